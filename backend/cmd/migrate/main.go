@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"budgetbuddy/migrations"
+	"budgetbuddy/pkg/config"
+	"budgetbuddy/pkg/database"
+	"budgetbuddy/pkg/logger"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  up        apply all pending migrations
+  down [n]  roll back the last n migrations (default 1)
+  status    list migrations and when they were applied`
+
+// errUsage означает неверные аргументы командной строки: печатается справка, код выхода 2.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	// Инициализация логгера
+	logger.Init()
+
+	// Ошибки возвращаются из run, а не завершают процесс сразу, чтобы отработали defer
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		logger.Error(err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	// Загрузка конфигурации
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := logger.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+		return fmt.Errorf("failed to configure logger: %w", err)
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		logger.Info("Applied ", applied, " migrations")
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return errors.New("number of migrations to roll back must be a positive integer")
+			}
+		}
+		rolledBack, err := migrator.Down(steps)
+		if err != nil {
+			return fmt.Errorf("failed to roll back migrations: %w", err)
+		}
+		logger.Info("Rolled back ", rolledBack, " migrations")
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get migration status: %w", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errUsage
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,33 +10,54 @@ import (
 	"time"
 
 	"budgetbuddy/internal/user/handlers"
-	"budgetbuddy/internal/user/migrations"
 	"budgetbuddy/internal/user/repository"
+	"budgetbuddy/migrations"
 	"budgetbuddy/pkg/config"
+	"budgetbuddy/pkg/database"
+	"budgetbuddy/pkg/health"
 	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/metrics"
+	"budgetbuddy/pkg/middleware"
 )
 
 func main() {
 	// Инициализация логгера
 	logger.Init()
 
+	// Ошибки запуска возвращаются из run, а не завершают процесс сразу, чтобы отработали defer
+	if err := run(); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	// Загрузка конфигурации
 	cfg, err := config.Load()
 	if err != nil {
-		logger.Fatal("Failed to load config: ", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.ValidateMail(); err != nil {
+		return fmt.Errorf("invalid mail config: %w", err)
+	}
+	if err := logger.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+		return fmt.Errorf("failed to configure logger: %w", err)
 	}
 
+	// Подключение к базе с ожиданием её готовности
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
 	// Выполнение миграций
-	if err := migrations.RunMigrations(cfg); err != nil {
-		logger.Fatal("Failed to run migrations: ", err)
+	if err := migrations.RunMigrations(db); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
 	// Инициализация репозитория
-	repo, err := repository.NewRepository(cfg)
-	if err != nil {
-		logger.Fatal("Failed to initialize repository: ", err)
-	}
-	defer repo.Close()
+	repo := repository.NewRepository(db)
 
 	// Инициализация роутера
 	mux := http.NewServeMux()
@@ -43,33 +65,75 @@ func main() {
 	// Инициализация обработчиков
 	handlers.SetupRoutes(mux, repo, cfg)
 
+	// Метрики в формате Prometheus: пул соединений с базой и HTTP-запросы по маршрутам
+	database.RegisterMetrics(metrics.Default, db)
+	httpMetrics := middleware.NewHTTPMetrics(metrics.Default)
+
+	// Общее ограничение частоты запросов с одного IP-адреса
+	limiter := middleware.NewRateLimiter("ip", middleware.NewMemoryStore(),
+		middleware.PerMinute(cfg.RateLimitPerMinute, cfg.RateLimitPerMinute))
+
+	// Проверки живости и готовности идут мимо ограничения частоты и метрик маршрутов:
+	// частые пробы оркестратора не должны расходовать лимит и получать 429
+	root := http.NewServeMux()
+	health.Register(root,
+		health.Check{Name: "database", Run: db.PingContext},
+		health.Check{Name: "migrations", Run: func(ctx context.Context) error { return migrations.CheckApplied(ctx, db) }},
+	)
+	root.Handle("/", limiter.Handler(httpMetrics.Instrument(mux)))
+
 	// Настройка сервера
 	server := &http.Server{
 		Addr:         ":" + cfg.UserServicePort,
-		Handler:      mux,
+		Handler:      middleware.RequestLogger(root),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	// /metrics отдаётся отдельным внутренним листенером, а не публичным портом
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Default.Handler())
+	metricsServer := &http.Server{
+		Addr:         cfg.UserMetricsAddr,
+		Handler:      metricsMux,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
 
-	// Запуск сервера в горутине
+	// Запуск серверов в горутинах
+	serverErr := make(chan error, 2)
+	go func() {
+		logger.Info("Serving metrics on ", cfg.UserMetricsAddr)
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
 	go func() {
 		logger.Info("Starting server on port ", cfg.UserServicePort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Server failed: ", err)
+			serverErr <- err
 		}
 	}()
 
-	// Ожидание сигнала завершения
+	// Ожидание сигнала завершения или ошибки сервера
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+	select {
+	case <-stop:
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	}
 
 	// Корректное завершение работы
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatal("Server shutdown failed: ", err)
+		return fmt.Errorf("server shutdown failed: %w", err)
+	}
+	if err := metricsServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("metrics server shutdown failed: %w", err)
 	}
 	logger.Info("Server gracefully stopped")
-}
\ No newline at end of file
+	return nil
+}
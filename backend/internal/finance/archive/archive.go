@@ -0,0 +1,179 @@
+// Package archive записывает и читает архив данных пользователя для переноса между
+// экземплярами сервиса. Архив — zip из JSON-документов:
+//
+//	manifest.json     — формат и версия архива
+//	profile.json      — профиль пользователя
+//	categories.json   — категории с подкатегориями
+//	accounts.json     — счета
+//	transactions.json — доходы и расходы
+//	transfers.json    — переводы между счетами
+//	goals.json        — цели со взносами
+//	budgets.json      — бюджеты
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+)
+
+// Format и Version записываются в manifest.json. Версия увеличивается при несовместимых
+// изменениях документов; архивы более новой версии не читаются.
+const (
+	Format  = "budgetbuddy-archive"
+	Version = 1
+)
+
+// MaxDocumentSize ограничивает размер распакованного документа архива.
+const MaxDocumentSize = 256 << 20
+
+// ErrInvalidArchive возвращается, если файл не является архивом поддерживаемой версии.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+type Manifest struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransactionSource передаёт транзакции пользователя в fn по одной, чтобы их
+// не нужно было держать в памяти при записи архива.
+type TransactionSource func(fn func(*models.TransactionResponse) error) error
+
+// Write записывает архив в w. Транзакции берутся из transactions, а не из data.Transactions.
+func Write(w io.Writer, profile *models.ArchiveProfile, data *models.FinanceArchive, transactions TransactionSource) error {
+	zw := zip.NewWriter(w)
+
+	documents := []struct {
+		name  string
+		value interface{}
+	}{
+		{"manifest.json", Manifest{Format: Format, Version: Version, CreatedAt: time.Now().UTC()}},
+		{"profile.json", profile},
+		{"categories.json", nonNil(data.Categories)},
+		{"accounts.json", nonNil(data.Accounts)},
+		{"transfers.json", nonNil(data.Transfers)},
+		{"goals.json", nonNil(data.Goals)},
+		{"budgets.json", nonNil(data.Budgets)},
+	}
+	for _, doc := range documents {
+		f, err := zw.Create(doc.name)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(f).Encode(doc.value); err != nil {
+			return fmt.Errorf("write %s: %w", doc.name, err)
+		}
+	}
+
+	// Транзакции записываются JSON-массивом по мере чтения из базы
+	f, err := zw.Create("transactions.json")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "["); err != nil {
+		return err
+	}
+	separator := ""
+	err = transactions(func(tx *models.TransactionResponse) error {
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, separator); err != nil {
+			return err
+		}
+		separator = ",\n"
+		_, err = f.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write transactions.json: %w", err)
+	}
+	if _, err := io.WriteString(f, "]\n"); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// nonNil заменяет nil-срез пустым, чтобы в документе был [] вместо null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// Read читает архив. Отсутствующие документы с данными считаются пустыми,
+// manifest.json и profile.json обязательны.
+func Read(r io.ReaderAt, size int64) (*models.ArchiveProfile, *models.FinanceArchive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	decode := func(name string, v interface{}, required bool) error {
+		f, ok := files[name]
+		if !ok {
+			if required {
+				return fmt.Errorf("%w: %s is missing", ErrInvalidArchive, name)
+			}
+			return nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+		}
+		defer rc.Close()
+		limited := &io.LimitedReader{R: rc, N: MaxDocumentSize + 1}
+		if err := json.NewDecoder(limited).Decode(v); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+		}
+		if limited.N <= 0 {
+			return fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidArchive, name, MaxDocumentSize)
+		}
+		return nil
+	}
+
+	var manifest Manifest
+	if err := decode("manifest.json", &manifest, true); err != nil {
+		return nil, nil, err
+	}
+	if manifest.Format != Format {
+		return nil, nil, fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, manifest.Format)
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, manifest.Version)
+	}
+
+	var profile models.ArchiveProfile
+	if err := decode("profile.json", &profile, true); err != nil {
+		return nil, nil, err
+	}
+	var data models.FinanceArchive
+	for _, doc := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"categories.json", &data.Categories},
+		{"accounts.json", &data.Accounts},
+		{"transactions.json", &data.Transactions},
+		{"transfers.json", &data.Transfers},
+		{"goals.json", &data.Goals},
+		{"budgets.json", &data.Budgets},
+	} {
+		if err := decode(doc.name, doc.value, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &profile, &data, nil
+}
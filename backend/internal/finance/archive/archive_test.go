@@ -0,0 +1,81 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead(t *testing.T) {
+	day := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+	accountID := int64(3)
+	profile := &models.ArchiveProfile{Email: "user@example.com", Name: "User", BaseCurrency: "EUR", CreatedAt: day}
+	data := &models.FinanceArchive{
+		Categories: []models.ArchiveCategory{{
+			Category:      models.Category{ID: 1, Name: "Food", Type: "expense", System: true},
+			Subcategories: []models.Subcategory{{ID: 2, CategoryID: 1, Name: "Cafe"}},
+		}},
+		Accounts: []models.Account{{ID: accountID, Name: "Card", Type: models.AccountCard, Currency: "EUR"}},
+	}
+	transactions := []models.TransactionResponse{
+		{ID: 10, Type: "expense", Amount: money.MustParse("3.50"), Currency: "EUR", CategoryID: 1, AccountID: &accountID, Date: day},
+		{ID: 10, Type: "income", Amount: money.MustParse("100"), Currency: "EUR", CategoryID: 4, Date: day},
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, profile, data, func(fn func(*models.TransactionResponse) error) error {
+		for i := range transactions {
+			if err := fn(&transactions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	gotProfile, got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, profile, gotProfile)
+	assert.Equal(t, data.Categories, got.Categories)
+	assert.Equal(t, data.Accounts, got.Accounts)
+	assert.Equal(t, transactions, got.Transactions)
+	assert.Empty(t, got.Goals)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, _, err := Read(bytes.NewReader([]byte("not a zip")), 9)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	write := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, content := range files {
+			f, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = f.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	for name, files := range map[string]map[string]string{
+		"Newer Version":   {"manifest.json": `{"format":"budgetbuddy-archive","version":2}`, "profile.json": `{}`},
+		"Unknown Format":  {"manifest.json": `{"format":"other","version":1}`, "profile.json": `{}`},
+		"Missing Profile": {"manifest.json": `{"format":"budgetbuddy-archive","version":1}`},
+		"Broken Document": {"manifest.json": `{"format":"budgetbuddy-archive","version":1}`, "profile.json": `{}`, "goals.json": `{`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			content := write(files)
+			_, _, err := Read(bytes.NewReader(content), int64(len(content)))
+			assert.ErrorIs(t, err, ErrInvalidArchive)
+		})
+	}
+}
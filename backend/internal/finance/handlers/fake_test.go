@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	finance_repository "budgetbuddy/internal/finance/repository"
+	user_models "budgetbuddy/internal/user/models"
+)
+
+// fakeRepo — Repository в памяти для тестов обработчиков. В памяти реализованы категории
+// и транзакции; остальные методы возвращают errNotSupported.
+type fakeRepo struct {
+	mu           sync.Mutex
+	categories   map[int64]*models.Category
+	owners       map[int64]int64
+	transactions []models.Transaction
+	nextID       int64
+	exportDelay  time.Duration
+}
+
+var _ Repository = (*fakeRepo)(nil)
+
+// errNotSupported возвращают методы, которые тестам обработчиков пока не нужны.
+var errNotSupported = errors.New("not supported by fakeRepo")
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		categories: make(map[int64]*models.Category),
+		owners:     make(map[int64]int64),
+	}
+}
+
+func (f *fakeRepo) SaveCategory(ctx context.Context, userID int64, category *models.Category) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	saved := *category
+	saved.ID = f.nextID
+	f.categories[saved.ID] = &saved
+	f.owners[saved.ID] = userID
+	return saved.ID, nil
+}
+
+func (f *fakeRepo) GetCategories(ctx context.Context, userID int64, txType string, includeArchived bool) ([]models.Category, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	categories := []models.Category{}
+	for id, c := range f.categories {
+		if f.owners[id] == userID && c.Type == txType && (includeArchived || !c.Archived) {
+			categories = append(categories, *c)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].ID < categories[j].ID })
+	return categories, nil
+}
+
+func (f *fakeRepo) save(userID int64, txType string, tx *models.Transaction) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.categories[tx.CategoryID]
+	if !ok || f.owners[tx.CategoryID] != userID || c.Type != txType {
+		return 0, fmt.Errorf("%s category with id %d does not exist: %w", txType, tx.CategoryID, finance_repository.ErrInvalidReference)
+	}
+	f.nextID++
+	saved := *tx
+	saved.ID, saved.Type = f.nextID, txType
+	f.transactions = append(f.transactions, saved)
+	return saved.ID, nil
+}
+
+func (f *fakeRepo) SaveIncome(ctx context.Context, userID int64, tx *models.Transaction) (int64, error) {
+	return f.save(userID, "income", tx)
+}
+
+func (f *fakeRepo) SaveExpense(ctx context.Context, userID int64, tx *models.Transaction) (int64, error) {
+	return f.save(userID, "expense", tx)
+}
+
+func (f *fakeRepo) GetTransactions(ctx context.Context, userID int64, filter *models.TransactionFilter) ([]models.Transaction, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var transactions []models.Transaction
+	for _, tx := range f.transactions {
+		if tx.UserID == userID && (filter.Type == "" || tx.Type == filter.Type) {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, "", nil
+}
+
+// CheckBudget возвращает nil, как репозиторий для категории без бюджета.
+func (f *fakeRepo) CheckBudget(ctx context.Context, userID, categoryID int64, month string) (*models.BudgetStatus, error) {
+	return nil, nil
+}
+
+func (f *fakeRepo) AddGoalContribution(ctx context.Context, contribution *models.GoalContribution) (*models.Goal, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) AverageSpendingByDayOfWeek(ctx context.Context, userID int64, baseCurrency string) ([]finance_repository.AverageSpending, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) CopyBudgets(ctx context.Context, userID int64, from, to string, overwrite bool) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) DeleteAccount(ctx context.Context, userID, id int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteBudget(ctx context.Context, id, userID int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteBudgetTemplate(ctx context.Context, id, userID int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteCategory(ctx context.Context, userID, id int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteGoal(ctx context.Context, id, userID int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteGoalContribution(ctx context.Context, goalID, id, userID int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteRecurringRule(ctx context.Context, id, userID int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteTransaction(ctx context.Context, userID int64, txType string, id int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) DeleteTransfer(ctx context.Context, id, userID int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) ExportBudgets(ctx context.Context, userID int64, fromMonth, toMonth string, fn func(*models.Budget) error) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) ExportCategories(ctx context.Context, userID int64, fn func(*models.Category) error) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) ExportGoals(ctx context.Context, userID int64, fn func(*models.Goal) error) error {
+	return errNotSupported
+}
+
+// ExportTransactions отдаёт транзакции пользователя, выжидая exportDelay перед каждой,
+// как медленный запрос к базе.
+func (f *fakeRepo) ExportTransactions(ctx context.Context, userID int64, from, to *time.Time, fn func(*models.Transaction) error) error {
+	f.mu.Lock()
+	transactions := append([]models.Transaction(nil), f.transactions...)
+	f.mu.Unlock()
+	for i := range transactions {
+		if transactions[i].UserID != userID {
+			continue
+		}
+		time.Sleep(f.exportDelay)
+		if err := fn(&transactions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) ForecastSavings(ctx context.Context, userID, goalID int64, baseCurrency string) (float64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) GetAccount(ctx context.Context, id, userID int64) (*models.Account, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetAccounts(ctx context.Context, userID int64, includeArchived bool) ([]models.Account, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetArchive(ctx context.Context, userID int64) (*models.FinanceArchive, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetBudgetStatuses(ctx context.Context, userID int64, month string) ([]models.BudgetStatus, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetBudgetTemplates(ctx context.Context, userID int64) ([]models.BudgetTemplate, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetBudgets(ctx context.Context, userID int64, month string) ([]models.Budget, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetGoalContributions(ctx context.Context, goalID, userID int64) ([]models.GoalContribution, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetGoals(ctx context.Context, userID int64) ([]models.Goal, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetRecurringRule(ctx context.Context, id, userID int64) (*models.RecurringRule, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetRecurringRules(ctx context.Context, userID int64) ([]models.RecurringRule, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetSubcategories(ctx context.Context, userID, categoryID int64) ([]models.Subcategory, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) GetTransfers(ctx context.Context, userID int64, accountID *int64) ([]models.Transfer, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) ImportTransactions(ctx context.Context, userID int64, transactions []models.Transaction) ([]int64, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) IncomeExpenseTrends(ctx context.Context, userID int64, baseCurrency string) ([]finance_repository.Trend, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) MergeCategory(ctx context.Context, userID, sourceID, targetID int64) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) PurgeUserData(ctx context.Context, tx *sql.Tx, userID int64) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) RestoreArchive(ctx context.Context, tx *sql.Tx, userID int64, data *models.FinanceArchive) (*models.RestoreResult, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) SaveAccount(ctx context.Context, account *models.Account) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) SaveBudget(ctx context.Context, budget *models.Budget) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) SaveBudgetTemplate(ctx context.Context, template *models.BudgetTemplate) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) SaveGoal(ctx context.Context, userID int64, goal *models.Goal) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) SaveRecurringRule(ctx context.Context, rule *models.RecurringRule) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) SaveSubcategory(ctx context.Context, userID int64, subcategory *models.Subcategory) (int64, error) {
+	return 0, errNotSupported
+}
+
+func (f *fakeRepo) SaveTransfer(ctx context.Context, transfer *models.Transfer) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) SpendingByCategory(ctx context.Context, userID int64, month, baseCurrency string) ([]finance_repository.Spending, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) TransactionFingerprints(ctx context.Context, userID int64, from, to time.Time) (map[string]int, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) UpdateAccount(ctx context.Context, userID, id int64, req *models.AccountUpdateRequest) (*models.Account, error) {
+	return nil, errNotSupported
+}
+
+func (f *fakeRepo) UpdateBudgetTemplate(ctx context.Context, template *models.BudgetTemplate) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) UpdateCategory(ctx context.Context, userID, id int64, req *models.CategoryUpdateRequest) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) UpdateGoal(ctx context.Context, id, userID int64, goal *models.Goal) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) UpdateRecurringRule(ctx context.Context, rule *models.RecurringRule) error {
+	return errNotSupported
+}
+
+func (f *fakeRepo) UpdateTransaction(ctx context.Context, userID int64, txType string, tx *models.Transaction) error {
+	return errNotSupported
+}
+
+// fakeUserRepo — UserRepository в памяти: пользователи с базовой валютой и активные сессии.
+type fakeUserRepo struct {
+	mu       sync.Mutex
+	users    map[int64]*user_models.User
+	sessions map[string]int64
+}
+
+var _ UserRepository = (*fakeUserRepo)(nil)
+
+func newFakeUserRepo() *fakeUserRepo {
+	return &fakeUserRepo{
+		users:    make(map[int64]*user_models.User),
+		sessions: make(map[string]int64),
+	}
+}
+
+func (f *fakeUserRepo) IsSessionActive(ctx context.Context, userID int64, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	owner, ok := f.sessions[id]
+	return ok && owner == userID, nil
+}
+
+func (f *fakeUserRepo) DeleteUser(ctx context.Context, userID int64, purge func(tx *sql.Tx) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.users[userID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(f.users, userID)
+	for id, owner := range f.sessions {
+		if owner == userID {
+			delete(f.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (f *fakeUserRepo) GetUserBaseCurrency(ctx context.Context, userID int64) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[userID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return u.BaseCurrency, nil
+}
+
+func (f *fakeUserRepo) GetUserByID(ctx context.Context, userID int64) (*user_models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	user := *u
+	return &user, nil
+}
+
+func (f *fakeUserRepo) GetUserProfile(ctx context.Context, userID int64) (*user_models.User, error) {
+	user, err := f.GetUserByID(ctx, userID)
+	if user != nil {
+		user.Password = ""
+	}
+	return user, err
+}
+
+// RestoreProfile вызывает restore без транзакции: данные finance-service в fakeRepo не транзакционны.
+func (f *fakeUserRepo) RestoreProfile(ctx context.Context, userID int64, name, baseCurrency string, restore func(tx *sql.Tx) error) error {
+	if err := restore(nil); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u, ok := f.users[userID]; ok {
+		if name != "" {
+			u.Name = name
+		}
+		if baseCurrency != "" {
+			u.BaseCurrency = baseCurrency
+		}
+	}
+	return nil
+}
+
+// addUser заводит пользователя с базовой валютой currency и активной сессией sessionID.
+func (f *fakeUserRepo) addUser(id int64, currency, sessionID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[id] = &user_models.User{ID: id, Email: fmt.Sprintf("user%d@example.com", id), BaseCurrency: currency, CreatedAt: time.Now()}
+	f.sessions[sessionID] = id
+}
@@ -1,36 +1,58 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"budgetbuddy/internal/finance/archive"
+	"budgetbuddy/internal/finance/importer"
+	finance_metrics "budgetbuddy/internal/finance/metrics"
 	"budgetbuddy/internal/finance/models"
 	finance_repository "budgetbuddy/internal/finance/repository"
-	user_repository "budgetbuddy/internal/user/repository"
+	user_models "budgetbuddy/internal/user/models"
 	"budgetbuddy/pkg/config"
 	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/metrics"
 	"budgetbuddy/pkg/middleware"
+	"budgetbuddy/pkg/money"
 
 	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Блокировка подтверждения паролем при удалении учётной записи — как у входа в user-service
+const (
+	lockoutThreshold = 5
+	lockoutBase      = time.Minute
+	lockoutMax       = time.Hour
 )
 
 type Handlers struct {
-	repo      *finance_repository.Repository
-	userRepo  *user_repository.Repository
+	repo      Repository
+	userRepo  UserRepository
 	jwtSecret string
 	wsConns   map[int64][]*websocket.Conn
 	wsMutex   sync.RWMutex
+	lockout   *middleware.Lockout
 }
 
-func NewHandlers(repo *finance_repository.Repository, userRepo *user_repository.Repository, cfg *config.Config) *Handlers {
+func NewHandlers(repo Repository, userRepo UserRepository, cfg *config.Config) *Handlers {
 	return &Handlers{
 		repo:      repo,
 		userRepo:  userRepo,
 		jwtSecret: cfg.JWTSecret,
 		wsConns:   make(map[int64][]*websocket.Conn),
+		lockout:   middleware.NewLockout(lockoutThreshold, lockoutBase, lockoutMax),
 	}
 }
 
@@ -56,27 +78,63 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// События, рассылаемые клиентам по WebSocket
+const (
+	EventNewTransaction     = "new_transaction"
+	EventTransactionUpdated = "transaction_updated"
+	EventTransactionDeleted = "transaction_deleted"
+	EventBudgetWarning      = "budget_warning"
+	EventBudgetExceeded     = "budget_exceeded"
+	EventGoalReached        = "goal_reached"
+)
+
+// BudgetWarningPercentage — доля бюджета в процентах, при достижении которой отправляется предупреждение.
+const BudgetWarningPercentage = 80
+
 type WebSocketMessage struct {
 	Event string      `json:"event"`
 	Data  interface{} `json:"data"`
 }
 
-func SetupRoutes(mux *http.ServeMux, repo *finance_repository.Repository, userRepo *user_repository.Repository, cfg *config.Config) {
+// SetupRoutes регистрирует маршруты сервиса в mux, а число открытых WebSocket-соединений — в реестре reg.
+func SetupRoutes(mux *http.ServeMux, reg *metrics.Registry, repo Repository, userRepo UserRepository, cfg *config.Config) {
 	h := NewHandlers(repo, userRepo, cfg)
-	mux.HandleFunc("/income", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.AddIncome)))
-	mux.HandleFunc("/expense", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.AddExpense)))
-	mux.HandleFunc("/transactions", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.GetTransactions)))
-	mux.HandleFunc("/categories", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.handleCategories)))
-	mux.HandleFunc("/subcategories", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.handleSubcategories)))
-	mux.HandleFunc("/goals", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.handleGoals)))
-	mux.HandleFunc("/analytics/spending", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.SpendingByCategory)))
-	mux.HandleFunc("/analytics/trends", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.IncomeExpenseTrends)))
-	mux.HandleFunc("/analytics/average-spending", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.AverageSpendingByDayOfWeek)))
-	mux.HandleFunc("/analytics/forecast", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.ForecastSavings)))
-	mux.HandleFunc("/ws", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.WebSocketHandler)))
-	mux.HandleFunc("/budgets", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.SaveBudget)))
-	mux.HandleFunc("/budgets/list", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.GetBudgets)))
-	mux.HandleFunc("/budgets/delete", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.DeleteBudget)))
+	reg.NewGaugeFunc("budgetbuddy_websocket_connections",
+		"Number of open WebSocket connections.", h.websocketConnections)
+	mux.HandleFunc("/income", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.AddIncome)))
+	mux.HandleFunc("/expense", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.AddExpense)))
+	mux.HandleFunc("/transactions", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.GetTransactions)))
+	mux.HandleFunc("/import", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.ImportTransactions)))
+	mux.HandleFunc("/export", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.Export)))
+	mux.HandleFunc("/account", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.DeleteAccount)))
+	mux.HandleFunc("/account/archive", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.GetArchive)))
+	mux.HandleFunc("/account/restore", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.RestoreArchive)))
+	mux.HandleFunc("/transactions/{id}", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleTransaction)))
+	mux.HandleFunc("/accounts", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleAccounts)))
+	mux.HandleFunc("/accounts/{id}", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleAccount)))
+	mux.HandleFunc("/transfers", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleTransfers)))
+	mux.HandleFunc("/transfers/{id}", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.DeleteTransfer)))
+	mux.HandleFunc("/recurring", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleRecurringRules)))
+	mux.HandleFunc("/recurring/{id}", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleRecurringRule)))
+	mux.HandleFunc("/categories", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleCategories)))
+	mux.HandleFunc("/categories/{id}", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleCategory)))
+	mux.HandleFunc("/categories/{id}/merge", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.MergeCategory)))
+	mux.HandleFunc("/subcategories", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleSubcategories)))
+	mux.HandleFunc("/goals", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleGoals)))
+	mux.HandleFunc("/goals/{id}/contributions", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleGoalContributions)))
+	mux.HandleFunc("/goals/{id}/contributions/{contributionID}", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.DeleteGoalContribution)))
+	mux.HandleFunc("/analytics/spending", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.SpendingByCategory)))
+	mux.HandleFunc("/analytics/trends", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.IncomeExpenseTrends)))
+	mux.HandleFunc("/analytics/average-spending", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.AverageSpendingByDayOfWeek)))
+	mux.HandleFunc("/analytics/forecast", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.ForecastSavings)))
+	mux.HandleFunc("/ws", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.WebSocketHandler)))
+	mux.HandleFunc("/budgets", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.SaveBudget)))
+	mux.HandleFunc("/budgets/list", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.GetBudgets)))
+	mux.HandleFunc("/budgets/copy", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.CopyBudgets)))
+	mux.HandleFunc("/budgets/templates", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleBudgetTemplates)))
+	mux.HandleFunc("/budgets/templates/{id}", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.handleBudgetTemplate)))
+	mux.HandleFunc("/budgets/status", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.GetBudgetStatus)))
+	mux.HandleFunc("/budgets/delete", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.DeleteBudget)))
 }
 
 func (h *Handlers) AddIncome(w http.ResponseWriter, r *http.Request) {
@@ -87,8 +145,8 @@ func (h *Handlers) AddIncome(w http.ResponseWriter, r *http.Request) {
 
 	var req models.TransactionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		logger.Error("Failed to decode income request: ", err)
+		http.Error(w, requestBodyError(err), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode income request: ", err)
 		return
 	}
 
@@ -97,49 +155,60 @@ func (h *Handlers) AddIncome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+		http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+
 	date, err := time.Parse("2006-01-02", req.Date)
 	if err != nil {
 		http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
-		logger.Error("Invalid date format: ", err)
+		logger.ErrorContext(r.Context(), "Invalid date format: ", err)
 		return
 	}
 
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	currency, err := h.resolveTransactionCurrency(r.Context(), userID, &req)
+	if err != nil {
+		http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
 		return
 	}
 
 	tx := &models.Transaction{
 		UserID:        userID,
 		Amount:        req.Amount,
+		Currency:      currency,
 		CategoryID:    req.CategoryID,
 		SubcategoryID: req.SubcategoryID,
+		AccountID:     req.AccountID,
 		Description:   req.Description,
 		Tags:          req.Tags,
 		Date:          date,
 		Note:          req.Note,
 	}
 
-	id, err := h.repo.SaveIncome(userID, tx)
+	id, err := h.repo.SaveIncome(r.Context(), userID, tx)
+	if errors.Is(err, finance_repository.ErrInvalidReference) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to save income", http.StatusInternalServerError)
-		logger.Error("Failed to save income: ", err)
+		logger.ErrorContext(r.Context(), "Failed to save income: ", err)
 		return
 	}
 
-	response := models.TransactionResponse{
-		ID:            id,
-		Amount:        tx.Amount,
-		CategoryID:    tx.CategoryID,
-		SubcategoryID: tx.SubcategoryID,
-		Description:   tx.Description,
-		Tags:          tx.Tags,
-		Date:          tx.Date,
-		Note:          tx.Note,
-	}
-	h.broadcastTransaction(userID, &response)
+	tx.ID = id
+	finance_metrics.TransactionsCreated.Inc("income", finance_metrics.SourceAPI)
+	response := newTransactionResponse("income", tx)
+	h.broadcast(userID, EventNewTransaction, &response)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
@@ -153,8 +222,8 @@ func (h *Handlers) AddExpense(w http.ResponseWriter, r *http.Request) {
 
 	var req models.TransactionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		logger.Error("Failed to decode expense request: ", err)
+		http.Error(w, requestBodyError(err), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode expense request: ", err)
 		return
 	}
 
@@ -163,57 +232,65 @@ func (h *Handlers) AddExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+		http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+
 	date, err := time.Parse("2006-01-02", req.Date)
 	if err != nil {
 		http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
-		logger.Error("Invalid date format: ", err)
+		logger.ErrorContext(r.Context(), "Invalid date format: ", err)
 		return
 	}
 
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	currency, err := h.resolveTransactionCurrency(r.Context(), userID, &req)
+	if err != nil {
+		http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
 		return
 	}
 
 	tx := &models.Transaction{
 		UserID:        userID,
 		Amount:        req.Amount,
+		Currency:      currency,
 		CategoryID:    req.CategoryID,
 		SubcategoryID: req.SubcategoryID,
+		AccountID:     req.AccountID,
 		Description:   req.Description,
 		Tags:          req.Tags,
 		Date:          date,
 		Note:          req.Note,
 	}
 
-	month := date.Format("2006-01")
-	budgetAmount, spent, err := h.repo.CheckBudget(userID, req.CategoryID, month)
-	if err != nil {
-		logger.Error("Failed to check budget: ", err)
-	} else if budgetAmount > 0 && spent+req.Amount > budgetAmount {
-		logger.Warn("Budget exceeded for category ", req.CategoryID, ": spent=", spent+req.Amount, ", budget=", budgetAmount)
+	// Исполнение бюджета до расхода нужно, чтобы уведомить только о переходе порога
+	budgetBefore, budgetErr := h.repo.CheckBudget(r.Context(), userID, req.CategoryID, date.Format("2006-01"))
+	if budgetErr != nil {
+		logger.ErrorContext(r.Context(), "Failed to check budget: ", budgetErr)
 	}
 
-	id, err := h.repo.SaveExpense(userID, tx)
+	id, err := h.repo.SaveExpense(r.Context(), userID, tx)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		logger.Error("Failed to save expense: ", err)
+		logger.ErrorContext(r.Context(), "Failed to save expense: ", err)
 		return
 	}
 
-	response := models.TransactionResponse{
-		ID:            id,
-		Amount:        tx.Amount,
-		CategoryID:    tx.CategoryID,
-		SubcategoryID: tx.SubcategoryID,
-		Description:   tx.Description,
-		Tags:          tx.Tags,
-		Date:          tx.Date,
-		Note:          tx.Note,
+	tx.ID = id
+	finance_metrics.TransactionsCreated.Inc("expense", finance_metrics.SourceAPI)
+	response := newTransactionResponse("expense", tx)
+	h.broadcast(userID, EventNewTransaction, &response)
+	if budgetErr == nil && budgetBefore != nil {
+		h.notifyBudget(r.Context(), userID, budgetBefore)
 	}
-	h.broadcastTransaction(userID, &response)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
@@ -225,58 +302,306 @@ func (h *Handlers) GetTransactions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	txType := r.URL.Query().Get("type")
-	if txType != "income" && txType != "expense" {
-		http.Error(w, "Invalid transaction type, use 'income' or 'expense'", http.StatusBadRequest)
+	filter, err := parseTransactionFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 
-	transactions, err := h.repo.GetTransactions(userID, txType)
+	transactions, nextCursor, err := h.repo.GetTransactions(r.Context(), userID, filter)
+	if errors.Is(err, finance_repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to get transactions", http.StatusInternalServerError)
-		logger.Error("Failed to get transactions: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get transactions: ", err)
 		return
 	}
 
-	response := make([]models.TransactionResponse, len(transactions))
-	for i, tx := range transactions {
-		response[i] = models.TransactionResponse{
-			ID:            tx.ID,
-			Amount:        tx.Amount,
-			CategoryID:    tx.CategoryID,
-			SubcategoryID: tx.SubcategoryID,
-			Description:   tx.Description,
-			Tags:          tx.Tags,
-			Date:          tx.Date,
-			Note:          tx.Note,
+	response := models.TransactionListResponse{
+		Transactions: make([]models.TransactionResponse, len(transactions)),
+		NextCursor:   nextCursor,
+	}
+	for i := range transactions {
+		response.Transactions[i] = newTransactionResponse(transactions[i].Type, &transactions[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseTransactionFilter разбирает параметры запроса GET /transactions.
+// Ошибки содержат сообщение, пригодное для ответа клиенту.
+func parseTransactionFilter(q url.Values) (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{
+		Type:     q.Get("type"),
+		Tag:      q.Get("tag"),
+		Search:   q.Get("search"),
+		Cursor:   q.Get("cursor"),
+		SortBy:   "date",
+		SortDesc: true,
+	}
+	if filter.Type == "" {
+		filter.Type = "all"
+	}
+	if filter.Type != "income" && filter.Type != "expense" && filter.Type != "all" {
+		return nil, errors.New("Invalid transaction type, use 'income', 'expense' or 'all'")
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s date format, use YYYY-MM-DD", name)
+			}
+			*dst = &date
+		}
+	}
+
+	for name, dst := range map[string]**int64{"category_id": &filter.CategoryID, "subcategory_id": &filter.SubcategoryID, "account_id": &filter.AccountID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s", name)
+			}
+			*dst = &id
+		}
+	}
+
+	for name, dst := range map[string]**money.Amount{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if v := q.Get(name); v != "" {
+			amount, err := money.Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s", name)
+			}
+			*dst = &amount
+		}
+	}
+
+	if v := q.Get("sort"); v != "" {
+		if v != "date" && v != "amount" {
+			return nil, errors.New("Invalid sort field, use 'date' or 'amount'")
+		}
+		filter.SortBy = v
+	}
+	if v := q.Get("order"); v != "" {
+		if v != "asc" && v != "desc" {
+			return nil, errors.New("Invalid sort order, use 'asc' or 'desc'")
+		}
+		filter.SortDesc = v == "desc"
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > finance_repository.MaxTransactionLimit {
+			return nil, fmt.Errorf("Invalid limit, use a number from 1 to %d", finance_repository.MaxTransactionLimit)
 		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+func (h *Handlers) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		h.UpdateTransaction(w, r)
+	case http.MethodDelete:
+		h.DeleteTransaction(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handlers) UpdateTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	txType := r.URL.Query().Get("type")
+	if txType != "income" && txType != "expense" {
+		http.Error(w, "Invalid transaction type, use 'income' or 'expense'", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, requestBodyError(err), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode transaction update request: ", err)
+		return
+	}
+
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+		http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Invalid date format: ", err)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	// Без валюты в запросе остаётся сохранённая (или валюта счёта), а не базовая валюта пользователя
+	tx := &models.Transaction{
+		ID:            id,
+		UserID:        userID,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		CategoryID:    req.CategoryID,
+		SubcategoryID: req.SubcategoryID,
+		AccountID:     req.AccountID,
+		Description:   req.Description,
+		Tags:          req.Tags,
+		Date:          date,
+		Note:          req.Note,
 	}
 
+	err = h.repo.UpdateTransaction(r.Context(), userID, txType, tx)
+	if errors.Is(err, finance_repository.ErrNotFound) {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, finance_repository.ErrInvalidReference) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update transaction", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to update transaction: ", err)
+		return
+	}
+
+	response := newTransactionResponse(txType, tx)
+	h.broadcast(userID, EventTransactionUpdated, &response)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
+func (h *Handlers) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	txType := r.URL.Query().Get("type")
+	if txType != "income" && txType != "expense" {
+		http.Error(w, "Invalid transaction type, use 'income' or 'expense'", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	err = h.repo.DeleteTransaction(r.Context(), userID, txType, id)
+	if errors.Is(err, finance_repository.ErrNotFound) {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete transaction", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to delete transaction: ", err)
+		return
+	}
+
+	h.broadcast(userID, EventTransactionDeleted, &models.TransactionResponse{ID: id, Type: txType})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveCurrency возвращает валюту из запроса или базовую валюту пользователя, если валюта не указана.
+func (h *Handlers) resolveCurrency(ctx context.Context, userID int64, currency string) (string, error) {
+	if currency != "" {
+		return currency, nil
+	}
+	return h.userRepo.GetUserBaseCurrency(ctx, userID)
+}
+
+// resolveTransactionCurrency возвращает валюту транзакции. Для транзакции со счётом валюта
+// по умолчанию берётся из счёта в репозитории, поэтому базовая валюта не подставляется.
+func (h *Handlers) resolveTransactionCurrency(ctx context.Context, userID int64, req *models.TransactionRequest) (string, error) {
+	if req.AccountID != nil {
+		return req.Currency, nil
+	}
+	return h.resolveCurrency(ctx, userID, req.Currency)
+}
+
+// requestBodyError возвращает клиенту причину ошибки разбора тела запроса.
+// Ошибки в денежных суммах (например, лишние знаки после запятой) показываются как есть.
+func requestBodyError(err error) string {
+	if errors.Is(err, money.ErrInvalidAmount) {
+		return err.Error()
+	}
+	return "Invalid request body"
+}
+
+func newTransactionResponse(txType string, tx *models.Transaction) models.TransactionResponse {
+	return models.TransactionResponse{
+		ID:            tx.ID,
+		Type:          txType,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		CategoryID:    tx.CategoryID,
+		SubcategoryID: tx.SubcategoryID,
+		AccountID:     tx.AccountID,
+		Description:   tx.Description,
+		Tags:          tx.Tags,
+		Date:          tx.Date,
+		Note:          tx.Note,
+	}
+}
+
 func (h *Handlers) handleCategories(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 
 	if r.Method == http.MethodPost {
 		var req models.Category
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			logger.Error("Failed to decode category request: ", err)
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode category request: ", err)
 			return
 		}
 
@@ -284,11 +609,15 @@ func (h *Handlers) handleCategories(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid category type, use 'income' or 'expense'", http.StatusBadRequest)
 			return
 		}
+		if strings.TrimSpace(req.Name) == "" {
+			http.Error(w, "Category name is required", http.StatusBadRequest)
+			return
+		}
 
-		id, err := h.repo.SaveCategory(&req)
+		id, err := h.repo.SaveCategory(r.Context(), userID, &req)
 		if err != nil {
 			http.Error(w, "Failed to save category", http.StatusInternalServerError)
-			logger.Error("Failed to save category: ", err)
+			logger.ErrorContext(r.Context(), "Failed to save category: ", err)
 			return
 		}
 
@@ -306,10 +635,11 @@ func (h *Handlers) handleCategories(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		categories, err := h.repo.GetCategories(userID, txType)
+		includeArchived := r.URL.Query().Get("include_archived") == "true"
+		categories, err := h.repo.GetCategories(r.Context(), userID, txType, includeArchived)
 		if err != nil {
 			http.Error(w, "Failed to get categories", http.StatusInternalServerError)
-			logger.Error("Failed to get categories: ", err)
+			logger.ErrorContext(r.Context(), "Failed to get categories: ", err)
 			return
 		}
 
@@ -322,110 +652,254 @@ func (h *Handlers) handleCategories(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
-func (h *Handlers) handleSubcategories(w http.ResponseWriter, r *http.Request) {
-	_, err := h.getUserIDFromToken(r)
+func (h *Handlers) handleCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 
-	if r.Method == http.MethodPost {
-		var req models.Subcategory
+	if r.Method == http.MethodPut {
+		var req models.CategoryUpdateRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			logger.Error("Failed to decode subcategory request: ", err)
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode category update request: ", err)
+			return
+		}
+		if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+			http.Error(w, "Category name must not be empty", http.StatusBadRequest)
 			return
 		}
 
-		id, err := h.repo.SaveSubcategory(&req)
+		err := h.repo.UpdateCategory(r.Context(), userID, id, &req)
+		if writeCategoryError(w, err) {
+			return
+		}
+		if errors.Is(err, finance_repository.ErrAlreadyExists) {
+			http.Error(w, "Category with this name already exists", http.StatusConflict)
+			return
+		}
 		if err != nil {
-			http.Error(w, "Failed to save subcategory", http.StatusInternalServerError)
-			logger.Error("Failed to save subcategory: ", err)
+			http.Error(w, "Failed to update category", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to update category: ", err)
 			return
 		}
-
-		response := models.Subcategory{ID: id, CategoryID: req.CategoryID, Name: req.Name}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(response)
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	if r.Method == http.MethodGet {
-		categoryIDStr := r.URL.Query().Get("category_id")
-		categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid category_id", http.StatusBadRequest)
+	if r.Method == http.MethodDelete {
+		err := h.repo.DeleteCategory(r.Context(), userID, id)
+		if writeCategoryError(w, err) {
+			return
+		}
+		if errors.Is(err, finance_repository.ErrConflict) {
+			http.Error(w, "Category is in use, merge it into another category instead", http.StatusConflict)
 			return
 		}
-
-		subcategories, err := h.repo.GetSubcategories(categoryID)
 		if err != nil {
-			http.Error(w, "Failed to get subcategories", http.StatusInternalServerError)
-			logger.Error("Failed to get subcategories: ", err)
+			http.Error(w, "Failed to delete category", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to delete category: ", err)
 			return
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(subcategories)
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
-func (h *Handlers) handleGoals(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) MergeCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 
-	if r.Method == http.MethodPost {
-		var req models.GoalRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			logger.Error("Failed to decode goal request: ", err)
-			return
-		}
+	var req models.CategoryMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, requestBodyError(err), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode category merge request: ", err)
+		return
+	}
 
-		deadline, err := time.Parse("2006-01-02", req.Deadline)
-		if err != nil {
-			http.Error(w, "Invalid deadline format, use YYYY-MM-DD", http.StatusBadRequest)
-			logger.Error("Invalid deadline format: ", err)
-			return
-		}
+	moved, err := h.repo.MergeCategory(r.Context(), userID, id, req.TargetID)
+	if writeCategoryError(w, err) {
+		return
+	}
+	if errors.Is(err, finance_repository.ErrInvalidReference) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to merge category", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to merge category: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int64{"moved_transactions": moved})
+}
+
+// writeCategoryError отвечает на попытку изменить чужую или системную категорию
+// и сообщает, была ли ошибка обработана.
+func writeCategoryError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, finance_repository.ErrNotFound):
+		http.Error(w, "Category not found", http.StatusNotFound)
+	case errors.Is(err, finance_repository.ErrForbidden):
+		http.Error(w, "System categories cannot be modified", http.StatusForbidden)
+	default:
+		return false
+	}
+	return true
+}
+
+func (h *Handlers) handleSubcategories(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.Subcategory
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode subcategory request: ", err)
+			return
+		}
+
+		if strings.TrimSpace(req.Name) == "" {
+			http.Error(w, "Subcategory name is required", http.StatusBadRequest)
+			return
+		}
+
+		id, err := h.repo.SaveSubcategory(r.Context(), userID, &req)
+		if errors.Is(err, finance_repository.ErrInvalidReference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save subcategory", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to save subcategory: ", err)
+			return
+		}
+
+		response := models.Subcategory{ID: id, CategoryID: req.CategoryID, Name: req.Name}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		categoryIDStr := r.URL.Query().Get("category_id")
+		categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid category_id", http.StatusBadRequest)
+			return
+		}
+
+		subcategories, err := h.repo.GetSubcategories(r.Context(), userID, categoryID)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to get subcategories", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get subcategories: ", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(subcategories)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (h *Handlers) handleGoals(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.GoalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode goal request: ", err)
+			return
+		}
+
+		deadline, err := time.Parse("2006-01-02", req.Deadline)
+		if err != nil {
+			http.Error(w, "Invalid deadline format, use YYYY-MM-DD", http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Invalid deadline format: ", err)
+			return
+		}
+
+		if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+			http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+			return
+		}
+		currency, err := h.resolveCurrency(r.Context(), userID, req.Currency)
+		if err != nil {
+			http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+			return
+		}
 
 		goal := &models.Goal{
-			UserID:        userID,
-			Name:          req.Name,
-			TargetAmount:  req.TargetAmount,
-			CurrentAmount: 0,
-			Deadline:      deadline,
-			CreatedAt:     time.Now(),
+			UserID:       userID,
+			Name:         req.Name,
+			TargetAmount: req.TargetAmount,
+			Currency:     currency,
+			Deadline:     deadline,
+			CreatedAt:    time.Now(),
 		}
 
-		id, err := h.repo.SaveGoal(userID, goal)
+		id, err := h.repo.SaveGoal(r.Context(), userID, goal)
 		if err != nil {
 			http.Error(w, "Failed to save goal", http.StatusInternalServerError)
-			logger.Error("Failed to save goal: ", err)
+			logger.ErrorContext(r.Context(), "Failed to save goal: ", err)
 			return
 		}
+		goal.ID = id
 
-		response := models.GoalResponse{
-			ID:            id,
-			Name:          goal.Name,
-			TargetAmount:  goal.TargetAmount,
-			CurrentAmount: goal.CurrentAmount,
-			Deadline:      goal.Deadline,
-			CreatedAt:     goal.CreatedAt,
-		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(newGoalResponse(goal))
 		return
 	}
 
@@ -439,28 +913,43 @@ func (h *Handlers) handleGoals(w http.ResponseWriter, r *http.Request) {
 
 		var req models.GoalRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			logger.Error("Failed to decode goal update request: ", err)
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode goal update request: ", err)
 			return
 		}
 
 		deadline, err := time.Parse("2006-01-02", req.Deadline)
 		if err != nil {
 			http.Error(w, "Invalid deadline format, use YYYY-MM-DD", http.StatusBadRequest)
-			logger.Error("Invalid deadline format: ", err)
+			logger.ErrorContext(r.Context(), "Invalid deadline format: ", err)
 			return
 		}
 
+		if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+			http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+			return
+		}
+
+		// Без указанной валюты цель сохраняет текущую
 		goal := &models.Goal{
 			Name:         req.Name,
 			TargetAmount: req.TargetAmount,
+			Currency:     req.Currency,
 			Deadline:     deadline,
 		}
 
-		err = h.repo.UpdateGoal(id, userID, goal)
+		err = h.repo.UpdateGoal(r.Context(), id, userID, goal)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, finance_repository.ErrConflict) {
+			http.Error(w, "Cannot change currency of a goal with contributions", http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to update goal", http.StatusInternalServerError)
-			logger.Error("Failed to update goal: ", err)
+			logger.ErrorContext(r.Context(), "Failed to update goal: ", err)
 			return
 		}
 
@@ -469,23 +958,16 @@ func (h *Handlers) handleGoals(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == http.MethodGet {
-		goals, err := h.repo.GetGoals(userID)
+		goals, err := h.repo.GetGoals(r.Context(), userID)
 		if err != nil {
 			http.Error(w, "Failed to get goals", http.StatusInternalServerError)
-			logger.Error("Failed to get goals: ", err)
+			logger.ErrorContext(r.Context(), "Failed to get goals: ", err)
 			return
 		}
 
 		response := make([]models.GoalResponse, len(goals))
-		for i, g := range goals {
-			response[i] = models.GoalResponse{
-				ID:            g.ID,
-				Name:          g.Name,
-				TargetAmount:  g.TargetAmount,
-				CurrentAmount: g.CurrentAmount,
-				Deadline:      g.Deadline,
-				CreatedAt:     g.CreatedAt,
-			}
+		for i := range goals {
+			response[i] = newGoalResponse(&goals[i])
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -502,20 +984,184 @@ func (h *Handlers) handleGoals(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = h.repo.DeleteGoal(id, userID)
+		err = h.repo.DeleteGoal(r.Context(), id, userID)
 		if err != nil {
 			http.Error(w, "Failed to delete goal", http.StatusInternalServerError)
-			logger.Error("Failed to delete goal: ", err)
+			logger.ErrorContext(r.Context(), "Failed to delete goal: ", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func newGoalResponse(g *models.Goal) models.GoalResponse {
+	return models.GoalResponse{
+		ID:            g.ID,
+		Name:          g.Name,
+		TargetAmount:  g.TargetAmount,
+		CurrentAmount: g.CurrentAmount,
+		Currency:      g.Currency,
+		Deadline:      g.Deadline,
+		CreatedAt:     g.CreatedAt,
+	}
+}
+
+func (h *Handlers) handleGoalContributions(w http.ResponseWriter, r *http.Request) {
+	goalID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid goal ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.GoalContributionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode goal contribution request: ", err)
+			return
+		}
+
+		contribution := &models.GoalContribution{
+			GoalID:     goalID,
+			UserID:     userID,
+			Amount:     req.Amount,
+			Note:       req.Note,
+			ExpenseID:  req.ExpenseID,
+			TransferID: req.TransferID,
+			CreatedAt:  time.Now(),
+		}
+		if req.ExpenseID != nil && req.TransferID != nil {
+			http.Error(w, "Link either an expense or a transfer, not both", http.StatusBadRequest)
+			return
+		}
+		if req.ExpenseID != nil || req.TransferID != nil {
+			if req.Amount != 0 || req.Date != "" {
+				http.Error(w, "Amount and date are taken from the linked expense or transfer", http.StatusBadRequest)
+				return
+			}
+		} else {
+			if req.Amount == 0 {
+				http.Error(w, "Amount must not be zero", http.StatusBadRequest)
+				return
+			}
+			contribution.Date = time.Now().UTC().Truncate(24 * time.Hour)
+			if req.Date != "" {
+				contribution.Date, err = time.Parse("2006-01-02", req.Date)
+				if err != nil {
+					http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		goal, err := h.repo.AddGoalContribution(r.Context(), contribution)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, finance_repository.ErrInvalidReference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, finance_repository.ErrAlreadyExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to add goal contribution", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to add goal contribution: ", err)
+			return
+		}
+
+		// Событие отправляется только тем взносом, который довёл цель до целевой суммы
+		if before := goal.CurrentAmount - contribution.Amount; before < goal.TargetAmount && goal.CurrentAmount >= goal.TargetAmount {
+			logger.InfoContext(r.Context(), "Goal ", goal.ID, " reached for user ", userID)
+			response := newGoalResponse(goal)
+			h.broadcast(userID, EventGoalReached, &response)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(contribution)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		contributions, err := h.repo.GetGoalContributions(r.Context(), goalID, userID)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to get goal contributions", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get goal contributions: ", err)
 			return
 		}
+		if contributions == nil {
+			contributions = []models.GoalContribution{}
+		}
 
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(contributions)
 		return
 	}
 
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
+func (h *Handlers) DeleteGoalContribution(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	goalID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid goal ID", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("contributionID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid contribution ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	err = h.repo.DeleteGoalContribution(r.Context(), goalID, id, userID)
+	if errors.Is(err, finance_repository.ErrNotFound) {
+		http.Error(w, "Contribution not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete goal contribution", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to delete goal contribution: ", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *Handlers) SpendingByCategory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -525,7 +1171,7 @@ func (h *Handlers) SpendingByCategory(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 
@@ -535,10 +1181,21 @@ func (h *Handlers) SpendingByCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	spending, err := h.repo.SpendingByCategory(userID, month)
+	baseCurrency, err := h.userRepo.GetUserBaseCurrency(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+		return
+	}
+
+	spending, err := h.repo.SpendingByCategory(r.Context(), userID, month, baseCurrency)
+	if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to get spending data", http.StatusInternalServerError)
-		logger.Error("Failed to get spending data: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get spending data: ", err)
 		return
 	}
 
@@ -556,14 +1213,25 @@ func (h *Handlers) IncomeExpenseTrends(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	baseCurrency, err := h.userRepo.GetUserBaseCurrency(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
 		return
 	}
 
-	trends, err := h.repo.IncomeExpenseTrends(userID)
+	trends, err := h.repo.IncomeExpenseTrends(r.Context(), userID, baseCurrency)
+	if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to get trends data", http.StatusInternalServerError)
-		logger.Error("Failed to get trends data: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get trends data: ", err)
 		return
 	}
 
@@ -581,14 +1249,25 @@ func (h *Handlers) AverageSpendingByDayOfWeek(w http.ResponseWriter, r *http.Req
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	baseCurrency, err := h.userRepo.GetUserBaseCurrency(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
 		return
 	}
 
-	spending, err := h.repo.AverageSpendingByDayOfWeek(userID)
+	spending, err := h.repo.AverageSpendingByDayOfWeek(r.Context(), userID, baseCurrency)
+	if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to get average spending data", http.StatusInternalServerError)
-		logger.Error("Failed to get average spending data: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get average spending data: ", err)
 		return
 	}
 
@@ -606,7 +1285,7 @@ func (h *Handlers) ForecastSavings(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 
@@ -617,10 +1296,21 @@ func (h *Handlers) ForecastSavings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	monthsToGoal, err := h.repo.ForecastSavings(userID, goalID)
+	baseCurrency, err := h.userRepo.GetUserBaseCurrency(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+		return
+	}
+
+	monthsToGoal, err := h.repo.ForecastSavings(r.Context(), userID, goalID, baseCurrency)
+	if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		logger.Error("Failed to forecast savings: ", err)
+		logger.ErrorContext(r.Context(), "Failed to forecast savings: ", err)
 		return
 	}
 
@@ -634,13 +1324,13 @@ func (h *Handlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID for WebSocket: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID for WebSocket: ", err)
 		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Error("WebSocket upgrade failed: ", err)
+		logger.ErrorContext(r.Context(), "WebSocket upgrade failed: ", err)
 		return
 	}
 	defer conn.Close()
@@ -661,17 +1351,41 @@ func (h *Handlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 			h.wsMutex.Unlock()
-			logger.Error("WebSocket read error: ", err)
+			logger.ErrorContext(r.Context(), "WebSocket read error: ", err)
 			return
 		}
 	}
 }
 
-func (h *Handlers) broadcastTransaction(userID int64, tx *models.TransactionResponse) {
+// websocketConnections возвращает число открытых WebSocket-соединений всех пользователей.
+func (h *Handlers) websocketConnections() float64 {
+	h.wsMutex.RLock()
+	defer h.wsMutex.RUnlock()
+	n := 0
+	for _, conns := range h.wsConns {
+		n += len(conns)
+	}
+	return float64(n)
+}
+
+// closeConnections закрывает все WebSocket-соединения пользователя.
+func (h *Handlers) closeConnections(userID int64) {
+	h.wsMutex.Lock()
+	conns := h.wsConns[userID]
+	delete(h.wsConns, userID)
+	h.wsMutex.Unlock()
+	for _, conn := range conns {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account deleted"), time.Now().Add(time.Second))
+		conn.Close()
+	}
+}
+
+func (h *Handlers) broadcast(userID int64, event string, data interface{}) {
 	h.wsMutex.RLock()
 	defer h.wsMutex.RUnlock()
 	for _, conn := range h.wsConns[userID] {
-		err := conn.WriteJSON(WebSocketMessage{Event: "new_transaction", Data: tx})
+		err := conn.WriteJSON(WebSocketMessage{Event: event, Data: data})
 		if err != nil {
 			logger.Error("Failed to send WebSocket message: ", err)
 		}
@@ -686,35 +1400,57 @@ func (h *Handlers) SaveBudget(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 	var req models.Budget
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		logger.Error("Failed to decode budget request: ", err)
+		http.Error(w, requestBodyError(err), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode budget request: ", err)
 		return
 	}
 	if req.Amount <= 0 {
 		http.Error(w, "Amount must be positive", http.StatusBadRequest)
 		return
 	}
-	if req.Month == "" {
+
+	if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+		http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+	if _, err := time.Parse("2006-01", req.Month); err != nil {
 		http.Error(w, "Month is required (YYYY-MM)", http.StatusBadRequest)
 		return
 	}
 
+	currency, err := h.resolveCurrency(r.Context(), userID, req.Currency)
+	if err != nil {
+		http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+		return
+	}
+
 	budget := &models.Budget{
 		UserID:     userID,
 		CategoryID: req.CategoryID,
 		Amount:     req.Amount,
+		Currency:   currency,
 		Month:      req.Month,
+		Rollover:   req.Rollover,
 		CreatedAt:  time.Now(),
 	}
-	id, err := h.repo.SaveBudget(budget)
+	id, err := h.repo.SaveBudget(r.Context(), budget)
+	if errors.Is(err, finance_repository.ErrAlreadyExists) {
+		http.Error(w, "Budget for this category and month already exists", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, finance_repository.ErrInvalidReference) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to save budget", http.StatusInternalServerError)
-		logger.Error("Failed to save budget: ", err)
+		logger.ErrorContext(r.Context(), "Failed to save budget: ", err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -730,18 +1466,18 @@ func (h *Handlers) GetBudgets(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 	month := r.URL.Query().Get("month")
-	if month == "" {
+	if _, err := time.Parse("2006-01", month); err != nil {
 		http.Error(w, "Month parameter required (YYYY-MM)", http.StatusBadRequest)
 		return
 	}
-	budgets, err := h.repo.GetBudgets(userID, month)
+	budgets, err := h.repo.GetBudgets(r.Context(), userID, month)
 	if err != nil {
 		http.Error(w, "Failed to get budgets", http.StatusInternalServerError)
-		logger.Error("Failed to get budgets: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get budgets: ", err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -749,33 +1485,1434 @@ func (h *Handlers) GetBudgets(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(budgets)
 }
 
-func (h *Handlers) DeleteBudget(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
+func (h *Handlers) GetBudgetStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
-		logger.Error("Invalid budget ID: ", err)
+	month := r.URL.Query().Get("month")
+	if _, err := time.Parse("2006-01", month); err != nil {
+		http.Error(w, "Month parameter required (YYYY-MM)", http.StatusBadRequest)
+		return
+	}
+	statuses, err := h.repo.GetBudgetStatuses(r.Context(), userID, month)
+	if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
-	err = h.repo.DeleteBudget(id, userID)
 	if err != nil {
-		http.Error(w, "Failed to delete budget", http.StatusInternalServerError)
-		logger.Error("Failed to delete budget: ", err)
+		http.Error(w, "Failed to get budget status", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get budget status: ", err)
 		return
 	}
+	if statuses == nil {
+		statuses = []models.BudgetStatus{}
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(statuses)
 }
 
-func (h *Handlers) getUserIDFromToken(r *http.Request) (int64, error) {
-	return h.userRepo.GetUserIDByEmail(r.Header.Get("X-User-Email"))
+func (h *Handlers) CopyBudgets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+	var req models.BudgetCopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, requestBodyError(err), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode budget copy request: ", err)
+		return
+	}
+	_, fromErr := time.Parse("2006-01", req.FromMonth)
+	_, toErr := time.Parse("2006-01", req.ToMonth)
+	if fromErr != nil || toErr != nil {
+		http.Error(w, "from_month and to_month are required (YYYY-MM)", http.StatusBadRequest)
+		return
+	}
+	if req.FromMonth == req.ToMonth {
+		http.Error(w, "from_month and to_month must differ", http.StatusBadRequest)
+		return
+	}
+	copied, err := h.repo.CopyBudgets(r.Context(), userID, req.FromMonth, req.ToMonth, req.Overwrite)
+	if err != nil {
+		http.Error(w, "Failed to copy budgets", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to copy budgets: ", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int64{"copied": copied})
+}
+
+// newBudgetTemplate проверяет запрос на создание или изменение шаблона бюджета.
+// Пустой начальный месяц остаётся пустым: значение по умолчанию выбирает вызывающий.
+func newBudgetTemplate(userID int64, req *models.BudgetTemplate) (*models.BudgetTemplate, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("Amount must be positive")
+	}
+	if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+		return nil, errors.New("Invalid currency, use ISO 4217 code")
+	}
+	if req.StartMonth != "" {
+		if _, err := time.Parse("2006-01", req.StartMonth); err != nil {
+			return nil, errors.New("Invalid start_month format, use YYYY-MM")
+		}
+	}
+	return &models.BudgetTemplate{
+		UserID:     userID,
+		CategoryID: req.CategoryID,
+		Amount:     req.Amount,
+		Rollover:   req.Rollover,
+		StartMonth: req.StartMonth,
+	}, nil
+}
+
+func (h *Handlers) handleBudgetTemplates(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.BudgetTemplate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode budget template request: ", err)
+			return
+		}
+
+		template, err := newBudgetTemplate(userID, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Новый шаблон без начального месяца действует с текущего месяца
+		if template.StartMonth == "" {
+			template.StartMonth = time.Now().Format("2006-01")
+		}
+		template.Currency, err = h.resolveCurrency(r.Context(), userID, req.Currency)
+		if err != nil {
+			http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+			return
+		}
+		template.CreatedAt = time.Now()
+
+		id, err := h.repo.SaveBudgetTemplate(r.Context(), template)
+		if errors.Is(err, finance_repository.ErrAlreadyExists) {
+			http.Error(w, "Budget template for this category already exists", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, finance_repository.ErrInvalidReference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save budget template", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to save budget template: ", err)
+			return
+		}
+		template.ID = id
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(template)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		templates, err := h.repo.GetBudgetTemplates(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Failed to get budget templates", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get budget templates: ", err)
+			return
+		}
+		if templates == nil {
+			templates = []models.BudgetTemplate{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(templates)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (h *Handlers) handleBudgetTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid budget template ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req models.BudgetTemplate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode budget template update request: ", err)
+			return
+		}
+
+		// Без валюты и начального месяца в запросе остаются сохранённые
+		template, err := newBudgetTemplate(userID, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		template.Currency = req.Currency
+		template.ID = id
+
+		err = h.repo.UpdateBudgetTemplate(r.Context(), template)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Budget template not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update budget template", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to update budget template: ", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		err := h.repo.DeleteBudgetTemplate(r.Context(), id, userID)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Budget template not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to delete budget template", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to delete budget template: ", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// notifyBudget пересчитывает бюджет после нового расхода и уведомляет клиентов,
+// если расход перевёл бюджет через порог предупреждения или превысил лимит.
+func (h *Handlers) notifyBudget(ctx context.Context, userID int64, before *models.BudgetStatus) {
+	after, err := h.repo.CheckBudget(ctx, userID, before.CategoryID, before.Month)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check budget: ", err)
+		return
+	}
+	if after == nil {
+		return
+	}
+	if event := budgetEvent(before.Percentage, after.Percentage); event != "" {
+		if event == EventBudgetExceeded {
+			finance_metrics.BudgetsExceeded.Inc()
+		}
+		logger.InfoContext(ctx, "Budget ", after.BudgetID, " reached ", after.Percentage, "% for user ", userID)
+		h.broadcast(userID, event, after)
+	}
+}
+
+// budgetEvent возвращает событие для перехода исполнения бюджета от before к after процентам
+// или пустую строку, если порог не пересечён.
+func budgetEvent(before, after float64) string {
+	switch {
+	case after > 100 && before <= 100:
+		return EventBudgetExceeded
+	case after >= BudgetWarningPercentage && before < BudgetWarningPercentage:
+		return EventBudgetWarning
+	}
+	return ""
+}
+
+func (h *Handlers) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+	idStr := r.URL.Query().Get("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Invalid budget ID: ", err)
+		return
+	}
+	err = h.repo.DeleteBudget(r.Context(), id, userID)
+	if err != nil {
+		http.Error(w, "Failed to delete budget", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to delete budget: ", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handlers) getUserIDFromToken(r *http.Request) (int64, error) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return 0, errors.New("request is not authenticated")
+	}
+	return principal.UserID, nil
+}
+
+func (h *Handlers) handleRecurringRules(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.RecurringRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode recurring rule request: ", err)
+			return
+		}
+
+		rule, err := newRecurringRule(userID, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule.Currency, err = h.resolveCurrency(r.Context(), userID, req.Currency)
+		if err != nil {
+			http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+			return
+		}
+		rule.CreatedAt = time.Now()
+
+		id, err := h.repo.SaveRecurringRule(r.Context(), rule)
+		if errors.Is(err, finance_repository.ErrInvalidReference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save recurring rule", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to save recurring rule: ", err)
+			return
+		}
+		rule.ID = id
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(newRecurringRuleResponse(rule))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		rules, err := h.repo.GetRecurringRules(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Failed to get recurring rules", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get recurring rules: ", err)
+			return
+		}
+
+		response := make([]models.RecurringRuleResponse, len(rules))
+		for i := range rules {
+			response[i] = newRecurringRuleResponse(&rules[i])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (h *Handlers) handleRecurringRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid recurring rule ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		rule, err := h.repo.GetRecurringRule(r.Context(), id, userID)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Recurring rule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to get recurring rule", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get recurring rule: ", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(newRecurringRuleResponse(rule))
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req models.RecurringRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode recurring rule update request: ", err)
+			return
+		}
+
+		// Без валюты в запросе остаётся сохранённая
+		rule, err := newRecurringRule(userID, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule.Currency = req.Currency
+		rule.ID = id
+
+		err = h.repo.UpdateRecurringRule(r.Context(), rule)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Recurring rule not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, finance_repository.ErrInvalidReference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update recurring rule", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to update recurring rule: ", err)
+			return
+		}
+
+		updated, err := h.repo.GetRecurringRule(r.Context(), id, userID)
+		if err != nil {
+			http.Error(w, "Failed to get recurring rule", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get recurring rule: ", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(newRecurringRuleResponse(updated))
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		err := h.repo.DeleteRecurringRule(r.Context(), id, userID)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Recurring rule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to delete recurring rule", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to delete recurring rule: ", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// newRecurringRule проверяет запрос и строит по нему правило.
+// Ошибки содержат сообщение, пригодное для ответа клиенту.
+func newRecurringRule(userID int64, req *models.RecurringRuleRequest) (*models.RecurringRule, error) {
+	if req.Type != "income" && req.Type != "expense" {
+		return nil, errors.New("Invalid transaction type, use 'income' or 'expense'")
+	}
+	if req.Amount <= 0 {
+		return nil, errors.New("Amount must be positive")
+	}
+	if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+		return nil, errors.New("Invalid currency, use ISO 4217 code")
+	}
+	if !models.ValidFrequency(req.Frequency) {
+		return nil, errors.New("Invalid frequency, use 'daily', 'weekly', 'monthly' or 'yearly'")
+	}
+	if req.Interval == 0 {
+		req.Interval = 1
+	}
+	if req.Interval < 0 {
+		return nil, errors.New("Interval must be positive")
+	}
+	if req.Count != nil && *req.Count <= 0 {
+		return nil, errors.New("Count must be positive")
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, errors.New("Invalid start_date format, use YYYY-MM-DD")
+	}
+	var endDate *time.Time
+	if req.EndDate != "" {
+		date, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			return nil, errors.New("Invalid end_date format, use YYYY-MM-DD")
+		}
+		if date.Before(startDate) {
+			return nil, errors.New("end_date must not be before start_date")
+		}
+		endDate = &date
+	}
+
+	return &models.RecurringRule{
+		UserID:        userID,
+		Type:          req.Type,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		CategoryID:    req.CategoryID,
+		SubcategoryID: req.SubcategoryID,
+		Description:   req.Description,
+		Tags:          req.Tags,
+		Note:          req.Note,
+		Frequency:     req.Frequency,
+		Interval:      req.Interval,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		Count:         req.Count,
+	}, nil
+}
+
+func newRecurringRuleResponse(rule *models.RecurringRule) models.RecurringRuleResponse {
+	return models.RecurringRuleResponse{
+		ID:              rule.ID,
+		Type:            rule.Type,
+		Amount:          rule.Amount,
+		Currency:        rule.Currency,
+		CategoryID:      rule.CategoryID,
+		SubcategoryID:   rule.SubcategoryID,
+		Description:     rule.Description,
+		Tags:            rule.Tags,
+		Note:            rule.Note,
+		Frequency:       rule.Frequency,
+		Interval:        rule.Interval,
+		StartDate:       rule.StartDate,
+		EndDate:         rule.EndDate,
+		Count:           rule.Count,
+		OccurrenceCount: rule.OccurrenceCount,
+		NextDate:        rule.NextDate,
+		CreatedAt:       rule.CreatedAt,
+	}
+}
+
+func (h *Handlers) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.AccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode account request: ", err)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			http.Error(w, "Account name must not be empty", http.StatusBadRequest)
+			return
+		}
+		if !models.ValidAccountType(req.Type) {
+			http.Error(w, "Invalid account type, use 'cash', 'card', 'savings' or 'credit'", http.StatusBadRequest)
+			return
+		}
+		if req.Currency != "" && !money.ValidCurrency(req.Currency) {
+			http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+			return
+		}
+
+		currency, err := h.resolveCurrency(r.Context(), userID, req.Currency)
+		if err != nil {
+			http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+			return
+		}
+
+		account := &models.Account{
+			UserID:         userID,
+			Name:           req.Name,
+			Type:           req.Type,
+			Currency:       currency,
+			OpeningBalance: req.OpeningBalance,
+			Balance:        req.OpeningBalance,
+			CreatedAt:      time.Now(),
+		}
+		account.ID, err = h.repo.SaveAccount(r.Context(), account)
+		if errors.Is(err, finance_repository.ErrAlreadyExists) {
+			http.Error(w, "Account with this name already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save account", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to save account: ", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(account)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		accounts, err := h.repo.GetAccounts(r.Context(), userID, r.URL.Query().Get("include_archived") == "true")
+		if err != nil {
+			http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get accounts: ", err)
+			return
+		}
+		if accounts == nil {
+			accounts = []models.Account{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(accounts)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (h *Handlers) handleAccount(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	var account *models.Account
+	switch r.Method {
+	case http.MethodGet:
+		account, err = h.repo.GetAccount(r.Context(), id, userID)
+	case http.MethodPut:
+		var req models.AccountUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode account update request: ", err)
+			return
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				http.Error(w, "Account name must not be empty", http.StatusBadRequest)
+				return
+			}
+			req.Name = &name
+		}
+		account, err = h.repo.UpdateAccount(r.Context(), userID, id, &req)
+	case http.MethodDelete:
+		err = h.repo.DeleteAccount(r.Context(), userID, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if errors.Is(err, finance_repository.ErrNotFound) {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, finance_repository.ErrAlreadyExists) {
+		http.Error(w, "Account with this name already exists", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, finance_repository.ErrConflict) {
+		http.Error(w, "Account has transactions or transfers, archive it instead", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to process account", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to process account: ", err)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(account)
+}
+
+// handleTransfers создаёт и возвращает переводы между счетами. Переводы не являются
+// доходами или расходами и не попадают в аналитику и бюджеты.
+func (h *Handlers) handleTransfers(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req models.TransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, requestBodyError(err), http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Failed to decode transfer request: ", err)
+			return
+		}
+		if req.FromAccountID == req.ToAccountID {
+			http.Error(w, "Source and destination accounts must differ", http.StatusBadRequest)
+			return
+		}
+		if req.Amount <= 0 || (req.ToAmount != nil && *req.ToAmount <= 0) {
+			http.Error(w, "Amount must be positive", http.StatusBadRequest)
+			return
+		}
+		date, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+
+		transfer := &models.Transfer{
+			UserID:        userID,
+			FromAccountID: req.FromAccountID,
+			ToAccountID:   req.ToAccountID,
+			Amount:        req.Amount,
+			Date:          date,
+			Note:          req.Note,
+			CreatedAt:     time.Now(),
+		}
+		if req.ToAmount != nil {
+			transfer.ToAmount = *req.ToAmount
+		}
+
+		err = h.repo.SaveTransfer(r.Context(), transfer)
+		if errors.Is(err, finance_repository.ErrInvalidReference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, finance_repository.ErrMissingExchangeRate) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save transfer", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to save transfer: ", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(transfer)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		var accountID *int64
+		if v := r.URL.Query().Get("account_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid account_id", http.StatusBadRequest)
+				return
+			}
+			accountID = &id
+		}
+
+		transfers, err := h.repo.GetTransfers(r.Context(), userID, accountID)
+		if err != nil {
+			http.Error(w, "Failed to get transfers", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get transfers: ", err)
+			return
+		}
+		if transfers == nil {
+			transfers = []models.Transfer{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(transfers)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (h *Handlers) DeleteTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transfer ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	err = h.repo.DeleteTransfer(r.Context(), id, userID)
+	if errors.Is(err, finance_repository.ErrNotFound) {
+		http.Error(w, "Transfer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete transfer", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to delete transfer: ", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MaxImportSize — максимальный размер загружаемой выписки.
+const MaxImportSize = 10 << 20
+
+// ImportTransactions импортирует банковскую выписку (CSV, OFX или QIF), загруженную как
+// multipart/form-data в поле file. При dry_run=true ничего не сохраняется, а в ответе
+// возвращается предварительный просмотр: разобранные строки, ошибки и найденные дубликаты.
+// Иначе принятые строки сохраняются в одной транзакции БД. Дубликаты пропускаются, если
+// не передан import_duplicates=true; skip_rows — номера строк, которые нужно пропустить.
+func (h *Handlers) ImportTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+	if err := r.ParseMultipartForm(MaxImportSize); err != nil {
+		http.Error(w, "Invalid multipart form or file is too large", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to parse import form: ", err)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = importer.DetectFormat(header.Filename)
+	}
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := r.FormValue("dry_run") == "true"
+	importDuplicates := r.FormValue("import_duplicates") == "true"
+
+	categories := map[string]int64{}
+	for _, txType := range []string{"income", "expense"} {
+		if v := r.FormValue(txType + "_category_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s_category_id", txType), http.StatusBadRequest)
+				return
+			}
+			categories[txType] = id
+		}
+	}
+	skipRows := map[int]bool{}
+	if v := r.FormValue("skip_rows"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				http.Error(w, "Invalid skip_rows, use comma-separated row numbers", http.StatusBadRequest)
+				return
+			}
+			skipRows[n] = true
+		}
+	}
+
+	// Валюта по умолчанию — валюта счёта, валюта из запроса или базовая валюта пользователя
+	var accountID *int64
+	currency := r.FormValue("currency")
+	if currency != "" && !money.ValidCurrency(currency) {
+		http.Error(w, "Invalid currency, use ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+	if v := r.FormValue("account_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid account_id", http.StatusBadRequest)
+			return
+		}
+		account, err := h.repo.GetAccount(r.Context(), id, userID)
+		if errors.Is(err, finance_repository.ErrNotFound) {
+			http.Error(w, "Account not found", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to get account", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get account: ", err)
+			return
+		}
+		accountID, currency = &id, account.Currency
+	}
+	if currency == "" {
+		if currency, err = h.resolveCurrency(r.Context(), userID, ""); err != nil {
+			http.Error(w, "Failed to get base currency", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get base currency: ", err)
+			return
+		}
+	}
+
+	rows, err := importer.Parse(format, file, opts)
+	if err != nil {
+		http.Error(w, "Failed to parse statement: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var from, to time.Time
+	for i := range rows {
+		row := &rows[i]
+		if row.Error != "" {
+			continue
+		}
+		if row.Currency == "" {
+			row.Currency = currency
+		}
+		if accountID != nil && row.Currency != currency {
+			row.Error = fmt.Sprintf("currency %s does not match account currency %s", row.Currency, currency)
+			continue
+		}
+		if from.IsZero() || row.Date.Before(from) {
+			from = row.Date
+		}
+		if row.Date.After(to) {
+			to = row.Date
+		}
+	}
+
+	existing := map[string]int{}
+	if !to.IsZero() {
+		existing, err = h.repo.TransactionFingerprints(r.Context(), userID, from, to)
+		if err != nil {
+			http.Error(w, "Failed to check duplicates", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to get transaction fingerprints: ", err)
+			return
+		}
+	}
+
+	result := models.ImportResult{DryRun: dryRun, Rows: rows}
+	result.Duplicates = importer.MarkDuplicates(rows, existing)
+	var accepted []models.Transaction
+	for i := range rows {
+		row := &rows[i]
+		switch {
+		case row.Error != "":
+			result.Invalid++
+			continue
+		case skipRows[row.Row] || (row.Duplicate && !importDuplicates):
+			row.Skipped = true
+			continue
+		}
+		categoryID, ok := categories[row.Type]
+		if !ok && !dryRun {
+			http.Error(w, fmt.Sprintf("%s_category_id is required to import row %d", row.Type, row.Row), http.StatusBadRequest)
+			return
+		}
+		accepted = append(accepted, models.Transaction{
+			UserID:      userID,
+			Type:        row.Type,
+			Amount:      row.Amount,
+			Currency:    row.Currency,
+			CategoryID:  categoryID,
+			AccountID:   accountID,
+			Description: row.Description,
+			Date:        row.Date,
+			Note:        row.Note,
+		})
+	}
+	result.Imported = len(accepted)
+
+	status := http.StatusOK
+	if !dryRun && len(accepted) > 0 {
+		_, err := h.repo.ImportTransactions(r.Context(), userID, accepted)
+		if errors.Is(err, finance_repository.ErrInvalidReference) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to import transactions", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to import transactions: ", err)
+			return
+		}
+		for _, tx := range accepted {
+			finance_metrics.TransactionsCreated.Inc(tx.Type, finance_metrics.SourceImport)
+		}
+		logger.InfoContext(r.Context(), "Imported ", len(accepted), " transactions for user ", userID)
+		status = http.StatusCreated
+	}
+	if result.Rows == nil {
+		result.Rows = []models.ImportRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseImportOptions разбирает параметры формата выписки из полей формы.
+// Ошибки содержат сообщение, пригодное для ответа клиенту.
+func parseImportOptions(r *http.Request) (importer.Options, error) {
+	opts := importer.Options{
+		CSV: importer.CSVMapping{
+			HasHeader:   r.FormValue("has_header") != "false",
+			Date:        r.FormValue("date_column"),
+			Amount:      r.FormValue("amount_column"),
+			Debit:       r.FormValue("debit_column"),
+			Credit:      r.FormValue("credit_column"),
+			Description: r.FormValue("description_column"),
+			Note:        r.FormValue("note_column"),
+			Currency:    r.FormValue("currency_column"),
+		},
+		DateFormat:       r.FormValue("date_format"),
+		DecimalSeparator: r.FormValue("decimal_separator"),
+	}
+	if v := r.FormValue("delimiter"); v != "" {
+		if v == `\t` {
+			v = "\t"
+		}
+		delimiter := []rune(v)
+		if len(delimiter) != 1 {
+			return opts, errors.New("Invalid delimiter, use a single character")
+		}
+		opts.CSV.Delimiter = delimiter[0]
+	}
+	if opts.DecimalSeparator != "" && opts.DecimalSeparator != "." && opts.DecimalSeparator != "," {
+		return opts, errors.New("Invalid decimal_separator, use '.' or ','")
+	}
+	return opts, nil
+}
+
+// exportStream пишет записи выгрузки в ответ в формате CSV или NDJSON (по одному
+// JSON-объекту на строку) и периодически сбрасывает буфер клиенту.
+type exportStream struct {
+	w       http.ResponseWriter
+	csv     *csv.Writer
+	json    *json.Encoder
+	written int
+}
+
+// Число записей, после которого буфер выгрузки отправляется клиенту
+const exportFlushInterval = 100
+
+func newExportStream(w http.ResponseWriter, format, dataset string, header []string) (*exportStream, error) {
+	s := &exportStream{w: w}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, dataset, format))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		s.csv = csv.NewWriter(w)
+		return s, s.csv.Write(header)
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	s.json = json.NewEncoder(w)
+	return s, nil
+}
+
+func (s *exportStream) write(value interface{}, record []string) error {
+	var err error
+	if s.csv != nil {
+		for i, cell := range record {
+			record[i] = csvSafeCell(cell)
+		}
+		err = s.csv.Write(record)
+	} else {
+		err = s.json.Encode(value)
+	}
+	if err != nil {
+		return err
+	}
+	s.written++
+	if s.written%exportFlushInterval == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+// csvSafeCell защищает от CSV-инъекций: ячейку, которая начинается с =, +, - или @,
+// табличный редактор выполнит как формулу, поэтому к ней добавляется апостроф.
+// Числа (например, отрицательные суммы) остаются как есть.
+func csvSafeCell(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+func (s *exportStream) flush() error {
+	if s.csv != nil {
+		s.csv.Flush()
+		if err := s.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// clearWriteDeadline снимает WriteTimeout сервера для потоковой выгрузки: она может идти
+// дольше таймаута, а оборванный на середине файл клиент не отличит от целого.
+func clearWriteDeadline(ctx context.Context, w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.WarnContext(ctx, "Failed to clear write deadline: ", err)
+	}
+}
+
+func optionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+// Export выгружает данные пользователя: dataset — transactions (доходы и расходы),
+// budgets, goals или categories; format — csv или ndjson. Параметры from и to (YYYY-MM-DD)
+// ограничивают период для транзакций и месяцы для бюджетов. Записи читаются из базы
+// и отправляются клиенту по одной, поэтому объём выгрузки не ограничен памятью сервера.
+func (h *Handlers) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	dataset := q.Get("dataset")
+	if dataset == "" {
+		dataset = "transactions"
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		http.Error(w, "Invalid format, use 'csv' or 'ndjson'", http.StatusBadRequest)
+		return
+	}
+
+	var from, to *time.Time
+	for name, dst := range map[string]**time.Time{"from": &from, "to": &to} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s date format, use YYYY-MM-DD", name), http.StatusBadRequest)
+				return
+			}
+			*dst = &date
+		}
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	var header []string
+	var run func(s *exportStream) error
+	switch dataset {
+	case "transactions":
+		header = []string{"id", "type", "date", "amount", "currency", "category_id", "subcategory_id", "account_id", "description", "tags", "note"}
+		run = func(s *exportStream) error {
+			return h.repo.ExportTransactions(r.Context(), userID, from, to, func(tx *models.Transaction) error {
+				response := newTransactionResponse(tx.Type, tx)
+				return s.write(&response, []string{
+					strconv.FormatInt(tx.ID, 10), tx.Type, tx.Date.Format("2006-01-02"), tx.Amount.String(), tx.Currency,
+					strconv.FormatInt(tx.CategoryID, 10), optionalID(tx.SubcategoryID), optionalID(tx.AccountID),
+					tx.Description, strings.Join(tx.Tags, ","), tx.Note,
+				})
+			})
+		}
+	case "budgets":
+		var fromMonth, toMonth string
+		if from != nil {
+			fromMonth = from.Format("2006-01")
+		}
+		if to != nil {
+			toMonth = to.Format("2006-01")
+		}
+		header = []string{"id", "month", "category_id", "amount", "currency", "rollover", "rollover_amount", "template_id"}
+		run = func(s *exportStream) error {
+			return h.repo.ExportBudgets(r.Context(), userID, fromMonth, toMonth, func(b *models.Budget) error {
+				return s.write(b, []string{
+					strconv.FormatInt(b.ID, 10), b.Month, strconv.FormatInt(b.CategoryID, 10), b.Amount.String(), b.Currency,
+					strconv.FormatBool(b.Rollover), b.RolloverAmount.String(), optionalID(b.TemplateID),
+				})
+			})
+		}
+	case "goals":
+		header = []string{"id", "name", "target_amount", "current_amount", "currency", "deadline", "created_at"}
+		run = func(s *exportStream) error {
+			return h.repo.ExportGoals(r.Context(), userID, func(g *models.Goal) error {
+				response := newGoalResponse(g)
+				return s.write(&response, []string{
+					strconv.FormatInt(g.ID, 10), g.Name, g.TargetAmount.String(), g.CurrentAmount.String(), g.Currency,
+					g.Deadline.Format("2006-01-02"), g.CreatedAt.Format(time.RFC3339),
+				})
+			})
+		}
+	case "categories":
+		header = []string{"id", "name", "type", "system", "archived"}
+		run = func(s *exportStream) error {
+			return h.repo.ExportCategories(r.Context(), userID, func(c *models.Category) error {
+				return s.write(c, []string{
+					strconv.FormatInt(c.ID, 10), c.Name, c.Type, strconv.FormatBool(c.System), strconv.FormatBool(c.Archived),
+				})
+			})
+		}
+	default:
+		http.Error(w, "Invalid dataset, use 'transactions', 'budgets', 'goals' or 'categories'", http.StatusBadRequest)
+		return
+	}
+
+	clearWriteDeadline(r.Context(), w)
+
+	// После начала выгрузки статус ответа изменить нельзя: при ошибке поток обрывается
+	// и ошибка только записывается в лог
+	stream, err := newExportStream(w, format, dataset, header)
+	if err == nil {
+		err = run(stream)
+	}
+	if err == nil {
+		err = stream.flush()
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to export ", dataset, ": ", err)
+	}
+}
+
+// GetArchive выгружает все данные пользователя одним архивом для переноса на другой экземпляр сервиса.
+func (h *Handlers) GetArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	user, err := h.userRepo.GetUserProfile(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, "Failed to get user profile", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get user profile: ", err)
+		return
+	}
+	data, err := h.repo.GetArchive(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get archive data", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to get archive data: ", err)
+		return
+	}
+	profile := &models.ArchiveProfile{
+		Email:        user.Email,
+		Name:         user.Name,
+		BaseCurrency: user.BaseCurrency,
+		CreatedAt:    user.CreatedAt,
+	}
+	transactions := func(fn func(*models.TransactionResponse) error) error {
+		return h.repo.ExportTransactions(r.Context(), userID, nil, nil, func(tx *models.Transaction) error {
+			response := newTransactionResponse(tx.Type, tx)
+			return fn(&response)
+		})
+	}
+
+	// Архив большой учётной записи собирается дольше WriteTimeout сервера
+	clearWriteDeadline(r.Context(), w)
+
+	filename := fmt.Sprintf("budgetbuddy-%s.zip", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	// Как и в Export, после начала записи ошибку можно только залогировать
+	if err := archive.Write(w, profile, data, transactions); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to write archive: ", err)
+	}
+}
+
+// DeleteAccount удаляет учётную запись пользователя после подтверждения паролем: все данные
+// finance-service и сам пользователь удаляются в одной транзакции БД, выпущенные токены
+// отзываются, а открытые WebSocket-соединения закрываются.
+func (h *Handlers) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	var req user_models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, requestBodyError(err), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode delete account request: ", err)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	// Перебор пароля с украденным токеном ограничивается так же, как вход
+	key := fmt.Sprintf("delete-account:%d", userID)
+	if wait := h.lockout.Check(key); wait > 0 {
+		middleware.TooManyRequests(w, wait)
+		logger.ErrorContext(r.Context(), "Account deletion attempt for locked user: ", userID)
+		return
+	}
+	user, err := h.userRepo.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		h.lockout.Fail(key)
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Invalid password on account deletion for user: ", userID)
+		return
+	}
+	h.lockout.Reset(key)
+
+	err = h.userRepo.DeleteUser(r.Context(), userID, func(tx *sql.Tx) error {
+		return h.repo.PurgeUserData(r.Context(), tx, userID)
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+	h.closeConnections(userID)
+	logger.InfoContext(r.Context(), "Deleted account of user ", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MaxArchiveSize — максимальный размер загружаемого архива.
+const MaxArchiveSize = 100 << 20
+
+// RestoreArchive загружает архив из GET /account/archive в пустую учётную запись.
+// Имя и базовая валюта профиля берутся из архива, email не меняется.
+func (h *Handlers) RestoreArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Failed to get user ID", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxArchiveSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid multipart form or file is too large", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to parse restore form: ", err)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	profile, data, err := archive.Read(file, header.Size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to read archive: ", err)
+		return
+	}
+
+	// Профиль восстанавливается в той же транзакции, что и данные: при ошибке не меняется ничего
+	baseCurrency := profile.BaseCurrency
+	if !money.ValidCurrency(baseCurrency) {
+		baseCurrency = ""
+	}
+	var result *models.RestoreResult
+	err = h.userRepo.RestoreProfile(r.Context(), userID, profile.Name, baseCurrency, func(tx *sql.Tx) error {
+		var err error
+		result, err = h.repo.RestoreArchive(r.Context(), tx, userID, data)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, finance_repository.ErrConflict):
+			http.Error(w, "Archive can only be restored into an account without data", http.StatusConflict)
+		case errors.Is(err, finance_repository.ErrInvalidReference):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to restore archive", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to restore archive: ", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
 }
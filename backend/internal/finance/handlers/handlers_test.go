@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	finance_metrics "budgetbuddy/internal/finance/metrics"
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/auth"
+	"budgetbuddy/pkg/config"
+	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/middleware"
+	"budgetbuddy/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+func newTestHandlers(t *testing.T) (*Handlers, *fakeRepo, string) {
+	repo, userRepo := newFakeRepo(), newFakeUserRepo()
+	h := NewHandlers(repo, userRepo, config.NewTestConfig())
+	userRepo.addUser(1, "EUR", "session-1")
+	token, err := auth.GenerateJWT(h.jwtSecret, 1, "session-1")
+	require.NoError(t, err)
+	return h, repo, token
+}
+
+func call(h *Handlers, handler http.HandlerFunc, method, target string, body interface{}, token string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	middleware.AuthMiddleware(h.jwtSecret, h.userRepo, handler)(w, r)
+	return w
+}
+
+func TestCategories(t *testing.T) {
+	h, _, token := newTestHandlers(t)
+
+	w := call(h, h.handleCategories, http.MethodPost, "/categories", models.Category{Name: "Food", Type: "expense"}, token)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = call(h, h.handleCategories, http.MethodPost, "/categories", models.Category{Name: "Food", Type: "transfer"}, token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(h, h.handleCategories, http.MethodGet, "/categories?type=expense", nil, token)
+	require.Equal(t, http.StatusOK, w.Code)
+	var categories []models.Category
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&categories))
+	require.Len(t, categories, 1)
+	assert.Equal(t, "Food", categories[0].Name)
+}
+
+func TestAddExpense(t *testing.T) {
+	h, repo, token := newTestHandlers(t)
+	w := call(h, h.handleCategories, http.MethodPost, "/categories", models.Category{Name: "Food", Type: "expense"}, token)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var category models.Category
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&category))
+
+	before := finance_metrics.TransactionsCreated.Value("expense", finance_metrics.SourceAPI)
+	req := models.TransactionRequest{Amount: money.MustParse("12.50"), CategoryID: category.ID, Date: "2024-03-01"}
+	w = call(h, h.AddExpense, http.MethodPost, "/expenses", req, token)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created models.TransactionResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	// Без валюты в запросе берётся базовая валюта пользователя
+	assert.Equal(t, "EUR", created.Currency)
+	require.Len(t, repo.transactions, 1)
+
+	req.CategoryID = category.ID + 100
+	w = call(h, h.AddExpense, http.MethodPost, "/expenses", req, token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req.Amount = 0
+	w = call(h, h.AddExpense, http.MethodPost, "/expenses", req, token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(h, h.GetTransactions, http.MethodGet, "/transactions?type=expense", nil, token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list models.TransactionListResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Transactions, 1)
+	assert.Equal(t, created.ID, list.Transactions[0].ID)
+	assert.Equal(t, money.MustParse("12.50"), list.Transactions[0].Amount)
+
+	// Учитываются только успешно созданные транзакции
+	assert.Equal(t, before+1, finance_metrics.TransactionsCreated.Value("expense", finance_metrics.SourceAPI))
+}
+
+func TestRevokedSessionRejected(t *testing.T) {
+	h, _, _ := newTestHandlers(t)
+	token, err := auth.GenerateJWT(h.jwtSecret, 1, "unknown-session")
+	require.NoError(t, err)
+
+	w := call(h, h.GetTransactions, http.MethodGet, "/transactions", nil, token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = call(h, h.GetTransactions, http.MethodGet, "/transactions", nil, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestDeleteAccountLockout(t *testing.T) {
+	h, _, token := newTestHandlers(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	h.userRepo.(*fakeUserRepo).users[1].Password = string(hash)
+
+	for i := 0; i < lockoutThreshold; i++ {
+		w := call(h, h.DeleteAccount, http.MethodDelete, "/account", map[string]string{"password": "wrong"}, token)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	// После серии неверных паролей не проверяется даже верный
+	w := call(h, h.DeleteAccount, http.MethodDelete, "/account", map[string]string{"password": "secret"}, token)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	user, err := h.userRepo.GetUserByID(context.Background(), 1)
+	require.NoError(t, err)
+	assert.NotNil(t, user)
+}
+
+func TestCSVSafeCell(t *testing.T) {
+	for cell, expected := range map[string]string{
+		"Groceries":          "Groceries",
+		"":                   "",
+		"=HYPERLINK(\"x\")":  "'=HYPERLINK(\"x\")",
+		"+1+2":               "'+1+2",
+		"-2+3+cmd|' /C calc": "'-2+3+cmd|' /C calc",
+		"@SUM(A1)":           "'@SUM(A1)",
+		"-12.50":             "-12.50",
+	} {
+		assert.Equal(t, expected, csvSafeCell(cell), cell)
+	}
+}
+
+func TestExportOutlivesWriteTimeout(t *testing.T) {
+	h, repo, token := newTestHandlers(t)
+	for i := int64(1); i <= 3; i++ {
+		repo.transactions = append(repo.transactions, models.Transaction{ID: i, UserID: 1, Type: "expense",
+			Amount: money.MustParse("1"), Currency: "EUR", CategoryID: 1, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)})
+	}
+	// Выгрузка идёт дольше WriteTimeout сервера, но не обрывается
+	repo.exportDelay = 100 * time.Millisecond
+	server := httptest.NewUnstartedServer(middleware.AuthMiddleware(h.jwtSecret, h.userRepo, h.Export))
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/export?dataset=transactions&format=csv", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, records, 4)
+}
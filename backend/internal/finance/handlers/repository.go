@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	finance_repository "budgetbuddy/internal/finance/repository"
+	user_models "budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/middleware"
+)
+
+// Repository — методы репозитория finance-service, которые используют обработчики.
+// Реализуется *finance_repository.Repository; в тестах обработчиков — фейком в памяти.
+type Repository interface {
+	AddGoalContribution(ctx context.Context, contribution *models.GoalContribution) (*models.Goal, error)
+	AverageSpendingByDayOfWeek(ctx context.Context, userID int64, baseCurrency string) ([]finance_repository.AverageSpending, error)
+	CheckBudget(ctx context.Context, userID, categoryID int64, month string) (*models.BudgetStatus, error)
+	CopyBudgets(ctx context.Context, userID int64, from, to string, overwrite bool) (int64, error)
+	DeleteAccount(ctx context.Context, userID, id int64) error
+	DeleteBudget(ctx context.Context, id, userID int64) error
+	DeleteBudgetTemplate(ctx context.Context, id, userID int64) error
+	DeleteCategory(ctx context.Context, userID, id int64) error
+	DeleteGoal(ctx context.Context, id, userID int64) error
+	DeleteGoalContribution(ctx context.Context, goalID, id, userID int64) error
+	DeleteRecurringRule(ctx context.Context, id, userID int64) error
+	DeleteTransaction(ctx context.Context, userID int64, txType string, id int64) error
+	DeleteTransfer(ctx context.Context, id, userID int64) error
+	ExportBudgets(ctx context.Context, userID int64, fromMonth, toMonth string, fn func(*models.Budget) error) error
+	ExportCategories(ctx context.Context, userID int64, fn func(*models.Category) error) error
+	ExportGoals(ctx context.Context, userID int64, fn func(*models.Goal) error) error
+	ExportTransactions(ctx context.Context, userID int64, from, to *time.Time, fn func(*models.Transaction) error) error
+	ForecastSavings(ctx context.Context, userID, goalID int64, baseCurrency string) (float64, error)
+	GetAccount(ctx context.Context, id, userID int64) (*models.Account, error)
+	GetAccounts(ctx context.Context, userID int64, includeArchived bool) ([]models.Account, error)
+	GetArchive(ctx context.Context, userID int64) (*models.FinanceArchive, error)
+	GetBudgetStatuses(ctx context.Context, userID int64, month string) ([]models.BudgetStatus, error)
+	GetBudgetTemplates(ctx context.Context, userID int64) ([]models.BudgetTemplate, error)
+	GetBudgets(ctx context.Context, userID int64, month string) ([]models.Budget, error)
+	GetCategories(ctx context.Context, userID int64, txType string, includeArchived bool) ([]models.Category, error)
+	GetGoalContributions(ctx context.Context, goalID, userID int64) ([]models.GoalContribution, error)
+	GetGoals(ctx context.Context, userID int64) ([]models.Goal, error)
+	GetRecurringRule(ctx context.Context, id, userID int64) (*models.RecurringRule, error)
+	GetRecurringRules(ctx context.Context, userID int64) ([]models.RecurringRule, error)
+	GetSubcategories(ctx context.Context, userID, categoryID int64) ([]models.Subcategory, error)
+	GetTransactions(ctx context.Context, userID int64, filter *models.TransactionFilter) ([]models.Transaction, string, error)
+	GetTransfers(ctx context.Context, userID int64, accountID *int64) ([]models.Transfer, error)
+	ImportTransactions(ctx context.Context, userID int64, transactions []models.Transaction) ([]int64, error)
+	IncomeExpenseTrends(ctx context.Context, userID int64, baseCurrency string) ([]finance_repository.Trend, error)
+	MergeCategory(ctx context.Context, userID, sourceID, targetID int64) (int64, error)
+	PurgeUserData(ctx context.Context, tx *sql.Tx, userID int64) error
+	RestoreArchive(ctx context.Context, tx *sql.Tx, userID int64, data *models.FinanceArchive) (*models.RestoreResult, error)
+	SaveAccount(ctx context.Context, account *models.Account) (int64, error)
+	SaveBudget(ctx context.Context, budget *models.Budget) (int64, error)
+	SaveBudgetTemplate(ctx context.Context, template *models.BudgetTemplate) (int64, error)
+	SaveCategory(ctx context.Context, userID int64, category *models.Category) (int64, error)
+	SaveExpense(ctx context.Context, userID int64, tx *models.Transaction) (int64, error)
+	SaveGoal(ctx context.Context, userID int64, goal *models.Goal) (int64, error)
+	SaveIncome(ctx context.Context, userID int64, tx *models.Transaction) (int64, error)
+	SaveRecurringRule(ctx context.Context, rule *models.RecurringRule) (int64, error)
+	SaveSubcategory(ctx context.Context, userID int64, subcategory *models.Subcategory) (int64, error)
+	SaveTransfer(ctx context.Context, transfer *models.Transfer) error
+	SpendingByCategory(ctx context.Context, userID int64, month, baseCurrency string) ([]finance_repository.Spending, error)
+	TransactionFingerprints(ctx context.Context, userID int64, from, to time.Time) (map[string]int, error)
+	UpdateAccount(ctx context.Context, userID, id int64, req *models.AccountUpdateRequest) (*models.Account, error)
+	UpdateBudgetTemplate(ctx context.Context, template *models.BudgetTemplate) error
+	UpdateCategory(ctx context.Context, userID, id int64, req *models.CategoryUpdateRequest) error
+	UpdateGoal(ctx context.Context, id, userID int64, goal *models.Goal) error
+	UpdateRecurringRule(ctx context.Context, rule *models.RecurringRule) error
+	UpdateTransaction(ctx context.Context, userID int64, txType string, tx *models.Transaction) error
+}
+
+// UserRepository — методы репозитория user-service, нужные обработчикам finance-service:
+// профиль и базовая валюта пользователя, удаление учётной записи и проверка сессий.
+type UserRepository interface {
+	middleware.SessionStore
+	DeleteUser(ctx context.Context, userID int64, purge func(tx *sql.Tx) error) error
+	GetUserBaseCurrency(ctx context.Context, userID int64) (string, error)
+	GetUserByID(ctx context.Context, userID int64) (*user_models.User, error)
+	GetUserProfile(ctx context.Context, userID int64) (*user_models.User, error)
+	RestoreProfile(ctx context.Context, userID int64, name, baseCurrency string, restore func(tx *sql.Tx) error) error
+}
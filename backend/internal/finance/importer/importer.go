@@ -0,0 +1,443 @@
+// Package importer разбирает банковские выписки в форматах CSV, OFX и QIF.
+//
+// CSV: столбцы задаются сопоставлением CSVMapping — по названию из строки заголовка
+// или по номеру, начиная с 1. Сумма берётся либо из одного столбца со знаком
+// (отрицательная — расход), либо из пары столбцов списания и зачисления.
+//
+// OFX: поддерживаются OFX 1.x (SGML, без закрывающих тегов) и OFX 2.x (XML).
+// Операции читаются из блоков <STMTTRN>, валюта — из <CURDEF>.
+//
+// QIF: записи банковского счёта, разделённые строкой "^" (поля D, T, P, M).
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+)
+
+// Поддерживаемые форматы выписок
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+	FormatQIF = "qif"
+)
+
+// CSVMapping описывает расположение данных в CSV-файле. Значение столбца — название
+// из строки заголовка (без учёта регистра) или его номер, начиная с 1.
+type CSVMapping struct {
+	Delimiter   rune
+	HasHeader   bool
+	Date        string
+	Amount      string
+	Debit       string
+	Credit      string
+	Description string
+	Note        string
+	Currency    string
+}
+
+// Options — параметры разбора. DateFormat задаётся шаблоном из YYYY, YY, MM и DD
+// (например, "DD.MM.YYYY"), DecimalSeparator — "." или ",".
+type Options struct {
+	CSV              CSVMapping
+	DateFormat       string
+	DecimalSeparator string
+}
+
+// DetectFormat определяет формат выписки по расширению файла.
+func DetectFormat(filename string) string {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".csv", ".ofx", ".qif":
+		return ext[1:]
+	case ".qfx":
+		return FormatOFX
+	}
+	return ""
+}
+
+// Parse разбирает выписку указанного формата. Ошибка возвращается, если файл нельзя
+// разобрать целиком; ошибки отдельных операций записываются в ImportRow.Error.
+func Parse(format string, r io.Reader, opts Options) ([]models.ImportRow, error) {
+	switch format {
+	case FormatCSV:
+		return ParseCSV(r, opts)
+	case FormatOFX:
+		return ParseOFX(r)
+	case FormatQIF:
+		return ParseQIF(r, opts)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q, use csv, ofx or qif", format)
+	}
+}
+
+// ParseCSV разбирает выписку в формате CSV по сопоставлению opts.CSV.
+func ParseCSV(r io.Reader, opts Options) ([]models.ImportRow, error) {
+	mapping := opts.CSV
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if mapping.Delimiter != 0 {
+		reader.Comma = mapping.Delimiter
+	}
+
+	var header []string
+	if mapping.HasHeader {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		header = record
+	}
+
+	resolve := func(name, column string, required bool) (int, error) {
+		if column == "" {
+			if required {
+				return -1, fmt.Errorf("%s column is required", name)
+			}
+			return -1, nil
+		}
+		if n, err := strconv.Atoi(column); err == nil && n > 0 {
+			return n - 1, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")), column) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%s column %q not found", name, column)
+	}
+
+	dateCol, err := resolve("date", mapping.Date, true)
+	if err != nil {
+		return nil, err
+	}
+	amountCol, err := resolve("amount", mapping.Amount, false)
+	if err != nil {
+		return nil, err
+	}
+	debitCol, err := resolve("debit", mapping.Debit, false)
+	if err != nil {
+		return nil, err
+	}
+	creditCol, err := resolve("credit", mapping.Credit, false)
+	if err != nil {
+		return nil, err
+	}
+	if amountCol < 0 && debitCol < 0 && creditCol < 0 {
+		return nil, errors.New("amount column or debit/credit columns are required")
+	}
+	descriptionCol, err := resolve("description", mapping.Description, false)
+	if err != nil {
+		return nil, err
+	}
+	noteCol, err := resolve("note", mapping.Note, false)
+	if err != nil {
+		return nil, err
+	}
+	currencyCol, err := resolve("currency", mapping.Currency, false)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := dateLayout(opts.DateFormat, "YYYY-MM-DD")
+	var rows []models.ImportRow
+	line := 0
+	if mapping.HasHeader {
+		line = 1
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := models.ImportRow{
+			Row:         line,
+			Description: field(descriptionCol),
+			Note:        field(noteCol),
+			Currency:    strings.ToUpper(field(currencyCol)),
+		}
+		var errs []string
+		if row.Date, err = time.Parse(layout, field(dateCol)); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid date %q", field(dateCol)))
+		}
+
+		var amount money.Amount
+		if amountCol >= 0 {
+			amount, err = parseAmount(field(amountCol), opts.DecimalSeparator)
+		} else {
+			// Списание и зачисление в отдельных столбцах; заполнен обычно только один из них
+			var debit, credit money.Amount
+			debit, err = parseOptionalAmount(field(debitCol), opts.DecimalSeparator)
+			if err == nil {
+				credit, err = parseOptionalAmount(field(creditCol), opts.DecimalSeparator)
+			}
+			amount = abs(credit) - abs(debit)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		setAmount(&row, amount, &errs)
+		if row.Currency != "" && !money.ValidCurrency(row.Currency) {
+			errs = append(errs, fmt.Sprintf("invalid currency %q", row.Currency))
+		}
+		row.Error = strings.Join(errs, "; ")
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseOFX разбирает выписку в формате OFX. Даты в OFX всегда имеют вид YYYYMMDD[HHMMSS...],
+// а суммы записываются с точкой, поэтому параметры формата не нужны.
+func ParseOFX(r io.Reader) ([]models.ImportRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+	if !strings.Contains(strings.ToUpper(content), "<OFX>") {
+		return nil, errors.New("not an OFX file: <OFX> element not found")
+	}
+
+	var rows []models.ImportRow
+	var current map[string]string
+	currency := ""
+	// Значение элемента — текст от конца тега до следующего "<", что подходит и для SGML, и для XML
+	for _, part := range strings.Split(content, "<")[1:] {
+		tag, value, _ := strings.Cut(part, ">")
+		tag = strings.ToUpper(strings.TrimSpace(tag))
+		value = html.UnescapeString(strings.TrimSpace(value))
+		switch {
+		case tag == "STMTTRN":
+			// В SGML закрывающий тег блока может отсутствовать
+			if current != nil {
+				rows = append(rows, ofxRow(len(rows)+1, current, currency))
+			}
+			current = map[string]string{}
+		case tag == "/STMTTRN":
+			if current != nil {
+				rows = append(rows, ofxRow(len(rows)+1, current, currency))
+				current = nil
+			}
+		case tag == "CURDEF":
+			currency = strings.ToUpper(value)
+		case current != nil && !strings.HasPrefix(tag, "/"):
+			current[tag] = value
+		}
+	}
+	if current != nil {
+		rows = append(rows, ofxRow(len(rows)+1, current, currency))
+	}
+	return rows, nil
+}
+
+func ofxRow(n int, fields map[string]string, currency string) models.ImportRow {
+	row := models.ImportRow{
+		Row:         n,
+		Currency:    currency,
+		Description: fields["NAME"],
+		Note:        fields["MEMO"],
+	}
+	if row.Description == "" {
+		row.Description, row.Note = fields["MEMO"], ""
+	}
+	if c := strings.ToUpper(fields["CURRENCY"]); c != "" {
+		row.Currency = c
+	}
+
+	var errs []string
+	posted := fields["DTPOSTED"]
+	date, err := time.Parse("20060102", posted[:min(len(posted), 8)])
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("invalid date %q", posted))
+	}
+	row.Date = date
+	amount, err := parseAmount(fields["TRNAMT"], ".")
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	setAmount(&row, amount, &errs)
+	row.Error = strings.Join(errs, "; ")
+	return row
+}
+
+// ParseQIF разбирает выписку в формате QIF. Формат даты по умолчанию — MM/DD/YYYY;
+// апостроф в годах вида 07/01'25 воспринимается как "/".
+func ParseQIF(r io.Reader, opts Options) ([]models.ImportRow, error) {
+	layout := dateLayout(opts.DateFormat, "MM/DD/YYYY")
+	scanner := bufio.NewScanner(r)
+
+	var rows []models.ImportRow
+	var fields map[string]string
+	flush := func() {
+		if fields == nil {
+			return
+		}
+		rows = append(rows, qifRow(len(rows)+1, fields, layout, opts.DecimalSeparator))
+		fields = nil
+	}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+		if line == "^" {
+			flush()
+			continue
+		}
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		code, value := line[:1], strings.TrimSpace(line[1:])
+		if _, seen := fields[code]; !seen {
+			fields[code] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return rows, nil
+}
+
+func qifRow(n int, fields map[string]string, layout, decimalSeparator string) models.ImportRow {
+	row := models.ImportRow{Row: n, Description: fields["P"], Note: fields["M"]}
+	if row.Description == "" {
+		row.Description, row.Note = fields["M"], ""
+	}
+
+	var errs []string
+	rawDate := fields["D"]
+	date, err := time.Parse(layout, strings.ReplaceAll(rawDate, "'", "/"))
+	if err != nil && strings.Contains(rawDate, "'") {
+		// Год после апострофа обычно записан двумя цифрами
+		date, err = time.Parse(strings.Replace(layout, "2006", "06", 1), strings.ReplaceAll(rawDate, "'", "/"))
+	}
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("invalid date %q", rawDate))
+	}
+	row.Date = date
+
+	rawAmount := fields["T"]
+	if rawAmount == "" {
+		rawAmount = fields["U"]
+	}
+	amount, err := parseAmount(rawAmount, decimalSeparator)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	setAmount(&row, amount, &errs)
+	row.Error = strings.Join(errs, "; ")
+	return row
+}
+
+// setAmount записывает в строку тип и модуль суммы: отрицательная сумма — расход.
+func setAmount(row *models.ImportRow, amount money.Amount, errs *[]string) {
+	if amount == 0 {
+		if len(*errs) == 0 {
+			*errs = append(*errs, "amount must not be zero")
+		}
+		return
+	}
+	row.Type = "income"
+	if amount < 0 {
+		row.Type = "expense"
+	}
+	row.Amount = abs(amount)
+}
+
+func abs(a money.Amount) money.Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func parseOptionalAmount(s, decimalSeparator string) (money.Amount, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return parseAmount(s, decimalSeparator)
+}
+
+// parseAmount разбирает сумму с учётом десятичного разделителя. Разделители разрядов
+// (пробелы, апострофы и второй из знаков "." и ",") отбрасываются, сумма в скобках
+// считается отрицательной.
+func parseAmount(s, decimalSeparator string) (money.Amount, error) {
+	str := strings.TrimSpace(s)
+	negative := strings.HasPrefix(str, "(") && strings.HasSuffix(str, ")")
+	if negative {
+		str = str[1 : len(str)-1]
+	}
+	str = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "").Replace(str)
+	if decimalSeparator == "," {
+		str = strings.ReplaceAll(str, ".", "")
+		str = strings.ReplaceAll(str, ",", ".")
+	} else {
+		str = strings.ReplaceAll(str, ",", "")
+	}
+	amount, err := money.Parse(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// dateLayout переводит шаблон даты вида "DD.MM.YYYY" в формат пакета time.
+func dateLayout(pattern, fallback string) string {
+	if pattern == "" {
+		pattern = fallback
+	}
+	return strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(pattern)
+}
+
+// MarkDuplicates помечает строки, совпадающие по отпечатку с уже сохранёнными транзакциями,
+// и возвращает их число. existing — число транзакций пользователя с каждым отпечатком:
+// каждая сохранённая транзакция покрывает одну строку выписки, поэтому две одинаковые
+// покупки в один день не теряются, если сохранена только одна из них.
+func MarkDuplicates(rows []models.ImportRow, existing map[string]int) int {
+	duplicates := 0
+	for i := range rows {
+		row := &rows[i]
+		if row.Error != "" {
+			continue
+		}
+		row.Fingerprint = models.TransactionFingerprint(row.Type, row.Date, row.Amount, row.Description)
+		if existing[row.Fingerprint] > 0 {
+			existing[row.Fingerprint]--
+			row.Duplicate = true
+			duplicates++
+		}
+	}
+	return duplicates
+}
@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := "Дата;Сумма;Описание;Валюта\n" +
+		"02.07.2025;-1 234,50;Магазин;rub\n" +
+		"03.07.2025;50 000,00;Зарплата;RUB\n" +
+		"04.07.2025;abc;Ошибка;RUB\n"
+	opts := Options{
+		CSV:              CSVMapping{Delimiter: ';', HasHeader: true, Date: "дата", Amount: "Сумма", Description: "3", Currency: "Валюта"},
+		DateFormat:       "DD.MM.YYYY",
+		DecimalSeparator: ",",
+	}
+	rows, err := ParseCSV(strings.NewReader(input), opts)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.Equal(t, 2, rows[0].Row)
+	assert.Equal(t, "expense", rows[0].Type)
+	assert.Equal(t, money.MustParse("1234.50"), rows[0].Amount)
+	assert.Equal(t, "RUB", rows[0].Currency)
+	assert.Equal(t, time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC), rows[0].Date)
+	assert.Equal(t, "Магазин", rows[0].Description)
+	assert.Empty(t, rows[0].Error)
+
+	assert.Equal(t, "income", rows[1].Type)
+	assert.Contains(t, rows[2].Error, "invalid amount")
+
+	_, err = ParseCSV(strings.NewReader(input), Options{CSV: CSVMapping{Delimiter: ';', HasHeader: true, Date: "Дата", Amount: "Amount"}})
+	assert.Error(t, err)
+}
+
+func TestParseCSVDebitCredit(t *testing.T) {
+	input := "2025-07-02,Coffee,3.50,\n2025-07-03,Refund,,\"1,200.00\"\n"
+	opts := Options{CSV: CSVMapping{Date: "1", Description: "2", Debit: "3", Credit: "4"}}
+	rows, err := ParseCSV(strings.NewReader(input), opts)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "expense", rows[0].Type)
+	assert.Equal(t, money.MustParse("3.50"), rows[0].Amount)
+	assert.Equal(t, "income", rows[1].Type)
+	assert.Equal(t, money.MustParse("1200"), rows[1].Amount)
+}
+
+func TestParseOFX(t *testing.T) {
+	input := `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250702120000[-5:EST]
+<TRNAMT>-42.10
+<FITID>1
+<NAME>Grocery &amp; Co
+<MEMO>Card 1234
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250703
+<TRNAMT>1500.00
+<FITID>2
+<NAME>Salary
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+	rows, err := ParseOFX(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "expense", rows[0].Type)
+	assert.Equal(t, money.MustParse("42.10"), rows[0].Amount)
+	assert.Equal(t, "USD", rows[0].Currency)
+	assert.Equal(t, "Grocery & Co", rows[0].Description)
+	assert.Equal(t, "Card 1234", rows[0].Note)
+	assert.Equal(t, time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC), rows[0].Date)
+	assert.Equal(t, "income", rows[1].Type)
+
+	_, err = ParseOFX(strings.NewReader("date,amount\n"))
+	assert.Error(t, err)
+}
+
+func TestParseQIF(t *testing.T) {
+	input := "!Type:Bank\nD07/02'25\nT-1,250.00\nPRent\nMJuly\n^\nD07/03/2025\nT300.00\nPFriend\n^\n"
+	rows, err := ParseQIF(strings.NewReader(input), Options{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC), rows[0].Date)
+	assert.Equal(t, "expense", rows[0].Type)
+	assert.Equal(t, money.MustParse("1250"), rows[0].Amount)
+	assert.Equal(t, "Rent", rows[0].Description)
+	assert.Equal(t, "July", rows[0].Note)
+	assert.Equal(t, "income", rows[1].Type)
+}
+
+func TestMarkDuplicates(t *testing.T) {
+	date := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+	coffee := models.ImportRow{Type: "expense", Amount: money.MustParse("3.50"), Date: date, Description: "Coffee"}
+	rows := []models.ImportRow{coffee, coffee, {Row: 3, Error: "invalid date"}}
+	existing := map[string]int{
+		models.TransactionFingerprint("expense", date, money.MustParse("3.50"), "  COFFEE "): 1,
+	}
+
+	assert.Equal(t, 1, MarkDuplicates(rows, existing))
+	assert.True(t, rows[0].Duplicate)
+	assert.False(t, rows[1].Duplicate)
+	assert.Equal(t, rows[0].Fingerprint, rows[1].Fingerprint)
+	assert.Empty(t, rows[2].Fingerprint)
+}
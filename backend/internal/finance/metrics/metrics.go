@@ -0,0 +1,20 @@
+// Package metrics — бизнес-метрики finance-service в общем реестре процесса.
+package metrics
+
+import "budgetbuddy/pkg/metrics"
+
+// Источники создания транзакций для метки source.
+const (
+	SourceAPI       = "api"
+	SourceImport    = "import"
+	SourceRecurring = "recurring"
+)
+
+var (
+	// TransactionsCreated — созданные транзакции по типу (income, expense) и источнику.
+	TransactionsCreated = metrics.Default.NewCounterVec("budgetbuddy_transactions_created_total",
+		"Total number of transactions created by type and source.", "type", "source")
+	// BudgetsExceeded — сколько раз расход перевёл бюджет за 100%.
+	BudgetsExceeded = metrics.Default.NewCounterVec("budgetbuddy_budgets_exceeded_total",
+		"Total number of times an expense pushed a budget over its limit.")
+)
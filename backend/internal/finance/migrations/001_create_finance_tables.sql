@@ -1,28 +0,0 @@
-CREATE TABLE IF NOT EXISTS categories (
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(255) NOT NULL,
-    type VARCHAR(50) NOT NULL CHECK (type IN ('income', 'expense')),
-    UNIQUE (name, type)
-);
-
-CREATE TABLE IF NOT EXISTS incomes (
-    id SERIAL PRIMARY KEY,
-    user_id BIGINT NOT NULL,
-    amount DECIMAL(10,2) NOT NULL,
-    category_id BIGINT NOT NULL,
-    date TIMESTAMP NOT NULL,
-    note TEXT,
-    FOREIGN KEY (user_id) REFERENCES users(id),
-    FOREIGN KEY (category_id) REFERENCES categories(id)
-);
-
-CREATE TABLE IF NOT EXISTS expenses (
-    id SERIAL PRIMARY KEY,
-    user_id BIGINT NOT NULL,
-    amount DECIMAL(10,2) NOT NULL,
-    category_id BIGINT NOT NULL,
-    date TIMESTAMP NOT NULL,
-    note TEXT,
-    FOREIGN KEY (user_id) REFERENCES users(id),
-    FOREIGN KEY (category_id) REFERENCES categories(id)
-);
\ No newline at end of file
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"budgetbuddy/pkg/money"
+)
+
+// Типы счетов
+const (
+	AccountCash    = "cash"
+	AccountCard    = "card"
+	AccountSavings = "savings"
+	AccountCredit  = "credit"
+)
+
+// ValidAccountType проверяет, что тип счёта поддерживается.
+func ValidAccountType(accountType string) bool {
+	switch accountType {
+	case AccountCash, AccountCard, AccountSavings, AccountCredit:
+		return true
+	}
+	return false
+}
+
+type AccountRequest struct {
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	Currency       string       `json:"currency,omitempty"`
+	OpeningBalance money.Amount `json:"opening_balance"`
+}
+
+// AccountUpdateRequest — изменение счёта; пустые поля не меняются. Тип и валюта счёта не меняются.
+type AccountUpdateRequest struct {
+	Name           *string       `json:"name,omitempty"`
+	OpeningBalance *money.Amount `json:"opening_balance,omitempty"`
+	Archived       *bool         `json:"archived,omitempty"`
+}
+
+// Account — счёт пользователя (наличные, карта, накопительный, кредитный). Balance не хранится:
+// это начальный остаток плюс доходы, минус расходы и плюс движения по переводам в валюте счёта.
+type Account struct {
+	ID             int64        `json:"id"`
+	UserID         int64        `json:"user_id"`
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	Currency       string       `json:"currency"`
+	OpeningBalance money.Amount `json:"opening_balance"`
+	Balance        money.Amount `json:"balance"`
+	Archived       bool         `json:"archived"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// TransferRequest — перевод между счетами. ToAmount нужен только для счетов в разных валютах;
+// если он не указан, сумма зачисления считается по курсу на дату перевода.
+type TransferRequest struct {
+	FromAccountID int64         `json:"from_account_id"`
+	ToAccountID   int64         `json:"to_account_id"`
+	Amount        money.Amount  `json:"amount"`
+	ToAmount      *money.Amount `json:"to_amount,omitempty"`
+	Date          string        `json:"date"`
+	Note          string        `json:"note"`
+}
+
+// Transfer — перевод между счетами: Amount списывается со счёта FromAccountID в его валюте,
+// ToAmount зачисляется на счёт ToAccountID в его валюте.
+type Transfer struct {
+	ID            int64        `json:"id"`
+	UserID        int64        `json:"user_id"`
+	FromAccountID int64        `json:"from_account_id"`
+	ToAccountID   int64        `json:"to_account_id"`
+	Amount        money.Amount `json:"amount"`
+	FromCurrency  string       `json:"from_currency"`
+	ToAmount      money.Amount `json:"to_amount"`
+	ToCurrency    string       `json:"to_currency"`
+	Date          time.Time    `json:"date"`
+	Note          string       `json:"note"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
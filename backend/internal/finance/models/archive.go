@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// ArchiveProfile — профиль пользователя в архиве. Пароль в архив не попадает,
+// email при восстановлении не меняется.
+type ArchiveProfile struct {
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	BaseCurrency string    `json:"base_currency"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ArchiveCategory — категория с подкатегориями. Системные категории и подкатегории
+// при восстановлении сопоставляются с системными по названию, остальные создаются заново.
+type ArchiveCategory struct {
+	Category
+	Subcategories []Subcategory `json:"subcategories"`
+}
+
+// ArchiveGoal — цель с историей взносов.
+type ArchiveGoal struct {
+	GoalResponse
+	Contributions []GoalContribution `json:"contributions"`
+}
+
+// FinanceArchive — данные finance-service в архиве. Идентификаторы в архиве — исходные,
+// при восстановлении все ссылки между записями переназначаются на новые.
+type FinanceArchive struct {
+	Categories   []ArchiveCategory     `json:"categories"`
+	Accounts     []Account             `json:"accounts"`
+	Transactions []TransactionResponse `json:"transactions"`
+	Transfers    []Transfer            `json:"transfers"`
+	Goals        []ArchiveGoal         `json:"goals"`
+	Budgets      []Budget              `json:"budgets"`
+}
+
+// RestoreResult — число восстановленных записей каждого вида.
+type RestoreResult struct {
+	Categories    int `json:"categories"`
+	Subcategories int `json:"subcategories"`
+	Accounts      int `json:"accounts"`
+	Transactions  int `json:"transactions"`
+	Transfers     int `json:"transfers"`
+	Goals         int `json:"goals"`
+	Contributions int `json:"contributions"`
+	Budgets       int `json:"budgets"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"budgetbuddy/pkg/money"
+)
+
+// ImportRow — операция из банковской выписки. Row — номер строки CSV или записи OFX/QIF,
+// начиная с 1. Строка с Error не импортируется, Duplicate означает, что такая транзакция
+// уже есть у пользователя.
+type ImportRow struct {
+	Row         int          `json:"row"`
+	Type        string       `json:"type"`
+	Amount      money.Amount `json:"amount"`
+	Currency    string       `json:"currency"`
+	Date        time.Time    `json:"date"`
+	Description string       `json:"description"`
+	Note        string       `json:"note"`
+	Fingerprint string       `json:"fingerprint"`
+	Duplicate   bool         `json:"duplicate"`
+	Skipped     bool         `json:"skipped"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// ImportResult — результат импорта. При DryRun ничего не сохраняется, а Imported —
+// число строк, которые были бы импортированы.
+type ImportResult struct {
+	DryRun     bool        `json:"dry_run"`
+	Rows       []ImportRow `json:"rows"`
+	Imported   int         `json:"imported"`
+	Duplicates int         `json:"duplicates"`
+	Invalid    int         `json:"invalid"`
+}
+
+// TransactionFingerprint возвращает отпечаток транзакции для поиска дубликатов при импорте:
+// тип, дата, сумма и описание без учёта регистра и лишних пробелов.
+func TransactionFingerprint(txType string, date time.Time, amount money.Amount, description string) string {
+	description = strings.ToLower(strings.Join(strings.Fields(description), " "))
+	sum := sha256.Sum256([]byte(txType + "|" + date.Format("2006-01-02") + "|" + amount.String() + "|" + description))
+	return hex.EncodeToString(sum[:16])
+}
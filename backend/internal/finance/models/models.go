@@ -1,23 +1,32 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"budgetbuddy/pkg/money"
+)
 
 type TransactionRequest struct {
-	Amount        float64  `json:"amount"`
-	CategoryID    int64    `json:"category_id"`
-	SubcategoryID *int64   `json:"subcategory_id,omitempty"`
-	Description   string   `json:"description"`
-	Tags          []string `json:"tags,omitempty"`
-	Date          string   `json:"date"`
-	Note          string   `json:"note"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency,omitempty"`
+	CategoryID    int64        `json:"category_id"`
+	SubcategoryID *int64       `json:"subcategory_id,omitempty"`
+	AccountID     *int64       `json:"account_id,omitempty"`
+	Description   string       `json:"description"`
+	Tags          []string     `json:"tags,omitempty"`
+	Date          string       `json:"date"`
+	Note          string       `json:"note"`
 }
 
 type Transaction struct {
 	ID            int64
 	UserID        int64
-	Amount        float64
+	Type          string
+	Amount        money.Amount
+	Currency      string
 	CategoryID    int64
 	SubcategoryID *int64
+	AccountID     *int64
 	Description   string
 	Tags          []string
 	Date          time.Time
@@ -25,58 +34,178 @@ type Transaction struct {
 }
 
 type TransactionResponse struct {
-	ID            int64     `json:"id"`
-	Amount        float64   `json:"amount"`
-	CategoryID    int64     `json:"category_id"`
-	SubcategoryID *int64    `json:"subcategory_id,omitempty"`
-	Description   string    `json:"description"`
-	Tags          []string  `json:"tags,omitempty"`
-	Date          time.Time `json:"date"`
-	Note          string    `json:"note"`
+	ID            int64        `json:"id"`
+	Type          string       `json:"type,omitempty"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	CategoryID    int64        `json:"category_id"`
+	SubcategoryID *int64       `json:"subcategory_id,omitempty"`
+	AccountID     *int64       `json:"account_id,omitempty"`
+	Description   string       `json:"description"`
+	Tags          []string     `json:"tags,omitempty"`
+	Date          time.Time    `json:"date"`
+	Note          string       `json:"note"`
+}
+
+// TransactionFilter описывает параметры выборки GET /transactions.
+type TransactionFilter struct {
+	Type          string
+	From          *time.Time
+	To            *time.Time
+	CategoryID    *int64
+	SubcategoryID *int64
+	AccountID     *int64
+	Tag           string
+	MinAmount     *money.Amount
+	MaxAmount     *money.Amount
+	Search        string
+	SortBy        string
+	SortDesc      bool
+	Limit         int
+	Cursor        string
+}
+
+type TransactionListResponse struct {
+	Transactions []TransactionResponse `json:"transactions"`
+	NextCursor   string                `json:"next_cursor,omitempty"`
 }
 
 type GoalRequest struct {
-	Name         string  `json:"name"`
-	TargetAmount float64 `json:"target_amount"`
-	Deadline     string  `json:"deadline"`
+	Name         string       `json:"name"`
+	TargetAmount money.Amount `json:"target_amount"`
+	Currency     string       `json:"currency,omitempty"`
+	Deadline     string       `json:"deadline"`
 }
 
+// Goal — цель накоплений. CurrentAmount не хранится, а считается как сумма взносов.
 type Goal struct {
 	ID            int64
 	UserID        int64
 	Name          string
-	TargetAmount  float64
-	CurrentAmount float64
+	TargetAmount  money.Amount
+	CurrentAmount money.Amount
+	Currency      string
 	Deadline      time.Time
 	CreatedAt     time.Time
 }
 
+// GoalContributionRequest — взнос в цель. Если указан ExpenseID или TransferID, сумма и дата
+// берутся из расхода или из зачисления перевода (сумма пересчитывается в валюту цели),
+// и Amount указывать не нужно.
+type GoalContributionRequest struct {
+	Amount     money.Amount `json:"amount"`
+	Date       string       `json:"date,omitempty"`
+	Note       string       `json:"note"`
+	ExpenseID  *int64       `json:"expense_id,omitempty"`
+	TransferID *int64       `json:"transfer_id,omitempty"`
+}
+
+// GoalContribution — взнос в цель в валюте цели. Отрицательная сумма означает снятие.
+type GoalContribution struct {
+	ID         int64        `json:"id"`
+	GoalID     int64        `json:"goal_id"`
+	UserID     int64        `json:"user_id"`
+	Amount     money.Amount `json:"amount"`
+	Currency   string       `json:"currency"`
+	Date       time.Time    `json:"date"`
+	Note       string       `json:"note"`
+	ExpenseID  *int64       `json:"expense_id,omitempty"`
+	TransferID *int64       `json:"transfer_id,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
 type GoalResponse struct {
-	ID            int64     `json:"id"`
-	Name          string    `json:"name"`
-	TargetAmount  float64   `json:"target_amount"`
-	CurrentAmount float64   `json:"current_amount"`
-	Deadline      time.Time `json:"deadline"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            int64        `json:"id"`
+	Name          string       `json:"name"`
+	TargetAmount  money.Amount `json:"target_amount"`
+	CurrentAmount money.Amount `json:"current_amount"`
+	Currency      string       `json:"currency"`
+	Deadline      time.Time    `json:"deadline"`
+	CreatedAt     time.Time    `json:"created_at"`
 }
 
+// Category — категория доходов или расходов. System означает системную категорию,
+// общую для всех пользователей; её нельзя переименовать, архивировать или удалить.
 type Category struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	Type string `json:"type"`
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	System   bool   `json:"system"`
+	Archived bool   `json:"archived"`
+}
+
+// CategoryUpdateRequest — переименование и (раз)архивирование категории; пустые поля не меняются.
+type CategoryUpdateRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+type CategoryMergeRequest struct {
+	TargetID int64 `json:"target_id"`
 }
 
 type Subcategory struct {
 	ID         int64  `json:"id"`
 	CategoryID int64  `json:"category_id"`
 	Name       string `json:"name"`
+	System     bool   `json:"system"`
 }
 
+// Budget — лимит расходов по категории на месяц. При включённом Rollover к лимиту
+// добавляется RolloverAmount — остаток (или перерасход со знаком минус) предыдущего месяца,
+// вычисляемый при чтении. Бюджет по шаблону, не сохранённый в месяце отдельно, имеет ID 0.
 type Budget struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"user_id"`
-	CategoryID int64     `json:"category_id"`
-	Amount     float64   `json:"amount"`
-	Month      string    `json:"month"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID             int64        `json:"id"`
+	UserID         int64        `json:"user_id"`
+	CategoryID     int64        `json:"category_id"`
+	Amount         money.Amount `json:"amount"`
+	Currency       string       `json:"currency"`
+	Month          string       `json:"month"`
+	Rollover       bool         `json:"rollover"`
+	RolloverAmount money.Amount `json:"rollover_amount"`
+	TemplateID     *int64       `json:"template_id,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// BudgetTemplate — шаблон бюджета, который задаёт бюджет категории в каждом месяце
+// начиная со StartMonth, если в месяце нет отдельно сохранённого бюджета.
+type BudgetTemplate struct {
+	ID         int64        `json:"id"`
+	UserID     int64        `json:"user_id"`
+	CategoryID int64        `json:"category_id"`
+	Amount     money.Amount `json:"amount"`
+	Currency   string       `json:"currency"`
+	Rollover   bool         `json:"rollover"`
+	StartMonth string       `json:"start_month"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type BudgetCopyRequest struct {
+	FromMonth string `json:"from_month"`
+	ToMonth   string `json:"to_month"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// BudgetStatus — исполнение бюджета за месяц. Budgeted — лимит с учётом перенесённого остатка Rollover,
+// расходы пересчитаны в валюту бюджета, Percentage — доля потраченного от лимита в процентах.
+type BudgetStatus struct {
+	BudgetID     int64        `json:"budget_id"`
+	CategoryID   int64        `json:"category_id"`
+	CategoryName string       `json:"category_name"`
+	Month        string       `json:"month"`
+	Currency     string       `json:"currency"`
+	Budgeted     money.Amount `json:"budgeted"`
+	Rollover     money.Amount `json:"rollover_amount"`
+	Spent        money.Amount `json:"spent"`
+	Remaining    money.Amount `json:"remaining"`
+	Percentage   float64      `json:"percentage"`
+}
+
+// ExchangeRate — курс: сколько единиц QuoteCurrency стоит одна единица BaseCurrency на дату Date.
+// Курс хранится десятичной строкой, чтобы не терять точность.
+type ExchangeRate struct {
+	BaseCurrency  string    `json:"base"`
+	QuoteCurrency string    `json:"quote"`
+	Date          time.Time `json:"date"`
+	Rate          string    `json:"rate"`
 }
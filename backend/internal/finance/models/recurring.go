@@ -0,0 +1,132 @@
+package models
+
+import (
+	"time"
+
+	"budgetbuddy/pkg/money"
+)
+
+// Периодичность повторяющихся транзакций
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// ValidFrequency проверяет, что периодичность поддерживается.
+func ValidFrequency(frequency string) bool {
+	switch frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+		return true
+	}
+	return false
+}
+
+type RecurringRuleRequest struct {
+	Type          string       `json:"type"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency,omitempty"`
+	CategoryID    int64        `json:"category_id"`
+	SubcategoryID *int64       `json:"subcategory_id,omitempty"`
+	Description   string       `json:"description"`
+	Tags          []string     `json:"tags,omitempty"`
+	Note          string       `json:"note"`
+	Frequency     string       `json:"frequency"`
+	Interval      int          `json:"interval,omitempty"`
+	StartDate     string       `json:"start_date"`
+	EndDate       string       `json:"end_date,omitempty"`
+	Count         *int         `json:"count,omitempty"`
+}
+
+// RecurringRule описывает расписание в духе RRULE: транзакция повторяется каждые
+// Interval единиц Frequency начиная со StartDate, пока не достигнуты EndDate или Count.
+type RecurringRule struct {
+	ID            int64
+	UserID        int64
+	Type          string
+	Amount        money.Amount
+	Currency      string
+	CategoryID    int64
+	SubcategoryID *int64
+	Description   string
+	Tags          []string
+	Note          string
+	Frequency     string
+	Interval      int
+	StartDate     time.Time
+	EndDate       *time.Time
+	Count         *int
+	// OccurrenceCount — число уже пройденных повторений, NextDate — дата следующего (nil, если правило исчерпано)
+	OccurrenceCount int
+	NextDate        *time.Time
+	CreatedAt       time.Time
+}
+
+type RecurringRuleResponse struct {
+	ID              int64        `json:"id"`
+	Type            string       `json:"type"`
+	Amount          money.Amount `json:"amount"`
+	Currency        string       `json:"currency"`
+	CategoryID      int64        `json:"category_id"`
+	SubcategoryID   *int64       `json:"subcategory_id,omitempty"`
+	Description     string       `json:"description"`
+	Tags            []string     `json:"tags,omitempty"`
+	Note            string       `json:"note"`
+	Frequency       string       `json:"frequency"`
+	Interval        int          `json:"interval"`
+	StartDate       time.Time    `json:"start_date"`
+	EndDate         *time.Time   `json:"end_date,omitempty"`
+	Count           *int         `json:"count,omitempty"`
+	OccurrenceCount int          `json:"occurrence_count"`
+	NextDate        *time.Time   `json:"next_date,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// Occurrence возвращает дату n-го повторения (с нуля). Для ежемесячных и ежегодных
+// правил день привязан к дню StartDate и ограничивается концом месяца (31 января -> 28 февраля -> 31 марта).
+func (r *RecurringRule) Occurrence(n int) time.Time {
+	step := n * r.Interval
+	switch r.Frequency {
+	case FrequencyDaily:
+		return r.StartDate.AddDate(0, 0, step)
+	case FrequencyWeekly:
+		return r.StartDate.AddDate(0, 0, 7*step)
+	case FrequencyYearly:
+		return addMonthsClamped(r.StartDate, 12*step)
+	default:
+		return addMonthsClamped(r.StartDate, step)
+	}
+}
+
+// Finished сообщает, что повторение с номером n и датой date выходит за пределы правила.
+func (r *RecurringRule) Finished(n int, date time.Time) bool {
+	if r.Count != nil && n >= *r.Count {
+		return true
+	}
+	return r.EndDate != nil && date.After(*r.EndDate)
+}
+
+// NextAfter возвращает номер и дату первого повторения строго после after.
+// Если такого повторения нет, ok равен false.
+func (r *RecurringRule) NextAfter(after time.Time) (n int, date time.Time, ok bool) {
+	for n = 0; ; n++ {
+		date = r.Occurrence(n)
+		if r.Finished(n, date) {
+			return n, date, false
+		}
+		if date.After(after) {
+			return n, date, true
+		}
+	}
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRecurringRuleOccurrence(t *testing.T) {
+	monthly := &RecurringRule{Frequency: FrequencyMonthly, Interval: 1, StartDate: date(2025, time.January, 31)}
+	assert.Equal(t, date(2025, time.January, 31), monthly.Occurrence(0))
+	assert.Equal(t, date(2025, time.February, 28), monthly.Occurrence(1))
+	assert.Equal(t, date(2025, time.March, 31), monthly.Occurrence(2))
+	assert.Equal(t, date(2025, time.April, 30), monthly.Occurrence(3))
+
+	weekly := &RecurringRule{Frequency: FrequencyWeekly, Interval: 2, StartDate: date(2025, time.July, 1)}
+	assert.Equal(t, date(2025, time.July, 29), weekly.Occurrence(2))
+
+	yearly := &RecurringRule{Frequency: FrequencyYearly, Interval: 1, StartDate: date(2024, time.February, 29)}
+	assert.Equal(t, date(2025, time.February, 28), yearly.Occurrence(1))
+	assert.Equal(t, date(2028, time.February, 29), yearly.Occurrence(4))
+}
+
+func TestRecurringRuleLimits(t *testing.T) {
+	count := 3
+	rule := &RecurringRule{Frequency: FrequencyDaily, Interval: 1, StartDate: date(2025, time.July, 1), Count: &count}
+	assert.False(t, rule.Finished(2, rule.Occurrence(2)))
+	assert.True(t, rule.Finished(3, rule.Occurrence(3)))
+
+	end := date(2025, time.July, 10)
+	rule = &RecurringRule{Frequency: FrequencyWeekly, Interval: 1, StartDate: date(2025, time.July, 1), EndDate: &end}
+	assert.False(t, rule.Finished(1, rule.Occurrence(1)))
+	assert.True(t, rule.Finished(2, rule.Occurrence(2)))
+}
+
+func TestRecurringRuleNextAfter(t *testing.T) {
+	rule := &RecurringRule{Frequency: FrequencyMonthly, Interval: 1, StartDate: date(2025, time.January, 15)}
+	n, next, ok := rule.NextAfter(date(2025, time.March, 15))
+	assert.True(t, ok)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, date(2025, time.April, 15), next)
+
+	count := 2
+	rule.Count = &count
+	_, _, ok = rule.NextAfter(date(2025, time.March, 15))
+	assert.False(t, ok)
+}
@@ -0,0 +1,115 @@
+// Package rates загружает курсы валют из локальных файлов CSV и JSON.
+//
+// CSV: строки вида "date,base,quote,rate", например "2025-07-01,USD,RUB,78.5".
+// Строка заголовка необязательна.
+//
+// JSON: массив объектов {"date": "2025-07-01", "base": "USD", "quote": "RUB", "rate": 78.5}.
+//
+// Курс означает, сколько единиц quote стоит одна единица base на указанную дату.
+package rates
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+)
+
+// LoadFile читает курсы из файла; формат определяется по расширению (.csv или .json).
+func LoadFile(path string) ([]models.ExchangeRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ParseCSV(f)
+	case ".json":
+		return ParseJSON(f)
+	default:
+		return nil, fmt.Errorf("unsupported exchange rates file %q, use .csv or .json", path)
+	}
+}
+
+// ParseCSV разбирает курсы в формате CSV.
+func ParseCSV(r io.Reader) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	var rates []models.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+		rate, err := newRate(record[0], record[1], record[2], record[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// ParseJSON разбирает курсы в формате JSON.
+func ParseJSON(r io.Reader) ([]models.ExchangeRate, error) {
+	var records []struct {
+		Date  string      `json:"date"`
+		Base  string      `json:"base"`
+		Quote string      `json:"quote"`
+		Rate  json.Number `json:"rate"`
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&records); err != nil {
+		return nil, err
+	}
+
+	rates := make([]models.ExchangeRate, 0, len(records))
+	for i, rec := range records {
+		rate, err := newRate(rec.Date, rec.Base, rec.Quote, rec.Rate.String())
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+func newRate(date, base, quote, rate string) (models.ExchangeRate, error) {
+	d, err := time.Parse("2006-01-02", strings.TrimSpace(date))
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", date)
+	}
+	base, quote = strings.TrimSpace(base), strings.TrimSpace(quote)
+	if !money.ValidCurrency(base) || !money.ValidCurrency(quote) || base == quote {
+		return models.ExchangeRate{}, fmt.Errorf("invalid currency pair %s/%s", base, quote)
+	}
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(rate))
+	if !ok || value.Sign() <= 0 {
+		return models.ExchangeRate{}, fmt.Errorf("invalid rate %q, must be a positive decimal", rate)
+	}
+	return models.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Date:          d,
+		Rate:          value.FloatString(10),
+	}, nil
+}
@@ -0,0 +1,40 @@
+package rates
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := "date,base,quote,rate\n2025-07-01,USD,RUB,78.5\n2025-07-02, EUR, RUB, 91.2345\n"
+	rates, err := ParseCSV(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, rates, 2)
+	assert.Equal(t, "USD", rates[0].BaseCurrency)
+	assert.Equal(t, "RUB", rates[0].QuoteCurrency)
+	assert.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), rates[0].Date)
+	assert.Equal(t, "78.5000000000", rates[0].Rate)
+	assert.Equal(t, "EUR", rates[1].BaseCurrency)
+
+	_, err = ParseCSV(strings.NewReader("2025-07-01,USD,RUB,-1\n"))
+	assert.Error(t, err)
+
+	_, err = ParseCSV(strings.NewReader("2025-07-01,USD,usd,1\n"))
+	assert.Error(t, err)
+}
+
+func TestParseJSON(t *testing.T) {
+	input := `[{"date": "2025-07-01", "base": "USD", "quote": "RUB", "rate": 78.5},
+		{"date": "2025-07-01", "base": "EUR", "quote": "USD", "rate": "1.17"}]`
+	rates, err := ParseJSON(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, rates, 2)
+	assert.Equal(t, "1.1700000000", rates[1].Rate)
+
+	_, err = ParseJSON(strings.NewReader(`[{"date": "07/01/2025", "base": "USD", "quote": "RUB", "rate": 1}]`))
+	assert.Error(t, err)
+}
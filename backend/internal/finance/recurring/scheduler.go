@@ -0,0 +1,81 @@
+// Package recurring в фоне создаёт доходы и расходы по повторяющимся правилам.
+package recurring
+
+import (
+	"context"
+	"time"
+
+	"budgetbuddy/internal/finance/metrics"
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+)
+
+// Repository — методы репозитория, которые использует планировщик.
+type Repository interface {
+	GetDueRecurringRuleIDs(ctx context.Context, today time.Time) ([]int64, error)
+	MaterializeNextOccurrence(ctx context.Context, ruleID int64, today time.Time) (*models.Transaction, bool, error)
+}
+
+// Scheduler периодически находит правила с наступившими повторениями и создаёт по ним транзакции.
+type Scheduler struct {
+	repo     Repository
+	interval time.Duration
+}
+
+func NewScheduler(repo Repository, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		repo:     repo,
+		interval: interval,
+	}
+}
+
+// Run обрабатывает правила сразу и затем каждые interval, пока не будет отменён ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	logger.Info("Starting recurring transactions scheduler, interval ", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.RunOnce(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Recurring transactions scheduler stopped")
+			return
+		case <-ticker.C:
+			s.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce создаёт все повторения, наступившие к текущей дате (UTC).
+// Пропущенные за время простоя повторения досоздаются по одному, начиная с самого раннего.
+func (s *Scheduler) RunOnce(ctx context.Context) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	ids, err := s.repo.GetDueRecurringRuleIDs(ctx, today)
+	if err != nil {
+		logger.Error("Failed to get due recurring rules: ", err)
+		return
+	}
+
+	for _, id := range ids {
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			created, processed, err := s.repo.MaterializeNextOccurrence(ctx, id, today)
+			if err != nil {
+				logger.Error("Failed to materialize recurring rule ", id, ": ", err)
+				break
+			}
+			if created != nil {
+				metrics.TransactionsCreated.Inc(created.Type, metrics.SourceRecurring)
+				logger.Info("Created recurring ", created.Type, " ", created.ID, " for rule ", id, " on ", created.Date.Format("2006-01-02"))
+			}
+			if !processed {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/money"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// accountColumns — столбцы счёта a. Остаток считается в валюте счёта: транзакции счёта
+// всегда в его валюте, а движения по переводам хранятся уже пересчитанными.
+const accountColumns = `a.id, a.user_id, a.name, a.type, a.currency, a.opening_balance,
+	a.opening_balance
+		+ COALESCE((SELECT SUM(i.amount) FROM incomes i WHERE i.account_id = a.id), 0)
+		- COALESCE((SELECT SUM(e.amount) FROM expenses e WHERE e.account_id = a.id), 0)
+		+ COALESCE((SELECT SUM(l.amount) FROM transfer_legs l WHERE l.account_id = a.id), 0),
+	a.archived, a.created_at`
+
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var a models.Account
+	err := row.Scan(&a.ID, &a.UserID, &a.Name, &a.Type, &a.Currency, &a.OpeningBalance, &a.Balance, &a.Archived, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// validateTransactionAccount проверяет, что счёт транзакции принадлежит пользователю и что
+// валюта транзакции совпадает с валютой счёта. Пустая валюта заменяется валютой счёта.
+// На архивный счёт транзакцию записать нельзя, кроме той, что уже на нём (currentAccountID).
+func validateTransactionAccount(ctx context.Context, q querier, userID int64, tx *models.Transaction, currentAccountID *int64) error {
+	if tx.AccountID == nil {
+		return nil
+	}
+	var currency string
+	var archived bool
+	err := q.QueryRowContext(ctx, `SELECT currency, archived FROM accounts WHERE id = $1 AND user_id = $2`,
+		*tx.AccountID, userID).Scan(&currency, &archived)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("account with id %d does not exist: %w", *tx.AccountID, ErrInvalidReference)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check transaction account: ", err)
+		return err
+	}
+	if archived && (currentAccountID == nil || *currentAccountID != *tx.AccountID) {
+		return fmt.Errorf("account with id %d is archived: %w", *tx.AccountID, ErrInvalidReference)
+	}
+	if tx.Currency == "" {
+		tx.Currency = currency
+	}
+	if tx.Currency != currency {
+		return fmt.Errorf("currency %s does not match account currency %s: %w", tx.Currency, currency, ErrInvalidReference)
+	}
+	return nil
+}
+
+func (r *Repository) SaveAccount(ctx context.Context, account *models.Account) (int64, error) {
+	query := `
+		INSERT INTO accounts (user_id, name, type, currency, opening_balance, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, name) DO NOTHING
+		RETURNING id`
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, account.UserID, account.Name, account.Type, account.Currency,
+		account.OpeningBalance, account.CreatedAt).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("account %q already exists: %w", account.Name, ErrAlreadyExists)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save account: ", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetAccounts возвращает счета пользователя с текущими остатками. Архивные счета
+// возвращаются только при includeArchived.
+func (r *Repository) GetAccounts(ctx context.Context, userID int64, includeArchived bool) ([]models.Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts a
+		WHERE a.user_id = $1 AND ($2 OR NOT a.archived)
+		ORDER BY a.id`
+	rows, err := r.db.QueryContext(ctx, query, userID, includeArchived)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get accounts: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan account: ", err)
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, nil
+}
+
+func (r *Repository) GetAccount(ctx context.Context, id, userID int64) (*models.Account, error) {
+	account, err := scanAccount(r.db.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM accounts a WHERE a.id = $1 AND a.user_id = $2`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no account found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get account: ", err)
+		return nil, err
+	}
+	return account, nil
+}
+
+// UpdateAccount переименовывает счёт, меняет начальный остаток и/или признак архивного.
+// Архивный счёт скрыт из списка, но его транзакции и переводы сохраняются.
+func (r *Repository) UpdateAccount(ctx context.Context, userID, id int64, req *models.AccountUpdateRequest) (*models.Account, error) {
+	if req.Name != nil {
+		var taken bool
+		err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM accounts WHERE user_id = $1 AND name = $2 AND id <> $3)`,
+			userID, *req.Name, id).Scan(&taken)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to check account name: ", err)
+			return nil, err
+		}
+		if taken {
+			return nil, fmt.Errorf("account %q already exists: %w", *req.Name, ErrAlreadyExists)
+		}
+	}
+
+	query := `
+		UPDATE accounts
+		SET name = COALESCE($1, name), opening_balance = COALESCE($2, opening_balance), archived = COALESCE($3, archived)
+		WHERE id = $4 AND user_id = $5`
+	result, err := r.db.ExecContext(ctx, query, req.Name, req.OpeningBalance, req.Archived, id, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to update account: ", err)
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("no account found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	return r.GetAccount(ctx, id, userID)
+}
+
+// DeleteAccount удаляет счёт без транзакций и переводов. Если счёт используется,
+// возвращается ErrConflict: такой счёт можно только архивировать.
+func (r *Repository) DeleteAccount(ctx context.Context, userID, id int64) error {
+	query := `
+		DELETE FROM accounts a
+		WHERE a.id = $1 AND a.user_id = $2
+		AND NOT EXISTS (SELECT 1 FROM incomes WHERE account_id = a.id)
+		AND NOT EXISTS (SELECT 1 FROM expenses WHERE account_id = a.id)
+		AND NOT EXISTS (SELECT 1 FROM transfer_legs WHERE account_id = a.id)`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to delete account: ", err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		if _, err := r.GetAccount(ctx, id, userID); err != nil {
+			return err
+		}
+		return fmt.Errorf("account %d is in use: %w", id, ErrConflict)
+	}
+	return nil
+}
+
+// SaveTransfer записывает перевод между счетами пользователя как пару движений: списание
+// Amount со счёта-источника и зачисление ToAmount на счёт-получатель. Обе записи создаются
+// в одной транзакции БД. Если счета в разных валютах и ToAmount не указан (равен нулю),
+// сумма зачисления считается по курсу на дату перевода.
+func (r *Repository) SaveTransfer(ctx context.Context, transfer *models.Transfer) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, side := range []struct {
+		id       int64
+		currency *string
+	}{
+		{transfer.FromAccountID, &transfer.FromCurrency},
+		{transfer.ToAccountID, &transfer.ToCurrency},
+	} {
+		var archived bool
+		err = tx.QueryRowContext(ctx, `SELECT currency, archived FROM accounts WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+			side.id, transfer.UserID).Scan(side.currency, &archived)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("account with id %d does not exist: %w", side.id, ErrInvalidReference)
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to lock transfer account: ", err)
+			return err
+		}
+		if archived {
+			return fmt.Errorf("account with id %d is archived: %w", side.id, ErrInvalidReference)
+		}
+	}
+
+	switch {
+	case transfer.FromCurrency == transfer.ToCurrency:
+		if transfer.ToAmount != 0 && transfer.ToAmount != transfer.Amount {
+			return fmt.Errorf("to_amount must equal amount for accounts in the same currency: %w", ErrInvalidReference)
+		}
+		transfer.ToAmount = transfer.Amount
+	case transfer.ToAmount == 0:
+		var amount sql.Null[money.Amount]
+		err = tx.QueryRowContext(ctx, `SELECT ROUND($1::numeric * `+exchangeRateSQL("$2", "$3::date", "$4")+`, 2)`,
+			transfer.Amount, transfer.FromCurrency, transfer.Date, transfer.ToCurrency).Scan(&amount)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to convert transfer amount: ", err)
+			return err
+		}
+		if !amount.Valid {
+			return fmt.Errorf("no exchange rate from %s to %s on %s: %w",
+				transfer.FromCurrency, transfer.ToCurrency, transfer.Date.Format("2006-01-02"), ErrMissingExchangeRate)
+		}
+		transfer.ToAmount = amount.V
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO transfers (user_id, date, note, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		transfer.UserID, transfer.Date, transfer.Note, transfer.CreatedAt).Scan(&transfer.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save transfer: ", err)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO transfer_legs (transfer_id, account_id, amount) VALUES ($1, $2, $3), ($1, $4, $5)`,
+		transfer.ID, transfer.FromAccountID, -transfer.Amount, transfer.ToAccountID, transfer.ToAmount)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save transfer legs: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit transfer: ", err)
+		return err
+	}
+	return nil
+}
+
+// GetTransfers возвращает переводы пользователя, начиная с последних. Если accountID
+// не nil, возвращаются только переводы с участием этого счёта.
+func (r *Repository) GetTransfers(ctx context.Context, userID int64, accountID *int64) ([]models.Transfer, error) {
+	query := `
+		SELECT t.id, t.user_id, f.account_id, -f.amount, fa.currency, d.account_id, d.amount, da.currency, t.date, t.note, t.created_at
+		FROM transfers t
+		JOIN transfer_legs f ON f.transfer_id = t.id AND f.amount < 0
+		JOIN accounts fa ON fa.id = f.account_id
+		JOIN transfer_legs d ON d.transfer_id = t.id AND d.amount > 0
+		JOIN accounts da ON da.id = d.account_id
+		WHERE t.user_id = $1 AND ($2::bigint IS NULL OR $2 IN (f.account_id, d.account_id))
+		ORDER BY t.date DESC, t.id DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID, accountID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get transfers: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.Transfer
+	for rows.Next() {
+		var t models.Transfer
+		err := rows.Scan(&t.ID, &t.UserID, &t.FromAccountID, &t.Amount, &t.FromCurrency, &t.ToAccountID, &t.ToAmount,
+			&t.ToCurrency, &t.Date, &t.Note, &t.CreatedAt)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan transfer: ", err)
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, nil
+}
+
+// DeleteTransfer удаляет перевод вместе с обоими движениями и привязанными к нему взносами в цели.
+func (r *Repository) DeleteTransfer(ctx context.Context, id, userID int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM transfers WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to delete transfer: ", err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no transfer found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	return nil
+}
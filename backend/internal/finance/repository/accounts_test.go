@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAccounts(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	created := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT a.id, .*incomes i WHERE i.account_id = a.id.*expenses e WHERE e.account_id = a.id.*transfer_legs l WHERE l.account_id = a.id.*FROM accounts a\s+WHERE a.user_id = \$1 AND \(\$2 OR NOT a.archived\)`).
+		WithArgs(int64(1), false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "type", "currency", "opening_balance", "balance", "archived", "created_at"}).
+			AddRow(1, 1, "Wallet", "cash", "RUB", "1000.00", "1250.50", false, created).
+			AddRow(2, 1, "Savings", "savings", "USD", "0.00", "300.00", false, created))
+
+	accounts, err := repo.GetAccounts(context.Background(), 1, false)
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, money.MustParse("1250.50"), accounts[0].Balance)
+	assert.Equal(t, "USD", accounts[1].Currency)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAccount(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("Unused Account", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM accounts a\s+WHERE a.id = \$1 AND a.user_id = \$2`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.DeleteAccount(context.Background(), 1, 3))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Account In Use", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM accounts a`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT a.id, .* FROM accounts a WHERE a.id = \$1 AND a.user_id = \$2`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "type", "currency", "opening_balance", "balance", "archived", "created_at"}).
+				AddRow(3, 1, "Card", "card", "RUB", "0.00", "10.00", false, time.Now()))
+
+		assert.ErrorIs(t, repo.DeleteAccount(context.Background(), 1, 3), ErrConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSaveTransfer(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	date := time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC)
+
+	expectAccounts := func(fromCurrency, toCurrency string) {
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts WHERE id = \$1 AND user_id = \$2 FOR UPDATE`).
+			WithArgs(int64(1), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow(fromCurrency, false))
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts WHERE id = \$1 AND user_id = \$2 FOR UPDATE`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow(toCurrency, false))
+	}
+
+	t.Run("Same Currency", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectAccounts("RUB", "RUB")
+		mock.ExpectQuery(`INSERT INTO transfers \(user_id, date, note, created_at\) VALUES \(\$1, \$2, \$3, \$4\) RETURNING id`).
+			WithArgs(int64(1), date, "To savings", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectExec(`INSERT INTO transfer_legs \(transfer_id, account_id, amount\) VALUES \(\$1, \$2, \$3\), \(\$1, \$4, \$5\)`).
+			WithArgs(int64(8), int64(1), "-500.00", int64(2), "500.00").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		transfer := &models.Transfer{UserID: 1, FromAccountID: 1, ToAccountID: 2, Amount: money.MustParse("500"),
+			Date: date, Note: "To savings", CreatedAt: time.Now()}
+		require.NoError(t, repo.SaveTransfer(context.Background(), transfer))
+		assert.Equal(t, int64(8), transfer.ID)
+		assert.Equal(t, money.MustParse("500"), transfer.ToAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Converted By Rate", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectAccounts("USD", "RUB")
+		mock.ExpectQuery(`SELECT ROUND\(\$1::numeric \* \(CASE WHEN \$2 = \$4 THEN 1 ELSE .*exchange_rates`).
+			WithArgs("100.00", "USD", date, "RUB").
+			WillReturnRows(sqlmock.NewRows([]string{"amount"}).AddRow("9050.00"))
+		mock.ExpectQuery(`INSERT INTO transfers`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectExec(`INSERT INTO transfer_legs`).
+			WithArgs(int64(9), int64(1), "-100.00", int64(2), "9050.00").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		transfer := &models.Transfer{UserID: 1, FromAccountID: 1, ToAccountID: 2, Amount: money.MustParse("100"), Date: date}
+		require.NoError(t, repo.SaveTransfer(context.Background(), transfer))
+		assert.Equal(t, money.MustParse("9050"), transfer.ToAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing Rate", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectAccounts("USD", "RUB")
+		mock.ExpectQuery(`SELECT ROUND`).
+			WillReturnRows(sqlmock.NewRows([]string{"amount"}).AddRow(nil))
+		mock.ExpectRollback()
+
+		transfer := &models.Transfer{UserID: 1, FromAccountID: 1, ToAccountID: 2, Amount: money.MustParse("100"), Date: date}
+		assert.ErrorIs(t, repo.SaveTransfer(context.Background(), transfer), ErrMissingExchangeRate)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Foreign Account", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts`).
+			WithArgs(int64(1), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}))
+		mock.ExpectRollback()
+
+		transfer := &models.Transfer{UserID: 1, FromAccountID: 1, ToAccountID: 2, Amount: money.MustParse("100"), Date: date}
+		assert.ErrorIs(t, repo.SaveTransfer(context.Background(), transfer), ErrInvalidReference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Archived Account", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts`).
+			WithArgs(int64(1), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow("RUB", false))
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow("RUB", true))
+		mock.ExpectRollback()
+
+		transfer := &models.Transfer{UserID: 1, FromAccountID: 1, ToAccountID: 2, Amount: money.MustParse("100"), Date: date}
+		err := repo.SaveTransfer(context.Background(), transfer)
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.Contains(t, err.Error(), "account with id 2 is archived")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSaveExpenseAccount(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	expectCategory := func() {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	}
+
+	t.Run("Currency From Account", func(t *testing.T) {
+		expectCategory()
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(int64(4), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow("EUR", false))
+		mock.ExpectQuery(`INSERT INTO expenses`).
+			WithArgs(int64(1), "12.00", "EUR", int64(2), nil, int64(4), "", sqlmock.AnyArg(), sqlmock.AnyArg(), "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+		tx := &models.Transaction{Amount: money.MustParse("12"), CategoryID: 2, AccountID: int64Ptr(4), Date: time.Now()}
+		id, err := repo.SaveExpense(context.Background(), 1, tx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), id)
+		assert.Equal(t, "EUR", tx.Currency)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Currency Mismatch", func(t *testing.T) {
+		expectCategory()
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts`).
+			WithArgs(int64(4), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow("EUR", false))
+
+		tx := &models.Transaction{Amount: money.MustParse("12"), Currency: "RUB", CategoryID: 2, AccountID: int64Ptr(4), Date: time.Now()}
+		_, err := repo.SaveExpense(context.Background(), 1, tx)
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Archived Account", func(t *testing.T) {
+		expectCategory()
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts`).
+			WithArgs(int64(4), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow("EUR", true))
+
+		tx := &models.Transaction{Amount: money.MustParse("12"), CategoryID: 2, AccountID: int64Ptr(4), Date: time.Now()}
+		_, err := repo.SaveExpense(context.Background(), 1, tx)
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update Keeps Archived Account", func(t *testing.T) {
+		// Уже записанную на архивный счёт транзакцию можно исправить, не меняя счёт
+		expectCategory()
+		mock.ExpectQuery(`SELECT account_id FROM expenses WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(int64(5), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(4))
+		mock.ExpectQuery(`SELECT currency, archived FROM accounts`).
+			WithArgs(int64(4), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "archived"}).AddRow("EUR", true))
+		mock.ExpectQuery(`UPDATE expenses`).
+			WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("EUR"))
+
+		tx := &models.Transaction{ID: 5, Amount: money.MustParse("12"), CategoryID: 2, AccountID: int64Ptr(4), Date: time.Now()}
+		require.NoError(t, repo.UpdateTransaction(context.Background(), 1, "expense", tx))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,444 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// GetArchive собирает данные пользователя для архива: все доступные категории с подкатегориями,
+// счета, переводы, цели со взносами и бюджеты. Транзакции в результат не входят — их
+// выгружает ExportTransactions, чтобы не держать в памяти.
+func (r *Repository) GetArchive(ctx context.Context, userID int64) (*models.FinanceArchive, error) {
+	var data models.FinanceArchive
+
+	categoryIndex := make(map[int64]int)
+	err := r.ExportCategories(ctx, userID, func(c *models.Category) error {
+		categoryIndex[c.ID] = len(data.Categories)
+		data.Categories = append(data.Categories, models.ArchiveCategory{Category: *c, Subcategories: []models.Subcategory{}})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, category_id, name, user_id IS NULL FROM subcategories
+		WHERE `+visibleToUserSQL("subcategories", "$1")+`
+		ORDER BY category_id, id`, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get subcategories: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s models.Subcategory
+		if err := rows.Scan(&s.ID, &s.CategoryID, &s.Name, &s.System); err != nil {
+			logger.ErrorContext(ctx, "Failed to scan subcategory: ", err)
+			return nil, err
+		}
+		if i, ok := categoryIndex[s.CategoryID]; ok {
+			data.Categories[i].Subcategories = append(data.Categories[i].Subcategories, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if data.Accounts, err = r.GetAccounts(ctx, userID, true); err != nil {
+		return nil, err
+	}
+	if data.Transfers, err = r.GetTransfers(ctx, userID, nil); err != nil {
+		return nil, err
+	}
+
+	goalIndex := make(map[int64]int)
+	err = r.ExportGoals(ctx, userID, func(g *models.Goal) error {
+		goalIndex[g.ID] = len(data.Goals)
+		data.Goals = append(data.Goals, models.ArchiveGoal{
+			GoalResponse: models.GoalResponse{
+				ID:            g.ID,
+				Name:          g.Name,
+				TargetAmount:  g.TargetAmount,
+				CurrentAmount: g.CurrentAmount,
+				Currency:      g.Currency,
+				Deadline:      g.Deadline,
+				CreatedAt:     g.CreatedAt,
+			},
+			Contributions: []models.GoalContribution{},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contributionRows, err := r.db.QueryContext(ctx, `
+		SELECT `+goalContributionColumns+`
+		FROM goal_contributions gc JOIN goals g ON g.id = gc.goal_id
+		WHERE g.user_id = $1
+		ORDER BY gc.goal_id, gc.date, gc.id`, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get goal contributions: ", err)
+		return nil, err
+	}
+	defer contributionRows.Close()
+	for contributionRows.Next() {
+		c, err := scanGoalContribution(contributionRows)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan goal contribution: ", err)
+			return nil, err
+		}
+		if i, ok := goalIndex[c.GoalID]; ok {
+			data.Goals[i].Contributions = append(data.Goals[i].Contributions, *c)
+		}
+	}
+	if err := contributionRows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = r.ExportBudgets(ctx, userID, "", "", func(b *models.Budget) error {
+		data.Budgets = append(data.Budgets, *b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// archiveIDs сопоставляет идентификаторы записей в архиве с идентификаторами созданных записей.
+type archiveIDs map[int64]int64
+
+func (m archiveIDs) get(kind string, id int64) (int64, error) {
+	newID, ok := m[id]
+	if !ok {
+		return 0, fmt.Errorf("archive references unknown %s %d: %w", kind, id, ErrInvalidReference)
+	}
+	return newID, nil
+}
+
+func (m archiveIDs) optional(kind string, id *int64) (*int64, error) {
+	if id == nil {
+		return nil, nil
+	}
+	newID, err := m.get(kind, *id)
+	if err != nil {
+		return nil, err
+	}
+	return &newID, nil
+}
+
+// archiveRestore хранит состояние восстановления архива внутри одной транзакции БД.
+// Системные категории и подкатегории сопоставляются лениво, при первой ссылке на них,
+// чтобы не создавать у пользователя копии неиспользуемых системных записей.
+type archiveRestore struct {
+	ctx    context.Context
+	tx     *sql.Tx
+	userID int64
+	result models.RestoreResult
+
+	categories, subcategories         archiveIDs
+	systemCategories                  map[int64]models.Category
+	systemSubcategories               map[int64]models.Subcategory
+	accounts, transactions, transfers archiveIDs
+}
+
+// category возвращает новый id категории из архива.
+func (s *archiveRestore) category(id int64) (int64, error) {
+	if newID, ok := s.categories[id]; ok {
+		return newID, nil
+	}
+	c, ok := s.systemCategories[id]
+	if !ok {
+		return s.categories.get("category", id)
+	}
+	var newID int64
+	err := s.tx.QueryRowContext(s.ctx, `SELECT id FROM categories WHERE user_id IS NULL AND name = $1 AND type = $2 ORDER BY id LIMIT 1`,
+		c.Name, c.Type).Scan(&newID)
+	if err == sql.ErrNoRows {
+		// Системной категории нет в этом экземпляре — создаём её как категорию пользователя
+		err = s.tx.QueryRowContext(s.ctx, `INSERT INTO categories (user_id, name, type, archived) VALUES ($1, $2, $3, $4) RETURNING id`,
+			s.userID, c.Name, c.Type, c.Archived).Scan(&newID)
+		s.result.Categories++
+	}
+	if err != nil {
+		logger.ErrorContext(s.ctx, "Failed to restore system category: ", err)
+		return 0, err
+	}
+	s.categories[id] = newID
+	return newID, nil
+}
+
+// subcategory возвращает новый id подкатегории из архива.
+func (s *archiveRestore) subcategory(id *int64) (*int64, error) {
+	if id == nil {
+		return nil, nil
+	}
+	if newID, ok := s.subcategories[*id]; ok {
+		return &newID, nil
+	}
+	sub, ok := s.systemSubcategories[*id]
+	if !ok {
+		return s.subcategories.optional("subcategory", id)
+	}
+	categoryID, err := s.category(sub.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	var newID int64
+	err = s.tx.QueryRowContext(s.ctx, `SELECT id FROM subcategories WHERE user_id IS NULL AND category_id = $1 AND name = $2 ORDER BY id LIMIT 1`,
+		categoryID, sub.Name).Scan(&newID)
+	if err == sql.ErrNoRows {
+		err = s.tx.QueryRowContext(s.ctx, `INSERT INTO subcategories (category_id, user_id, name) VALUES ($1, $2, $3) RETURNING id`,
+			categoryID, s.userID, sub.Name).Scan(&newID)
+		s.result.Subcategories++
+	}
+	if err != nil {
+		logger.ErrorContext(s.ctx, "Failed to restore system subcategory: ", err)
+		return nil, err
+	}
+	s.subcategories[*id] = newID
+	return &newID, nil
+}
+
+// RestoreArchive загружает данные архива пользователю внутри транзакции tx, которую
+// фиксирует вызывающий: вместе с данными в ней же восстанавливается профиль. Восстановить
+// архив можно только в пустую учётную запись, иначе возвращается ErrConflict. Все ссылки
+// между записями переназначаются на новые идентификаторы; ссылка на запись, которой нет
+// в архиве, возвращает ErrInvalidReference.
+func (r *Repository) RestoreArchive(ctx context.Context, tx *sql.Tx, userID int64, data *models.FinanceArchive) (*models.RestoreResult, error) {
+	var hasData bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM incomes WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM expenses WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM goals WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM budgets WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM accounts WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM transfers WHERE user_id = $1)
+			OR EXISTS (SELECT 1 FROM categories WHERE user_id = $1)`, userID).Scan(&hasData)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check account data: ", err)
+		return nil, err
+	}
+	if hasData {
+		return nil, fmt.Errorf("user %d already has data: %w", userID, ErrConflict)
+	}
+
+	s := &archiveRestore{
+		ctx:                 ctx,
+		tx:                  tx,
+		userID:              userID,
+		categories:          archiveIDs{},
+		subcategories:       archiveIDs{},
+		systemCategories:    map[int64]models.Category{},
+		systemSubcategories: map[int64]models.Subcategory{},
+		accounts:            archiveIDs{},
+		transactions:        archiveIDs{},
+		transfers:           archiveIDs{},
+	}
+	steps := []func(*models.FinanceArchive) error{
+		s.restoreCategories,
+		s.restoreAccounts,
+		s.restoreTransactions,
+		s.restoreTransfers,
+		s.restoreGoals,
+		s.restoreBudgets,
+	}
+	for _, step := range steps {
+		if err := step(data); err != nil {
+			return nil, err
+		}
+	}
+	return &s.result, nil
+}
+
+func (s *archiveRestore) restoreCategories(data *models.FinanceArchive) error {
+	for _, c := range data.Categories {
+		if c.Type != "income" && c.Type != "expense" {
+			return fmt.Errorf("category %d has invalid type %q: %w", c.ID, c.Type, ErrInvalidReference)
+		}
+		if c.System {
+			s.systemCategories[c.ID] = c.Category
+			continue
+		}
+		var id int64
+		err := s.tx.QueryRowContext(s.ctx, `INSERT INTO categories (user_id, name, type, archived) VALUES ($1, $2, $3, $4) RETURNING id`,
+			s.userID, c.Name, c.Type, c.Archived).Scan(&id)
+		if err != nil {
+			logger.ErrorContext(s.ctx, "Failed to restore category: ", err)
+			return err
+		}
+		s.categories[c.ID] = id
+		s.result.Categories++
+	}
+
+	for _, c := range data.Categories {
+		for _, sub := range c.Subcategories {
+			sub.CategoryID = c.ID
+			if sub.System {
+				s.systemSubcategories[sub.ID] = sub
+				continue
+			}
+			categoryID, err := s.category(c.ID)
+			if err != nil {
+				return err
+			}
+			var id int64
+			err = s.tx.QueryRowContext(s.ctx, `INSERT INTO subcategories (category_id, user_id, name) VALUES ($1, $2, $3) RETURNING id`,
+				categoryID, s.userID, sub.Name).Scan(&id)
+			if err != nil {
+				logger.ErrorContext(s.ctx, "Failed to restore subcategory: ", err)
+				return err
+			}
+			s.subcategories[sub.ID] = id
+			s.result.Subcategories++
+		}
+	}
+	return nil
+}
+
+func (s *archiveRestore) restoreAccounts(data *models.FinanceArchive) error {
+	for _, a := range data.Accounts {
+		var id int64
+		err := s.tx.QueryRowContext(s.ctx, `
+			INSERT INTO accounts (user_id, name, type, currency, opening_balance, archived, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			s.userID, a.Name, a.Type, a.Currency, a.OpeningBalance, a.Archived, a.CreatedAt).Scan(&id)
+		if err != nil {
+			logger.ErrorContext(s.ctx, "Failed to restore account: ", err)
+			return err
+		}
+		s.accounts[a.ID] = id
+		s.result.Accounts++
+	}
+	return nil
+}
+
+func (s *archiveRestore) restoreTransactions(data *models.FinanceArchive) error {
+	for _, t := range data.Transactions {
+		if t.Type != "income" && t.Type != "expense" {
+			return fmt.Errorf("transaction %d has invalid type %q: %w", t.ID, t.Type, ErrInvalidReference)
+		}
+		categoryID, err := s.category(t.CategoryID)
+		if err != nil {
+			return err
+		}
+		subcategoryID, err := s.subcategory(t.SubcategoryID)
+		if err != nil {
+			return err
+		}
+		accountID, err := s.accounts.optional("account", t.AccountID)
+		if err != nil {
+			return err
+		}
+		table := "incomes"
+		if t.Type == "expense" {
+			table = "expenses"
+		}
+		var id int64
+		err = s.tx.QueryRowContext(s.ctx, `
+			INSERT INTO `+table+` (user_id, amount, currency, category_id, subcategory_id, account_id, description, tags, date, note)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+			s.userID, t.Amount, t.Currency, categoryID, subcategoryID, accountID, t.Description, pq.Array(t.Tags), t.Date, t.Note).Scan(&id)
+		if err != nil {
+			logger.ErrorContext(s.ctx, "Failed to restore transaction: ", err)
+			return err
+		}
+		// Доходы и расходы хранятся в разных таблицах, поэтому их id в архиве могут совпадать;
+		// взносы в цели ссылаются только на расходы
+		if t.Type == "expense" {
+			s.transactions[t.ID] = id
+		}
+		s.result.Transactions++
+	}
+	return nil
+}
+
+func (s *archiveRestore) restoreTransfers(data *models.FinanceArchive) error {
+	for _, t := range data.Transfers {
+		fromID, err := s.accounts.get("account", t.FromAccountID)
+		if err != nil {
+			return err
+		}
+		toID, err := s.accounts.get("account", t.ToAccountID)
+		if err != nil {
+			return err
+		}
+		var id int64
+		err = s.tx.QueryRowContext(s.ctx, `INSERT INTO transfers (user_id, date, note, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+			s.userID, t.Date, t.Note, t.CreatedAt).Scan(&id)
+		if err != nil {
+			logger.ErrorContext(s.ctx, "Failed to restore transfer: ", err)
+			return err
+		}
+		_, err = s.tx.ExecContext(s.ctx, `INSERT INTO transfer_legs (transfer_id, account_id, amount) VALUES ($1, $2, $3), ($1, $4, $5)`,
+			id, fromID, -t.Amount, toID, t.ToAmount)
+		if err != nil {
+			logger.ErrorContext(s.ctx, "Failed to restore transfer legs: ", err)
+			return err
+		}
+		s.transfers[t.ID] = id
+		s.result.Transfers++
+	}
+	return nil
+}
+
+func (s *archiveRestore) restoreGoals(data *models.FinanceArchive) error {
+	for _, g := range data.Goals {
+		var goalID int64
+		err := s.tx.QueryRowContext(s.ctx, `
+			INSERT INTO goals (user_id, name, target_amount, currency, deadline, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			s.userID, g.Name, g.TargetAmount, g.Currency, g.Deadline, g.CreatedAt).Scan(&goalID)
+		if err != nil {
+			logger.ErrorContext(s.ctx, "Failed to restore goal: ", err)
+			return err
+		}
+		s.result.Goals++
+
+		for _, c := range g.Contributions {
+			expenseID, err := s.transactions.optional("expense", c.ExpenseID)
+			if err != nil {
+				return err
+			}
+			transferID, err := s.transfers.optional("transfer", c.TransferID)
+			if err != nil {
+				return err
+			}
+			_, err = s.tx.ExecContext(s.ctx, `
+				INSERT INTO goal_contributions (goal_id, user_id, amount, date, note, expense_id, transfer_id, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				goalID, s.userID, c.Amount, c.Date, c.Note, expenseID, transferID, c.CreatedAt)
+			if err != nil {
+				logger.ErrorContext(s.ctx, "Failed to restore goal contribution: ", err)
+				return err
+			}
+			s.result.Contributions++
+		}
+	}
+	return nil
+}
+
+func (s *archiveRestore) restoreBudgets(data *models.FinanceArchive) error {
+	for _, b := range data.Budgets {
+		categoryID, err := s.category(b.CategoryID)
+		if err != nil {
+			return err
+		}
+		_, err = s.tx.ExecContext(s.ctx, `
+			INSERT INTO budgets (user_id, category_id, amount, currency, month, rollover, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			s.userID, categoryID, b.Amount, b.Currency, b.Month, b.Rollover, b.CreatedAt)
+		if err != nil {
+			logger.ErrorContext(s.ctx, "Failed to restore budget: ", err)
+			return err
+		}
+		s.result.Budgets++
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreArchive(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	day := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+	subcategoryID, accountID, expenseID := int64(21), int64(5), int64(30)
+	archive := func() *models.FinanceArchive {
+		return &models.FinanceArchive{
+			Categories: []models.ArchiveCategory{
+				{
+					Category:      models.Category{ID: 1, Name: "Food", Type: "expense", System: true},
+					Subcategories: []models.Subcategory{{ID: subcategoryID, CategoryID: 1, Name: "Cafe"}},
+				},
+				{Category: models.Category{ID: 2, Name: "Salary", Type: "income", System: true}},
+			},
+			Accounts: []models.Account{{ID: accountID, Name: "Card", Type: models.AccountCard, Currency: "RUB"}},
+			Transactions: []models.TransactionResponse{
+				{ID: expenseID, Type: "expense", Amount: money.MustParse("3.50"), Currency: "RUB", CategoryID: 1,
+					SubcategoryID: &subcategoryID, AccountID: &accountID, Date: day},
+			},
+			Goals: []models.ArchiveGoal{{
+				GoalResponse:  models.GoalResponse{ID: 7, Name: "Trip", TargetAmount: money.MustParse("1000"), Currency: "RUB", Deadline: day},
+				Contributions: []models.GoalContribution{{ID: 8, GoalID: 7, Amount: money.MustParse("3.50"), Date: day, ExpenseID: &expenseID}},
+			}},
+		}
+	}
+	expectFresh := func(hasData bool) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incomes WHERE user_id = \$1\)`).
+			WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(hasData))
+	}
+
+	// restore фиксирует транзакцию, как это делает RestoreProfile репозитория пользователей
+	restore := func(data *models.FinanceArchive) (*models.RestoreResult, error) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+		result, err := repo.RestoreArchive(context.Background(), tx, 1, data)
+		if err != nil {
+			return nil, err
+		}
+		return result, tx.Commit()
+	}
+
+	t.Run("Remaps References", func(t *testing.T) {
+		expectFresh(false)
+		// Системная категория Food есть в этом экземпляре, её подкатегория Cafe — своя
+		mock.ExpectQuery(`SELECT id FROM categories WHERE user_id IS NULL AND name = \$1 AND type = \$2`).
+			WithArgs("Food", "expense").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(101))
+		mock.ExpectQuery(`INSERT INTO subcategories`).
+			WithArgs(int64(101), int64(1), "Cafe").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(121))
+		mock.ExpectQuery(`INSERT INTO accounts`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(105))
+		mock.ExpectQuery(`INSERT INTO expenses`).
+			WithArgs(int64(1), "3.50", "RUB", int64(101), int64(121), int64(105), "", sqlmock.AnyArg(), day, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(130))
+		mock.ExpectQuery(`INSERT INTO goals`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(107))
+		mock.ExpectExec(`INSERT INTO goal_contributions`).
+			WithArgs(int64(107), int64(1), "3.50", day, "", int64(130), nil, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		result, err := restore(archive())
+		require.NoError(t, err)
+		assert.Equal(t, models.RestoreResult{Subcategories: 1, Accounts: 1, Transactions: 1, Goals: 1, Contributions: 1}, *result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Account Not Empty", func(t *testing.T) {
+		expectFresh(true)
+		mock.ExpectRollback()
+
+		_, err := restore(archive())
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Reference", func(t *testing.T) {
+		data := archive()
+		data.Categories = data.Categories[1:]
+		data.Accounts = nil
+		data.Transactions[0].SubcategoryID = nil
+		expectFresh(false)
+		mock.ExpectRollback()
+
+		_, err := restore(data)
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
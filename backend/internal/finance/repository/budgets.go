@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// budgetMonthEndSQL возвращает SQL-выражение последнего дня месяца month (YYYY-MM) —
+// даты курса, по которому пересчитывается лимит бюджета.
+func budgetMonthEndSQL(month string) string {
+	return `(TO_DATE(` + month + `, 'YYYY-MM') + INTERVAL '1 month - 1 day')::date`
+}
+
+// validateBudgetCategory проверяет, что бюджет ссылается на доступную пользователю категорию расходов.
+func validateBudgetCategory(ctx context.Context, q querier, userID, categoryID int64) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1 AND type = 'expense' AND `+visibleToUserSQL("categories", "$2")+`)`,
+		categoryID, userID).Scan(&exists)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check budget category: ", err)
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("expense category with id %d does not exist: %w", categoryID, ErrInvalidReference)
+	}
+	return nil
+}
+
+// budgetPlanSQL — бюджеты пользователя $1 за все месяцы до $2 включительно; пустой $2 означает
+// текущий месяц или последний месяц с сохранённым бюджетом, если он позже. Кроме сохранённых
+// бюджетов в план входят бюджеты по шаблонам для месяцев, где бюджет категории не сохранён
+// и не удалялся пользователем (budget_template_months); у таких бюджетов id = 0.
+//
+// Перенесённый остаток (rollover_amount) не хранится, а считается при каждом чтении проходом
+// по цепочке идущих подряд месяцев категории в одной валюте: лимит предыдущего месяца с его
+// собственным переносом минус его расходы. Поэтому учитываются и месяцы, которые никто
+// не открывал, и расходы, добавленные в прошлые месяцы задним числом. spent и missing —
+// расходы месяца в валюте бюджета и число расходов без курса, carry_missing — то же
+// для месяцев, из которых перенесён остаток.
+var budgetPlanSQL = `
+	WITH RECURSIVE bounds AS (
+		SELECT COALESCE(NULLIF($2, ''), GREATEST(TO_CHAR(CURRENT_DATE, 'YYYY-MM'),
+			(SELECT MAX(month) FROM budgets WHERE user_id = $1))) AS last_month
+	),
+	template_months AS (
+		SELECT TO_CHAR(m, 'YYYY-MM') AS month
+		FROM bounds, generate_series(
+			TO_DATE((SELECT MIN(start_month) FROM budget_templates WHERE user_id = $1), 'YYYY-MM'),
+			TO_DATE(bounds.last_month, 'YYYY-MM'), INTERVAL '1 month') m
+	),
+	plan AS (
+		SELECT b.id, b.user_id, b.category_id, b.amount, b.currency, b.month, b.rollover, b.template_id, b.created_at
+		FROM budgets b, bounds
+		WHERE b.user_id = $1 AND b.month <= bounds.last_month
+		UNION ALL
+		SELECT 0, t.user_id, t.category_id, t.amount, t.currency, m.month, t.rollover, t.id, t.created_at
+		FROM budget_templates t JOIN template_months m ON m.month >= t.start_month
+		WHERE t.user_id = $1
+			AND NOT EXISTS (SELECT 1 FROM budgets b WHERE b.user_id = t.user_id AND b.category_id = t.category_id AND b.month = m.month)
+			AND NOT EXISTS (SELECT 1 FROM budget_template_months tm WHERE tm.template_id = t.id AND tm.month = m.month)
+	),
+	spending AS (
+		SELECT p.*, s.spent, s.missing
+		FROM plan p CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(` + convertedAmountSQL("e", "p.currency") + `), 0) AS spent,
+				COUNT(*) FILTER (WHERE ` + exchangeRateSQL("e.currency", "e.date", "p.currency") + ` IS NULL) AS missing
+			FROM expenses e
+			WHERE e.user_id = p.user_id AND e.category_id = p.category_id AND TO_CHAR(e.date, 'YYYY-MM') = p.month
+		) s
+	),
+	chain AS (
+		SELECT s.*, 0::numeric AS rollover_amount, 0::bigint AS carry_missing
+		FROM spending s
+		WHERE NOT EXISTS (
+			SELECT 1 FROM plan p
+			WHERE p.category_id = s.category_id AND p.currency = s.currency
+				AND p.month = TO_CHAR(TO_DATE(s.month, 'YYYY-MM') - INTERVAL '1 month', 'YYYY-MM'))
+		UNION ALL
+		SELECT n.*,
+			CASE WHEN n.rollover THEN (c.amount + c.rollover_amount - c.spent)::numeric ELSE 0 END,
+			CASE WHEN n.rollover THEN c.carry_missing + c.missing ELSE 0 END
+		FROM chain c JOIN spending n ON n.category_id = c.category_id AND n.currency = c.currency
+			AND n.month = TO_CHAR(TO_DATE(c.month, 'YYYY-MM') + INTERVAL '1 month', 'YYYY-MM')
+	)`
+
+// CopyBudgets копирует бюджеты пользователя из месяца from, включая бюджеты по шаблонам,
+// в месяц to. Уже существующие бюджеты целевого месяца перезаписываются только при overwrite.
+// Возвращает число созданных или изменённых бюджетов.
+func (r *Repository) CopyBudgets(ctx context.Context, userID int64, from, to string, overwrite bool) (int64, error) {
+	conflict := `DO NOTHING`
+	if overwrite {
+		conflict = `DO UPDATE SET amount = EXCLUDED.amount, currency = EXCLUDED.currency, rollover = EXCLUDED.rollover`
+	}
+	query := budgetPlanSQL + `
+		INSERT INTO budgets (user_id, category_id, amount, currency, month, rollover, template_id, created_at)
+		SELECT user_id, category_id, amount, currency, $3, rollover, template_id, $4
+		FROM plan WHERE month = $2
+		ON CONFLICT (user_id, category_id, month) ` + conflict
+	result, err := r.db.ExecContext(ctx, query, userID, from, to, time.Now())
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to copy budgets: ", err)
+		return 0, err
+	}
+	copied, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return 0, err
+	}
+	return copied, nil
+}
+
+func (r *Repository) SaveBudgetTemplate(ctx context.Context, template *models.BudgetTemplate) (int64, error) {
+	if err := validateBudgetCategory(ctx, r.db, template.UserID, template.CategoryID); err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO budget_templates (user_id, category_id, amount, currency, rollover, start_month, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, category_id) DO NOTHING
+		RETURNING id`
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, template.UserID, template.CategoryID, template.Amount, template.Currency,
+		template.Rollover, template.StartMonth, template.CreatedAt).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("budget template for category %d already exists: %w", template.CategoryID, ErrAlreadyExists)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save budget template: ", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *Repository) GetBudgetTemplates(ctx context.Context, userID int64) ([]models.BudgetTemplate, error) {
+	query := `
+		SELECT id, user_id, category_id, amount, currency, rollover, start_month, created_at
+		FROM budget_templates WHERE user_id = $1 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get budget templates: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []models.BudgetTemplate
+	for rows.Next() {
+		var t models.BudgetTemplate
+		err := rows.Scan(&t.ID, &t.UserID, &t.CategoryID, &t.Amount, &t.Currency, &t.Rollover, &t.StartMonth, &t.CreatedAt)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan budget template: ", err)
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// UpdateBudgetTemplate меняет лимит, валюту, перенос остатка и начальный месяц шаблона.
+// Изменения действуют для всех месяцев, где бюджет категории не сохранён отдельно; категория не меняется.
+// Пустые валюта и начальный месяц оставляют текущие.
+func (r *Repository) UpdateBudgetTemplate(ctx context.Context, template *models.BudgetTemplate) error {
+	query := `
+		UPDATE budget_templates SET amount=$1, currency=COALESCE(NULLIF($2, ''), currency), rollover=$3, start_month=COALESCE(NULLIF($4, ''), start_month)
+		WHERE id=$5 AND user_id=$6`
+	result, err := r.db.ExecContext(ctx, query, template.Amount, template.Currency, template.Rollover, template.StartMonth,
+		template.ID, template.UserID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to update budget template: ", err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no budget template found with id %d for user %d: %w", template.ID, template.UserID, ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteBudgetTemplate удаляет шаблон. Уже созданные по нему бюджеты остаются.
+func (r *Repository) DeleteBudgetTemplate(ctx context.Context, id, userID int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM budget_templates WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to delete budget template: ", err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no budget template found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// visibleToUserSQL возвращает условие видимости записи таблицы table пользователю userParam:
+// системные записи (user_id = NULL) видны всем, остальные — только владельцу.
+func visibleToUserSQL(table, userParam string) string {
+	return `(` + table + `.user_id IS NULL OR ` + table + `.user_id = ` + userParam + `)`
+}
+
+// SaveCategory создаёт категорию пользователя. Если доступная пользователю категория
+// с таким названием и типом уже есть (системная или своя), возвращается её id.
+func (r *Repository) SaveCategory(ctx context.Context, userID int64, category *models.Category) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id FROM categories
+		WHERE name = $1 AND type = $2 AND `+visibleToUserSQL("categories", "$3")+`
+		ORDER BY user_id NULLS FIRST LIMIT 1`, category.Name, category.Type, userID).Scan(&id)
+	if err == nil {
+		logger.InfoContext(ctx, "Category already exists: ", category.Name, category.Type)
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		logger.ErrorContext(ctx, "Failed to check category existence: ", err)
+		return 0, err
+	}
+
+	query := `INSERT INTO categories (user_id, name, type) VALUES ($1, $2, $3) RETURNING id`
+	err = r.db.QueryRowContext(ctx, query, userID, category.Name, category.Type).Scan(&id)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save category: ", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetCategories возвращает системные категории и категории пользователя указанного типа.
+// Архивные категории возвращаются только при includeArchived.
+func (r *Repository) GetCategories(ctx context.Context, userID int64, txType string, includeArchived bool) ([]models.Category, error) {
+	query := `
+		SELECT id, name, type, user_id IS NULL, archived FROM categories
+		WHERE type = $1 AND ` + visibleToUserSQL("categories", "$2") + ` AND ($3 OR NOT archived)
+		ORDER BY user_id NULLS FIRST, name`
+	rows, err := r.db.QueryContext(ctx, query, txType, userID, includeArchived)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get categories: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var c models.Category
+		err := rows.Scan(&c.ID, &c.Name, &c.Type, &c.System, &c.Archived)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan category: ", err)
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// lockOwnCategory блокирует категорию пользователя и возвращает её тип.
+// Для системной категории возвращается ErrForbidden, для чужой или несуществующей — ErrNotFound.
+func lockOwnCategory(ctx context.Context, q querier, userID, id int64) (string, error) {
+	var txType string
+	var system bool
+	err := q.QueryRowContext(ctx, `
+		SELECT type, user_id IS NULL FROM categories
+		WHERE id = $1 AND `+visibleToUserSQL("categories", "$2")+` FOR UPDATE`, id, userID).Scan(&txType, &system)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no category found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to lock category: ", err)
+		return "", err
+	}
+	if system {
+		return "", fmt.Errorf("category %d is a system category: %w", id, ErrForbidden)
+	}
+	return txType, nil
+}
+
+// UpdateCategory переименовывает категорию пользователя и/или меняет признак архивной.
+// Архивная категория скрыта из списка, но остаётся у существующих транзакций.
+func (r *Repository) UpdateCategory(ctx context.Context, userID, id int64, req *models.CategoryUpdateRequest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	txType, err := lockOwnCategory(ctx, tx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if req.Name != nil {
+		var taken bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM categories
+			WHERE name = $1 AND type = $2 AND id <> $3 AND `+visibleToUserSQL("categories", "$4")+`)`,
+			*req.Name, txType, id, userID).Scan(&taken)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to check category name: ", err)
+			return err
+		}
+		if taken {
+			return fmt.Errorf("category %q already exists: %w", *req.Name, ErrAlreadyExists)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE categories SET name = $1 WHERE id = $2`, *req.Name, id); err != nil {
+			logger.ErrorContext(ctx, "Failed to rename category: ", err)
+			return err
+		}
+	}
+	if req.Archived != nil {
+		if _, err := tx.ExecContext(ctx, `UPDATE categories SET archived = $1 WHERE id = $2`, *req.Archived, id); err != nil {
+			logger.ErrorContext(ctx, "Failed to archive category: ", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit category update: ", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteCategory удаляет неиспользуемую категорию пользователя вместе с её подкатегориями.
+// Если на категорию ссылаются транзакции, правила или бюджеты, возвращается ErrConflict:
+// такую категорию нужно объединить с другой через MergeCategory.
+func (r *Repository) DeleteCategory(ctx context.Context, userID, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockOwnCategory(ctx, tx, userID, id); err != nil {
+		return err
+	}
+
+	var used bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM incomes WHERE category_id = $1)
+			OR EXISTS (SELECT 1 FROM expenses WHERE category_id = $1)
+			OR EXISTS (SELECT 1 FROM recurring_rules WHERE category_id = $1)
+			OR EXISTS (SELECT 1 FROM budgets WHERE category_id = $1)
+			OR EXISTS (SELECT 1 FROM budget_templates WHERE category_id = $1)`, id).Scan(&used)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check category usage: ", err)
+		return err
+	}
+	if used {
+		return fmt.Errorf("category %d is in use: %w", id, ErrConflict)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subcategories WHERE category_id = $1`, id); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete subcategories: ", err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete category: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit category deletion: ", err)
+		return err
+	}
+	return nil
+}
+
+// MergeCategory переносит всё, что ссылается на категорию пользователя sourceID, в категорию
+// targetID того же типа и удаляет исходную. Подкатегории переходят в целевую категорию.
+// Бюджеты и шаблоны, которые уже есть у целевой категории, суммируются; лимит в другой валюте
+// пересчитывается в валюту целевого бюджета по курсу на конец месяца (для шаблонов — на сегодня).
+// Перенесённые остатки отдельно не сливаются: они считаются при чтении по объединённым бюджетам
+// и расходам. Возвращает число перенесённых транзакций.
+func (r *Repository) MergeCategory(ctx context.Context, userID, sourceID, targetID int64) (int64, error) {
+	if sourceID == targetID {
+		return 0, fmt.Errorf("cannot merge category %d into itself: %w", sourceID, ErrInvalidReference)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	sourceType, err := lockOwnCategory(ctx, tx, userID, sourceID)
+	if err != nil {
+		return 0, err
+	}
+	var targetType string
+	err = tx.QueryRowContext(ctx, `SELECT type FROM categories WHERE id = $1 AND `+visibleToUserSQL("categories", "$2"),
+		targetID, userID).Scan(&targetType)
+	if err == sql.ErrNoRows || (err == nil && targetType != sourceType) {
+		return 0, fmt.Errorf("%s category with id %d does not exist: %w", sourceType, targetID, ErrInvalidReference)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get target category: ", err)
+		return 0, err
+	}
+
+	var from, to, month string
+	err = tx.QueryRowContext(ctx, `
+		SELECT s.currency, t.currency, s.month FROM budgets s
+		JOIN budgets t ON t.category_id = $1 AND t.user_id = s.user_id AND t.month = s.month
+		WHERE s.category_id = $2 AND s.user_id = $3 AND `+exchangeRateSQL("s.currency", budgetMonthEndSQL("s.month"), "t.currency")+` IS NULL
+		UNION ALL
+		SELECT s.currency, t.currency, '' FROM budget_templates s
+		JOIN budget_templates t ON t.category_id = $1 AND t.user_id = s.user_id
+		WHERE s.category_id = $2 AND s.user_id = $3 AND `+exchangeRateSQL("s.currency", "CURRENT_DATE", "t.currency")+` IS NULL
+		LIMIT 1`, targetID, sourceID, userID).Scan(&from, &to, &month)
+	if err == nil {
+		return 0, fmt.Errorf("no exchange rate from %s to %s to merge budgets %s: %w", from, to, month, ErrMissingExchangeRate)
+	}
+	if err != sql.ErrNoRows {
+		logger.ErrorContext(ctx, "Failed to check budget exchange rates: ", err)
+		return 0, err
+	}
+
+	var moved int64
+	for _, table := range []string{"incomes", "expenses"} {
+		result, err := tx.ExecContext(ctx, `UPDATE `+table+` SET category_id = $1 WHERE category_id = $2 AND user_id = $3`,
+			targetID, sourceID, userID)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to move ", table, " to category: ", err)
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+			return 0, err
+		}
+		moved += n
+	}
+
+	statements := []struct{ description, query string }{
+		{"move subcategories", `UPDATE subcategories SET category_id = $1 WHERE category_id = $2 AND ` + visibleToUserSQL("subcategories", "$3")},
+		{"move recurring rules", `UPDATE recurring_rules SET category_id = $1 WHERE category_id = $2 AND user_id = $3`},
+		{"merge budgets", `
+			UPDATE budgets t SET amount = t.amount + ROUND(s.amount * ` + exchangeRateSQL("s.currency", budgetMonthEndSQL("s.month"), "t.currency") + `, 2)
+			FROM budgets s
+			WHERE s.category_id = $2 AND s.user_id = $3
+				AND t.category_id = $1 AND t.user_id = s.user_id AND t.month = s.month`},
+		{"drop merged budgets", `
+			DELETE FROM budgets s USING budgets t
+			WHERE s.category_id = $2 AND s.user_id = $3 AND t.category_id = $1 AND t.user_id = s.user_id AND t.month = s.month`},
+		{"move budgets", `UPDATE budgets SET category_id = $1 WHERE category_id = $2 AND user_id = $3`},
+		{"merge budget templates", `
+			UPDATE budget_templates t SET amount = t.amount + ROUND(s.amount * ` + exchangeRateSQL("s.currency", "CURRENT_DATE", "t.currency") + `, 2)
+			FROM budget_templates s
+			WHERE s.category_id = $2 AND s.user_id = $3
+				AND t.category_id = $1 AND t.user_id = s.user_id`},
+		{"drop merged budget templates", `
+			DELETE FROM budget_templates s USING budget_templates t
+			WHERE s.category_id = $2 AND s.user_id = $3 AND t.category_id = $1 AND t.user_id = s.user_id`},
+		{"move budget templates", `UPDATE budget_templates SET category_id = $1 WHERE category_id = $2 AND user_id = $3`},
+	}
+	for _, st := range statements {
+		if _, err := tx.ExecContext(ctx, st.query, targetID, sourceID, userID); err != nil {
+			logger.ErrorContext(ctx, "Failed to ", st.description, ": ", err)
+			return 0, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, sourceID); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete category: ", err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit category merge: ", err)
+		return 0, err
+	}
+	return moved, nil
+}
+
+// SaveSubcategory создаёт подкатегорию пользователя в доступной ему категории.
+func (r *Repository) SaveSubcategory(ctx context.Context, userID int64, subcategory *models.Subcategory) (int64, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND `+visibleToUserSQL("categories", "$2")+`)`,
+		subcategory.CategoryID, userID).Scan(&exists)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check category existence: ", err)
+		return 0, err
+	}
+	if !exists {
+		logger.ErrorContext(ctx, "Category does not exist: ", subcategory.CategoryID)
+		return 0, fmt.Errorf("category_id %d does not exist: %w", subcategory.CategoryID, ErrInvalidReference)
+	}
+
+	query := `INSERT INTO subcategories (category_id, user_id, name) VALUES ($1, $2, $3) RETURNING id`
+	var id int64
+	err = r.db.QueryRowContext(ctx, query, subcategory.CategoryID, userID, subcategory.Name).Scan(&id)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save subcategory: ", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetSubcategories возвращает системные подкатегории и подкатегории пользователя
+// в доступной ему категории.
+func (r *Repository) GetSubcategories(ctx context.Context, userID, categoryID int64) ([]models.Subcategory, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND `+visibleToUserSQL("categories", "$2")+`)`,
+		categoryID, userID).Scan(&exists)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check category existence: ", err)
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no category found with id %d for user %d: %w", categoryID, userID, ErrNotFound)
+	}
+
+	query := `
+		SELECT id, category_id, name, user_id IS NULL FROM subcategories
+		WHERE category_id = $1 AND ` + visibleToUserSQL("subcategories", "$2") + `
+		ORDER BY name`
+	rows, err := r.db.QueryContext(ctx, query, categoryID, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get subcategories: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subcategories []models.Subcategory
+	for rows.Next() {
+		var s models.Subcategory
+		err := rows.Scan(&s.ID, &s.CategoryID, &s.Name, &s.System)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan subcategory: ", err)
+			return nil, err
+		}
+		subcategories = append(subcategories, s)
+	}
+	return subcategories, nil
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"budgetbuddy/internal/finance/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateCategory(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	name := "Groceries"
+	archived := true
+
+	t.Run("Rename And Archive", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories\s+WHERE id = \$1 AND .* FOR UPDATE`).
+			WithArgs(int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}).AddRow("expense", false))
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories\s+WHERE name = \$1 AND type = \$2 AND id <> \$3`).
+			WithArgs("Groceries", "expense", int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec(`UPDATE categories SET name = \$1 WHERE id = \$2`).
+			WithArgs("Groceries", int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE categories SET archived = \$1 WHERE id = \$2`).
+			WithArgs(true, int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.UpdateCategory(context.Background(), 1, 7, &models.CategoryUpdateRequest{Name: &name, Archived: &archived})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("System Category", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}).AddRow("expense", true))
+		mock.ExpectRollback()
+
+		err := repo.UpdateCategory(context.Background(), 1, 2, &models.CategoryUpdateRequest{Name: &name})
+		assert.ErrorIs(t, err, ErrForbidden)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Foreign Category", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories`).
+			WithArgs(int64(9), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}))
+		mock.ExpectRollback()
+
+		err := repo.UpdateCategory(context.Background(), 1, 9, &models.CategoryUpdateRequest{Archived: &archived})
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteCategory(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("In Use", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories`).
+			WithArgs(int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}).AddRow("expense", false))
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incomes WHERE category_id = \$1\)`).
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(true))
+		mock.ExpectRollback()
+
+		err := repo.DeleteCategory(context.Background(), 1, 7)
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unused", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories`).
+			WithArgs(int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}).AddRow("expense", false))
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM incomes`).
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(false))
+		mock.ExpectExec(`DELETE FROM subcategories WHERE category_id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`DELETE FROM categories WHERE id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.DeleteCategory(context.Background(), 1, 7)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMergeCategory(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("Into System Category", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories`).
+			WithArgs(int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}).AddRow("expense", false))
+		mock.ExpectQuery(`SELECT type FROM categories WHERE id = \$1`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow("expense"))
+		mock.ExpectQuery(`SELECT s.currency, t.currency, s.month FROM budgets s`).
+			WithArgs(int64(2), int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"from", "to", "month"}))
+		mock.ExpectExec(`UPDATE incomes SET category_id = \$1 WHERE category_id = \$2 AND user_id = \$3`).
+			WithArgs(int64(2), int64(7), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE expenses SET category_id = \$1 WHERE category_id = \$2 AND user_id = \$3`).
+			WithArgs(int64(2), int64(7), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 12))
+		for _, query := range []string{
+			`UPDATE subcategories SET category_id`,
+			`UPDATE recurring_rules SET category_id`,
+			`UPDATE budgets t SET amount = t.amount \+ ROUND\(s.amount \*`,
+			`DELETE FROM budgets s USING budgets t`,
+			`UPDATE budgets SET category_id`,
+			`UPDATE budget_templates t SET amount = t.amount \+ ROUND\(s.amount \*`,
+			`DELETE FROM budget_templates s USING budget_templates t`,
+			`UPDATE budget_templates SET category_id`,
+		} {
+			mock.ExpectExec(query).
+				WithArgs(int64(2), int64(7), int64(1)).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(`DELETE FROM categories WHERE id = \$1`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		moved, err := repo.MergeCategory(context.Background(), 1, 7, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), moved)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing Budget Rate", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories`).
+			WithArgs(int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}).AddRow("expense", false))
+		mock.ExpectQuery(`SELECT type FROM categories WHERE id = \$1`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow("expense"))
+		mock.ExpectQuery(`SELECT s.currency, t.currency, s.month FROM budgets s`).
+			WithArgs(int64(2), int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"from", "to", "month"}).AddRow("USD", "RUB", "2025-07"))
+		mock.ExpectRollback()
+
+		_, err := repo.MergeCategory(context.Background(), 1, 7, 2)
+		assert.ErrorIs(t, err, ErrMissingExchangeRate)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Different Type", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT type, user_id IS NULL FROM categories`).
+			WithArgs(int64(7), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "system"}).AddRow("expense", false))
+		mock.ExpectQuery(`SELECT type FROM categories WHERE id = \$1`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow("income"))
+		mock.ExpectRollback()
+
+		_, err := repo.MergeCategory(context.Background(), 1, 7, 3)
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"time"
+)
+
+// Функции выгрузки читают строки по одной через rows.Next() и передают их в fn,
+// не собирая весь результат в памяти. Ошибка fn прерывает выгрузку и возвращается как есть.
+
+// ExportTransactions выгружает доходы и расходы пользователя за период в порядке даты.
+// Границы периода необязательны.
+func (r *Repository) ExportTransactions(ctx context.Context, userID int64, from, to *time.Time, fn func(*models.Transaction) error) error {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM (` + transactionSource("all") + `) t
+		WHERE ($2::date IS NULL OR t.date >= $2) AND ($3::date IS NULL OR t.date <= $3)
+		ORDER BY t.date, t.type, t.id`
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to export transactions: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan transaction: ", err)
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportBudgets выгружает бюджеты пользователя, включая бюджеты по шаблонам, за месяцы
+// с fromMonth по toMonth (YYYY-MM). Пустая fromMonth означает отсутствие ограничения, пустая
+// toMonth — выгрузку до текущего месяца или последнего сохранённого бюджета.
+func (r *Repository) ExportBudgets(ctx context.Context, userID int64, fromMonth, toMonth string, fn func(*models.Budget) error) error {
+	query := budgetPlanSQL + `
+		SELECT ` + budgetColumns + `
+		FROM chain
+		WHERE $3 = '' OR month >= $3
+		ORDER BY month, id = 0, id, category_id`
+	rows, err := r.db.QueryContext(ctx, query, userID, toMonth, fromMonth)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to export budgets: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan budget: ", err)
+			return err
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportGoals выгружает цели пользователя с накопленными суммами.
+func (r *Repository) ExportGoals(ctx context.Context, userID int64, fn func(*models.Goal) error) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+goalColumns+` FROM goals g WHERE g.user_id = $1 ORDER BY g.id`, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to export goals: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan goal: ", err)
+			return err
+		}
+		if err := fn(g); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportCategories выгружает все доступные пользователю категории, включая системные и архивные.
+func (r *Repository) ExportCategories(ctx context.Context, userID int64, fn func(*models.Category) error) error {
+	query := `
+		SELECT id, name, type, user_id IS NULL, archived FROM categories
+		WHERE ` + visibleToUserSQL("categories", "$1") + `
+		ORDER BY type, user_id NULLS FIRST, name`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to export categories: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.Type, &c.System, &c.Archived); err != nil {
+			logger.ErrorContext(ctx, "Failed to scan category: ", err)
+			return err
+		}
+		if err := fn(&c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
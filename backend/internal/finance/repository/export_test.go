@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTransactions(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	columns := []string{"id", "user_id", "amount", "currency", "category_id", "subcategory_id", "account_id", "description", "tags", "date", "note", "type"}
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	day := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Streams Both Tables", func(t *testing.T) {
+		mock.ExpectQuery(`FROM incomes WHERE user_id = \$1 UNION ALL .* FROM expenses WHERE user_id = \$1\) t\s+WHERE \(\$2::date IS NULL OR t.date >= \$2\) AND \(\$3::date IS NULL OR t.date <= \$3\)\s+ORDER BY t.date, t.type, t.id`).
+			WithArgs(int64(1), &from, nil).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(7, 1, "20.00", "RUB", 2, nil, 4, "Dinner", "{food}", day, "", "expense").
+				AddRow(3, 1, "10.00", "RUB", 1, nil, nil, "Refund", "{}", day, "", "income"))
+
+		var exported []models.Transaction
+		err := repo.ExportTransactions(context.Background(), 1, &from, nil, func(tx *models.Transaction) error {
+			exported = append(exported, *tx)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, exported, 2)
+		assert.Equal(t, "expense", exported[0].Type)
+		assert.Equal(t, int64(4), *exported[0].AccountID)
+		assert.Nil(t, exported[1].AccountID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stops On Write Error", func(t *testing.T) {
+		mock.ExpectQuery(`FROM incomes`).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(7, 1, "20.00", "RUB", 2, nil, nil, "Dinner", "{}", day, "", "expense").
+				AddRow(3, 1, "10.00", "RUB", 1, nil, nil, "Refund", "{}", day, "", "income"))
+
+		writeErr := errors.New("client disconnected")
+		calls := 0
+		err := repo.ExportTransactions(context.Background(), 1, nil, nil, func(tx *models.Transaction) error {
+			calls++
+			return writeErr
+		})
+		assert.ErrorIs(t, err, writeErr)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestExportBudgets(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	mock.ExpectQuery(`WITH RECURSIVE .*FROM chain\s+WHERE \$3 = '' OR month >= \$3\s+ORDER BY month`).
+		WithArgs(int64(1), "", "2025-06").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "category_id", "amount", "currency", "month", "rollover", "rollover_amount", "template_id", "created_at"}).
+			AddRow(1, 1, 2, "5000.00", "RUB", "2025-06", false, "0", nil, time.Now()).
+			AddRow(2, 1, 2, "5000.00", "RUB", "2025-07", true, "150.00", 3, time.Now()))
+
+	var months []string
+	err := repo.ExportBudgets(context.Background(), 1, "2025-06", "", func(b *models.Budget) error {
+		months = append(months, b.Month)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2025-06", "2025-07"}, months)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
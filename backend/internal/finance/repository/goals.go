@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/money"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// AddGoalContribution добавляет взнос в цель и возвращает цель с обновлённой накопленной суммой.
+// Цель блокируется на время добавления, поэтому сумма до взноса равна
+// goal.CurrentAmount - contribution.Amount даже при одновременных взносах.
+// Взнос, привязанный к расходу или переводу, получает сумму расхода или зачисления перевода
+// в валюте цели по курсу на дату операции.
+func (r *Repository) AddGoalContribution(ctx context.Context, contribution *models.GoalContribution) (*models.Goal, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var lockedID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM goals WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		contribution.GoalID, contribution.UserID).Scan(&lockedID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no goal found with id %d for user %d: %w", contribution.GoalID, contribution.UserID, ErrNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to lock goal: ", err)
+		return nil, err
+	}
+
+	goal, err := scanGoal(tx.QueryRowContext(ctx, `SELECT `+goalColumns+` FROM goals g WHERE g.id = $1`, contribution.GoalID))
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get goal: ", err)
+		return nil, err
+	}
+	contribution.Currency = goal.Currency
+
+	if contribution.ExpenseID != nil {
+		var expenseCurrency string
+		var amount sql.Null[money.Amount]
+		err = tx.QueryRowContext(ctx, `
+			SELECT e.currency, `+convertedAmountSQL("e", "$3")+`, e.date
+			FROM expenses e WHERE e.id = $1 AND e.user_id = $2`,
+			*contribution.ExpenseID, contribution.UserID, goal.Currency).Scan(&expenseCurrency, &amount, &contribution.Date)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("expense with id %d does not exist: %w", *contribution.ExpenseID, ErrInvalidReference)
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to get linked expense: ", err)
+			return nil, err
+		}
+		if !amount.Valid {
+			return nil, fmt.Errorf("no exchange rate from %s to %s on %s: %w",
+				expenseCurrency, goal.Currency, contribution.Date.Format("2006-01-02"), ErrMissingExchangeRate)
+		}
+		contribution.Amount = amount.V
+	}
+
+	if contribution.TransferID != nil {
+		var transferCurrency string
+		var amount sql.Null[money.Amount]
+		err = tx.QueryRowContext(ctx, `
+			SELECT l.currency, `+convertedAmountSQL("l", "$3")+`, l.date
+			FROM (
+				SELECT d.amount, a.currency, t.date
+				FROM transfers t
+				JOIN transfer_legs d ON d.transfer_id = t.id AND d.amount > 0
+				JOIN accounts a ON a.id = d.account_id
+				WHERE t.id = $1 AND t.user_id = $2
+			) l`,
+			*contribution.TransferID, contribution.UserID, goal.Currency).Scan(&transferCurrency, &amount, &contribution.Date)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transfer with id %d does not exist: %w", *contribution.TransferID, ErrInvalidReference)
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to get linked transfer: ", err)
+			return nil, err
+		}
+		if !amount.Valid {
+			return nil, fmt.Errorf("no exchange rate from %s to %s on %s: %w",
+				transferCurrency, goal.Currency, contribution.Date.Format("2006-01-02"), ErrMissingExchangeRate)
+		}
+		contribution.Amount = amount.V
+	}
+
+	query := `
+		INSERT INTO goal_contributions (goal_id, user_id, amount, date, note, expense_id, transfer_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT DO NOTHING
+		RETURNING id`
+	err = tx.QueryRowContext(ctx, query, contribution.GoalID, contribution.UserID, contribution.Amount, contribution.Date,
+		contribution.Note, contribution.ExpenseID, contribution.TransferID, contribution.CreatedAt).Scan(&contribution.ID)
+	if err == sql.ErrNoRows {
+		if contribution.TransferID != nil {
+			return nil, fmt.Errorf("transfer with id %d is already linked to a goal: %w", *contribution.TransferID, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("expense with id %d is already linked to a goal: %w", *contribution.ExpenseID, ErrAlreadyExists)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save goal contribution: ", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit goal contribution: ", err)
+		return nil, err
+	}
+	goal.CurrentAmount += contribution.Amount
+	return goal, nil
+}
+
+const goalContributionColumns = `gc.id, gc.goal_id, gc.user_id, gc.amount, g.currency, gc.date, gc.note, gc.expense_id, gc.transfer_id, gc.created_at`
+
+func scanGoalContribution(row rowScanner) (*models.GoalContribution, error) {
+	var c models.GoalContribution
+	var expenseID, transferID sql.NullInt64
+	err := row.Scan(&c.ID, &c.GoalID, &c.UserID, &c.Amount, &c.Currency, &c.Date, &c.Note, &expenseID, &transferID, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expenseID.Valid {
+		c.ExpenseID = &expenseID.Int64
+	}
+	if transferID.Valid {
+		c.TransferID = &transferID.Int64
+	}
+	return &c, nil
+}
+
+// GetGoalContributions возвращает историю взносов в цель, начиная с последних.
+func (r *Repository) GetGoalContributions(ctx context.Context, goalID, userID int64) ([]models.GoalContribution, error) {
+	if _, err := r.GetGoal(ctx, goalID, userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + goalContributionColumns + `
+		FROM goal_contributions gc
+		JOIN goals g ON g.id = gc.goal_id
+		WHERE gc.goal_id = $1 AND g.user_id = $2
+		ORDER BY gc.date DESC, gc.id DESC`
+	rows, err := r.db.QueryContext(ctx, query, goalID, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get goal contributions: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contributions []models.GoalContribution
+	for rows.Next() {
+		c, err := scanGoalContribution(rows)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan goal contribution: ", err)
+			return nil, err
+		}
+		contributions = append(contributions, *c)
+	}
+	return contributions, nil
+}
+
+func (r *Repository) DeleteGoalContribution(ctx context.Context, goalID, id, userID int64) error {
+	query := `
+		DELETE FROM goal_contributions gc USING goals g
+		WHERE gc.id = $1 AND gc.goal_id = $2 AND g.id = gc.goal_id AND g.user_id = $3`
+	result, err := r.db.ExecContext(ctx, query, id, goalID, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to delete goal contribution: ", err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no contribution found with id %d in goal %d for user %d: %w", id, goalID, userID, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var goalRowColumns = []string{"id", "user_id", "name", "target_amount", "current_amount", "currency", "deadline", "created_at"}
+
+func TestAddGoalContribution(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	deadline := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Manual Contribution", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM goals WHERE id = \$1 AND user_id = \$2 FOR UPDATE`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery(`SELECT g.id, .*SUM\(gc.amount\) FROM goal_contributions gc`).
+			WithArgs(int64(3)).
+			WillReturnRows(sqlmock.NewRows(goalRowColumns).AddRow(3, 1, "Vacation", "100000.00", "95000.00", "RUB", deadline, deadline))
+		mock.ExpectQuery(`INSERT INTO goal_contributions`).
+			WithArgs(int64(3), int64(1), "5000.00", date, "July", nil, nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectCommit()
+
+		contribution := &models.GoalContribution{GoalID: 3, UserID: 1, Amount: money.MustParse("5000"), Date: date, Note: "July", CreatedAt: time.Now()}
+		goal, err := repo.AddGoalContribution(context.Background(), contribution)
+		require.NoError(t, err)
+		assert.Equal(t, int64(11), contribution.ID)
+		assert.Equal(t, "RUB", contribution.Currency)
+		assert.Equal(t, money.MustParse("100000"), goal.CurrentAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Linked Expense In Other Currency", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM goals WHERE id = \$1 AND user_id = \$2 FOR UPDATE`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery(`SELECT g.id, `).
+			WithArgs(int64(3)).
+			WillReturnRows(sqlmock.NewRows(goalRowColumns).AddRow(3, 1, "Vacation", "100000.00", "0", "RUB", deadline, deadline))
+		mock.ExpectQuery(`SELECT e.currency, ROUND\(e.amount \* .*FROM expenses e WHERE e.id = \$1 AND e.user_id = \$2`).
+			WithArgs(int64(42), int64(1), "RUB").
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "amount", "date"}).AddRow("USD", "9050.00", date))
+		mock.ExpectQuery(`INSERT INTO goal_contributions`).
+			WithArgs(int64(3), int64(1), "9050.00", date, "", int64(42), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+		mock.ExpectCommit()
+
+		contribution := &models.GoalContribution{GoalID: 3, UserID: 1, ExpenseID: int64Ptr(42), CreatedAt: time.Now()}
+		goal, err := repo.AddGoalContribution(context.Background(), contribution)
+		require.NoError(t, err)
+		assert.Equal(t, money.MustParse("9050"), contribution.Amount)
+		assert.Equal(t, date, contribution.Date)
+		assert.Equal(t, money.MustParse("9050"), goal.CurrentAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Linked Transfer", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM goals`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery(`SELECT g.id, `).
+			WithArgs(int64(3)).
+			WillReturnRows(sqlmock.NewRows(goalRowColumns).AddRow(3, 1, "Vacation", "100000.00", "0", "RUB", deadline, deadline))
+		mock.ExpectQuery(`SELECT l.currency, ROUND\(l.amount \* .*FROM transfers t\s+JOIN transfer_legs d ON d.transfer_id = t.id AND d.amount > 0`).
+			WithArgs(int64(8), int64(1), "RUB").
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "amount", "date"}).AddRow("RUB", "15000.00", date))
+		mock.ExpectQuery(`INSERT INTO goal_contributions`).
+			WithArgs(int64(3), int64(1), "15000.00", date, "", nil, int64(8), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(13))
+		mock.ExpectCommit()
+
+		contribution := &models.GoalContribution{GoalID: 3, UserID: 1, TransferID: int64Ptr(8), CreatedAt: time.Now()}
+		goal, err := repo.AddGoalContribution(context.Background(), contribution)
+		require.NoError(t, err)
+		assert.Equal(t, money.MustParse("15000"), contribution.Amount)
+		assert.Equal(t, money.MustParse("15000"), goal.CurrentAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Expense Already Linked", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM goals`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery(`SELECT g.id, `).
+			WithArgs(int64(3)).
+			WillReturnRows(sqlmock.NewRows(goalRowColumns).AddRow(3, 1, "Vacation", "100000.00", "9050.00", "RUB", deadline, deadline))
+		mock.ExpectQuery(`SELECT e.currency`).
+			WithArgs(int64(42), int64(1), "RUB").
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "amount", "date"}).AddRow("RUB", "9050.00", date))
+		mock.ExpectQuery(`INSERT INTO goal_contributions`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		_, err := repo.AddGoalContribution(context.Background(), &models.GoalContribution{GoalID: 3, UserID: 1, ExpenseID: int64Ptr(42), CreatedAt: time.Now()})
+		assert.ErrorIs(t, err, ErrAlreadyExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Foreign Goal", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM goals`).
+			WithArgs(int64(3), int64(2)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		_, err := repo.AddGoalContribution(context.Background(), &models.GoalContribution{GoalID: 3, UserID: 2, Amount: money.MustParse("10"), Date: date})
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateGoal(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	deadline := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Keeps Currency", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT currency, EXISTS\(SELECT 1 FROM goal_contributions`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "exists"}).AddRow("EUR", true))
+		mock.ExpectExec(`UPDATE goals SET name=\$1, target_amount=\$2, currency=\$3, deadline=\$4`).
+			WithArgs("Car", "2000000.00", "EUR", deadline, int64(3), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.UpdateGoal(context.Background(), 3, 1, &models.Goal{Name: "Car", TargetAmount: money.MustParse("2000000"), Deadline: deadline})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Currency Locked By Contributions", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT currency, EXISTS`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "exists"}).AddRow("EUR", true))
+		mock.ExpectRollback()
+
+		err := repo.UpdateGoal(context.Background(), 3, 1, &models.Goal{Name: "Car", TargetAmount: money.MustParse("2000000"), Currency: "RUB", Deadline: deadline})
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
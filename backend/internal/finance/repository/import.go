@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"fmt"
+	"time"
+)
+
+// TransactionFingerprints возвращает отпечатки доходов и расходов пользователя за период
+// с числом транзакций для каждого отпечатка. Используется для поиска дубликатов при импорте.
+func (r *Repository) TransactionFingerprints(ctx context.Context, userID int64, from, to time.Time) (map[string]int, error) {
+	query := `
+		SELECT 'income', date, amount, COALESCE(description, '') FROM incomes WHERE user_id = $1 AND date BETWEEN $2 AND $3
+		UNION ALL
+		SELECT 'expense', date, amount, COALESCE(description, '') FROM expenses WHERE user_id = $1 AND date BETWEEN $2 AND $3`
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get transaction fingerprints: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	fingerprints := make(map[string]int)
+	for rows.Next() {
+		var tx models.Transaction
+		if err := rows.Scan(&tx.Type, &tx.Date, &tx.Amount, &tx.Description); err != nil {
+			logger.ErrorContext(ctx, "Failed to scan transaction fingerprint: ", err)
+			return nil, err
+		}
+		fingerprints[models.TransactionFingerprint(tx.Type, tx.Date, tx.Amount, tx.Description)]++
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorContext(ctx, "Failed to iterate transaction fingerprints: ", err)
+		return nil, err
+	}
+	return fingerprints, nil
+}
+
+// ImportTransactions сохраняет импортированные транзакции в одной транзакции БД:
+// если хотя бы одна не проходит проверку, не сохраняется ни одна.
+func (r *Repository) ImportTransactions(ctx context.Context, userID int64, transactions []models.Transaction) ([]int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, len(transactions))
+	for i := range transactions {
+		t := &transactions[i]
+		save := saveIncome
+		if t.Type == "expense" {
+			save = saveExpense
+		}
+		id, err := save(ctx, tx, userID, t)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i+1, err)
+		}
+		t.ID = id
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit import: ", err)
+		return nil, err
+	}
+	return ids, nil
+}
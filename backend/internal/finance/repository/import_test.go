@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionFingerprints(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 31, 0, 0, 0, 0, time.UTC)
+	day := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM incomes WHERE user_id = \$1 AND date BETWEEN \$2 AND \$3\s+UNION ALL\s+.*FROM expenses WHERE user_id = \$1`).
+		WithArgs(int64(1), from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "date", "amount", "description"}).
+			AddRow("expense", day, "3.50", "Coffee").
+			AddRow("expense", day, "3.50", "coffee ").
+			AddRow("income", day, "100.00", ""))
+
+	fingerprints, err := repo.TransactionFingerprints(context.Background(), 1, from, to)
+	require.NoError(t, err)
+	assert.Len(t, fingerprints, 2)
+	assert.Equal(t, 2, fingerprints[models.TransactionFingerprint("expense", day, money.MustParse("3.50"), "Coffee")])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportTransactions(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	day := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+	transactions := func() []models.Transaction {
+		return []models.Transaction{
+			{Type: "expense", Amount: money.MustParse("3.50"), Currency: "RUB", CategoryID: 2, Description: "Coffee", Date: day},
+			{Type: "income", Amount: money.MustParse("100"), Currency: "RUB", CategoryID: 1, Description: "Refund", Date: day},
+		}
+	}
+	expectCategory := func(id int64, exists bool) {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories`).
+			WithArgs(id, int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+	}
+
+	t.Run("All Rows In One Transaction", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCategory(2, true)
+		mock.ExpectQuery(`INSERT INTO expenses`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		expectCategory(1, true)
+		mock.ExpectQuery(`INSERT INTO incomes`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectCommit()
+
+		txs := transactions()
+		ids, err := repo.ImportTransactions(context.Background(), 1, txs)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{10, 11}, ids)
+		assert.Equal(t, int64(11), txs[1].ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rolled Back On Invalid Row", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCategory(2, true)
+		mock.ExpectQuery(`INSERT INTO expenses`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		expectCategory(1, false)
+		mock.ExpectRollback()
+
+		_, err := repo.ImportTransactions(context.Background(), 1, transactions())
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
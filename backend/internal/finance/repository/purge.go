@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+)
+
+// PurgeUserData удаляет все данные пользователя в finance-service внутри транзакции tx,
+// которую открывает и завершает вызывающий код. Записи удаляются в порядке внешних ключей;
+// взносы в цели, движения по переводам и служебные записи шаблонов и правил удаляются каскадно.
+func (r *Repository) PurgeUserData(ctx context.Context, tx *sql.Tx, userID int64) error {
+	steps := []struct{ name, query string }{
+		{"goals", `DELETE FROM goals WHERE user_id = $1`},
+		{"recurring rules", `DELETE FROM recurring_rules WHERE user_id = $1`},
+		{"budgets", `DELETE FROM budgets WHERE user_id = $1`},
+		{"budget templates", `DELETE FROM budget_templates WHERE user_id = $1`},
+		{"incomes", `DELETE FROM incomes WHERE user_id = $1`},
+		{"expenses", `DELETE FROM expenses WHERE user_id = $1`},
+		{"transfers", `DELETE FROM transfers WHERE user_id = $1`},
+		{"accounts", `DELETE FROM accounts WHERE user_id = $1`},
+		{"subcategories", `DELETE FROM subcategories WHERE user_id = $1 OR category_id IN (SELECT id FROM categories WHERE user_id = $1)`},
+		{"categories", `DELETE FROM categories WHERE user_id = $1`},
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, userID); err != nil {
+			logger.ErrorContext(ctx, "Failed to purge ", step.name, ": ", err)
+			return err
+		}
+	}
+	return nil
+}
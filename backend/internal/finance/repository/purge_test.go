@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeUserData(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("Deletes In Foreign Key Order", func(t *testing.T) {
+		mock.ExpectBegin()
+		for _, table := range []string{"goals", "recurring_rules", "budgets", "budget_templates", "incomes", "expenses", "transfers", "accounts", "subcategories", "categories"} {
+			mock.ExpectExec(`DELETE FROM ` + table + ` WHERE user_id = \$1`).
+				WithArgs(int64(1)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, repo.PurgeUserData(context.Background(), tx, 1))
+		require.NoError(t, tx.Commit())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stops On Error", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM goals`).WillReturnError(errors.New("db error"))
+		mock.ExpectRollback()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		assert.Error(t, repo.PurgeUserData(context.Background(), tx, 1))
+		require.NoError(t, tx.Rollback())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const recurringRuleColumns = `id, user_id, type, amount, currency, category_id, subcategory_id, description, tags, note,
+	frequency, repeat_interval, start_date, end_date, max_count, occurrence_count, next_date, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecurringRule(row rowScanner) (*models.RecurringRule, error) {
+	var rule models.RecurringRule
+	var subcategoryID sql.NullInt64
+	var maxCount sql.NullInt64
+	var endDate, nextDate sql.NullTime
+	var tags pq.StringArray
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Type, &rule.Amount, &rule.Currency, &rule.CategoryID, &subcategoryID,
+		&rule.Description, &tags, &rule.Note, &rule.Frequency, &rule.Interval, &rule.StartDate, &endDate, &maxCount,
+		&rule.OccurrenceCount, &nextDate, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if subcategoryID.Valid {
+		val := subcategoryID.Int64
+		rule.SubcategoryID = &val
+	}
+	if endDate.Valid {
+		rule.EndDate = &endDate.Time
+	}
+	if maxCount.Valid {
+		val := int(maxCount.Int64)
+		rule.Count = &val
+	}
+	if nextDate.Valid {
+		rule.NextDate = &nextDate.Time
+	}
+	rule.Tags = tags
+	return &rule, nil
+}
+
+// recurringRuleTransaction строит транзакцию для повторения правила на дату date.
+func recurringRuleTransaction(rule *models.RecurringRule, date time.Time) *models.Transaction {
+	return &models.Transaction{
+		UserID:        rule.UserID,
+		Type:          rule.Type,
+		Amount:        rule.Amount,
+		Currency:      rule.Currency,
+		CategoryID:    rule.CategoryID,
+		SubcategoryID: rule.SubcategoryID,
+		Description:   rule.Description,
+		Tags:          rule.Tags,
+		Date:          date,
+		Note:          rule.Note,
+	}
+}
+
+func (r *Repository) SaveRecurringRule(ctx context.Context, rule *models.RecurringRule) (int64, error) {
+	if err := validateTransactionCategories(ctx, r.db, rule.UserID, recurringRuleTransaction(rule, rule.StartDate)); err != nil {
+		return 0, err
+	}
+
+	rule.OccurrenceCount = 0
+	rule.NextDate = nil
+	if first := rule.Occurrence(0); !rule.Finished(0, first) {
+		rule.NextDate = &first
+	}
+
+	query := `
+		INSERT INTO recurring_rules (user_id, type, amount, currency, category_id, subcategory_id, description, tags, note,
+			frequency, repeat_interval, start_date, end_date, max_count, occurrence_count, next_date, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id`
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, rule.UserID, rule.Type, rule.Amount, rule.Currency, rule.CategoryID, rule.SubcategoryID,
+		rule.Description, pq.Array(rule.Tags), rule.Note, rule.Frequency, rule.Interval, rule.StartDate, rule.EndDate,
+		rule.Count, rule.OccurrenceCount, rule.NextDate, rule.CreatedAt).Scan(&id)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save recurring rule: ", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *Repository) GetRecurringRules(ctx context.Context, userID int64) ([]models.RecurringRule, error) {
+	query := `SELECT ` + recurringRuleColumns + ` FROM recurring_rules WHERE user_id = $1 ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get recurring rules: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.RecurringRule
+	for rows.Next() {
+		rule, err := scanRecurringRule(rows)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan recurring rule: ", err)
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, nil
+}
+
+func (r *Repository) GetRecurringRule(ctx context.Context, id, userID int64) (*models.RecurringRule, error) {
+	query := `SELECT ` + recurringRuleColumns + ` FROM recurring_rules WHERE id = $1 AND user_id = $2`
+	rule, err := scanRecurringRule(r.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no recurring rule found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get recurring rule: ", err)
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateRecurringRule меняет правило. Расписание пересчитывается так, чтобы следующее
+// повторение шло строго после последнего уже созданного, и повторы не дублировались.
+// Пустая валюта оставляет текущую.
+func (r *Repository) UpdateRecurringRule(ctx context.Context, rule *models.RecurringRule) error {
+	if err := validateTransactionCategories(ctx, r.db, rule.UserID, recurringRuleTransaction(rule, rule.StartDate)); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var currency string
+	err = tx.QueryRowContext(ctx, `SELECT currency FROM recurring_rules WHERE id = $1 AND user_id = $2 FOR UPDATE`, rule.ID, rule.UserID).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no recurring rule found with id %d for user %d: %w", rule.ID, rule.UserID, ErrNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to lock recurring rule: ", err)
+		return err
+	}
+	if rule.Currency == "" {
+		rule.Currency = currency
+	}
+
+	var lastOccurrence sql.NullTime
+	err = tx.QueryRowContext(ctx, `SELECT MAX(occurrence_date) FROM recurring_occurrences WHERE rule_id = $1`, rule.ID).Scan(&lastOccurrence)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get last occurrence: ", err)
+		return err
+	}
+
+	after := rule.StartDate.AddDate(0, 0, -1)
+	if lastOccurrence.Valid {
+		after = lastOccurrence.Time
+	}
+	n, next, ok := rule.NextAfter(after)
+	rule.OccurrenceCount = n
+	rule.NextDate = nil
+	if ok {
+		rule.NextDate = &next
+	}
+
+	query := `
+		UPDATE recurring_rules SET type=$1, amount=$2, currency=$3, category_id=$4, subcategory_id=$5, description=$6,
+			tags=$7, note=$8, frequency=$9, repeat_interval=$10, start_date=$11, end_date=$12, max_count=$13,
+			occurrence_count=$14, next_date=$15
+		WHERE id=$16 AND user_id=$17`
+	_, err = tx.ExecContext(ctx, query, rule.Type, rule.Amount, rule.Currency, rule.CategoryID, rule.SubcategoryID, rule.Description,
+		pq.Array(rule.Tags), rule.Note, rule.Frequency, rule.Interval, rule.StartDate, rule.EndDate, rule.Count,
+		rule.OccurrenceCount, rule.NextDate, rule.ID, rule.UserID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to update recurring rule: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit recurring rule update: ", err)
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) DeleteRecurringRule(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM recurring_rules WHERE id=$1 AND user_id=$2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to delete recurring rule: ", err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no recurring rule found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	return nil
+}
+
+// GetDueRecurringRuleIDs возвращает правила, у которых следующее повторение наступило к дате today.
+func (r *Repository) GetDueRecurringRuleIDs(ctx context.Context, today time.Time) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM recurring_rules WHERE next_date <= $1 ORDER BY next_date, id`, today)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get due recurring rules: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			logger.ErrorContext(ctx, "Failed to scan recurring rule id: ", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// MaterializeNextOccurrence создаёт транзакцию для очередного наступившего повторения правила
+// и сдвигает расписание. Всё выполняется в одной транзакции с блокировкой правила, а повторение
+// фиксируется в recurring_occurrences по ключу (rule_id, occurrence_date), поэтому одновременный
+// запуск нескольких экземпляров или перезапуск сервиса не создаёт дублей.
+// Возвращает созданную транзакцию (nil, если повторение уже было создано) и признак того,
+// что повторение было обработано и стоит проверить правило ещё раз.
+func (r *Repository) MaterializeNextOccurrence(ctx context.Context, ruleID int64, today time.Time) (*models.Transaction, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + recurringRuleColumns + ` FROM recurring_rules WHERE id = $1 FOR UPDATE SKIP LOCKED`
+	rule, err := scanRecurringRule(tx.QueryRowContext(ctx, query, ruleID))
+	if err == sql.ErrNoRows {
+		// Правило удалено или его обрабатывает другой экземпляр сервиса
+		return nil, false, nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to lock recurring rule: ", err)
+		return nil, false, err
+	}
+	if rule.NextDate == nil || rule.NextDate.After(today) {
+		return nil, false, nil
+	}
+	date := *rule.NextDate
+
+	var created *models.Transaction
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO recurring_occurrences (rule_id, occurrence_date) VALUES ($1, $2)
+		ON CONFLICT (rule_id, occurrence_date) DO NOTHING`, rule.ID, date)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to record recurring occurrence: ", err)
+		return nil, false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return nil, false, err
+	}
+	if inserted > 0 {
+		created = recurringRuleTransaction(rule, date)
+		if rule.Type == "expense" {
+			created.ID, err = saveExpense(ctx, tx, rule.UserID, created)
+		} else {
+			created.ID, err = saveIncome(ctx, tx, rule.UserID, created)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE recurring_occurrences SET transaction_id = $1 WHERE rule_id = $2 AND occurrence_date = $3`,
+			created.ID, rule.ID, date)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to link recurring occurrence: ", err)
+			return nil, false, err
+		}
+	}
+
+	count := rule.OccurrenceCount + 1
+	var nextDate *time.Time
+	if next := rule.Occurrence(count); !rule.Finished(count, next) {
+		nextDate = &next
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE recurring_rules SET occurrence_count = $1, next_date = $2 WHERE id = $3`, count, nextDate, rule.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to advance recurring rule: ", err)
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit recurring occurrence: ", err)
+		return nil, false, err
+	}
+	return created, true, nil
+}
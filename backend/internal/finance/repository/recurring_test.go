@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recurringRuleRows(nextDate interface{}, occurrenceCount int) *sqlmock.Rows {
+	columns := []string{"id", "user_id", "type", "amount", "currency", "category_id", "subcategory_id", "description", "tags", "note",
+		"frequency", "repeat_interval", "start_date", "end_date", "max_count", "occurrence_count", "next_date", "created_at"}
+	start := time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows(columns).AddRow(4, 1, "income", "150000.00", "RUB", 3, nil, "Salary", "{}", "",
+		"monthly", 1, start, nil, 2, occurrenceCount, nextDate, start)
+}
+
+func TestMaterializeNextOccurrence(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	today := time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)
+	july := time.Date(2025, 7, 5, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Creates Income", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM recurring_rules WHERE id = \$1 FOR UPDATE SKIP LOCKED`).
+			WithArgs(int64(4)).
+			WillReturnRows(recurringRuleRows(june, 0))
+		mock.ExpectExec(`INSERT INTO recurring_occurrences`).
+			WithArgs(int64(4), june).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`INSERT INTO incomes`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+		mock.ExpectExec(`UPDATE recurring_occurrences SET transaction_id = \$1`).
+			WithArgs(int64(42), int64(4), june).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE recurring_rules SET occurrence_count = \$1, next_date = \$2 WHERE id = \$3`).
+			WithArgs(1, july, int64(4)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		created, processed, err := repo.MaterializeNextOccurrence(context.Background(), 4, today)
+		require.NoError(t, err)
+		assert.True(t, processed)
+		require.NotNil(t, created)
+		assert.Equal(t, int64(42), created.ID)
+		assert.Equal(t, june, created.Date)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Already Materialized", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM recurring_rules WHERE id = \$1 FOR UPDATE SKIP LOCKED`).
+			WithArgs(int64(4)).
+			WillReturnRows(recurringRuleRows(july, 1))
+		mock.ExpectExec(`INSERT INTO recurring_occurrences`).
+			WithArgs(int64(4), july).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		// Лимит в два повторения исчерпан, следующая дата сбрасывается
+		mock.ExpectExec(`UPDATE recurring_rules SET occurrence_count = \$1, next_date = \$2 WHERE id = \$3`).
+			WithArgs(2, nil, int64(4)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		created, processed, err := repo.MaterializeNextOccurrence(context.Background(), 4, today)
+		require.NoError(t, err)
+		assert.True(t, processed)
+		assert.Nil(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not Due Yet", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM recurring_rules WHERE id = \$1 FOR UPDATE SKIP LOCKED`).
+			WithArgs(int64(4)).
+			WillReturnRows(recurringRuleRows(time.Date(2025, 8, 5, 0, 0, 0, 0, time.UTC), 2))
+		mock.ExpectRollback()
+
+		created, processed, err := repo.MaterializeNextOccurrence(context.Background(), 4, today)
+		require.NoError(t, err)
+		assert.False(t, processed)
+		assert.Nil(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -2,363 +2,783 @@ package repository
 
 import (
 	"budgetbuddy/internal/finance/models"
-	"budgetbuddy/pkg/config"
 	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/money"
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
+var (
+	// ErrNotFound возвращается, когда запись не найдена или принадлежит другому пользователю.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidReference возвращается, когда запись ссылается на несуществующую категорию или подкатегорию.
+	ErrInvalidReference = errors.New("invalid reference")
+	// ErrInvalidCursor возвращается при передаче повреждённого курсора пагинации.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrMissingExchangeRate возвращается, когда сумму нельзя пересчитать в базовую валюту.
+	ErrMissingExchangeRate = errors.New("missing exchange rate")
+	// ErrAlreadyExists возвращается при нарушении уникальности, например второго бюджета категории за месяц.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrConflict возвращается, когда изменение противоречит текущему состоянию записи.
+	ErrConflict = errors.New("conflict")
+	// ErrForbidden возвращается при попытке изменить системную запись, общую для всех пользователей.
+	ErrForbidden = errors.New("forbidden")
+)
+
 type Repository struct {
 	db *sql.DB
 }
 
-func NewRepository(cfg *config.Config) (*Repository, error) {
-	db, err := sql.Open("postgres", cfg.DBUrl)
-	if err != nil {
-		return nil, err
-	}
-	return &Repository{db: db}, nil
+// querier — общий интерфейс *sql.DB и *sql.Tx, чтобы одни и те же запросы
+// можно было выполнять как отдельно, так и внутри транзакции.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-func (r *Repository) Close() {
-	r.db.Close()
+// NewRepository создаёт репозиторий поверх общего пула соединений; закрывает пул его владелец.
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
 }
 
-func (r *Repository) SaveBudget(budget *models.Budget) (int64, error) {
+func (r *Repository) SaveBudget(ctx context.Context, budget *models.Budget) (int64, error) {
+	if err := validateBudgetCategory(ctx, r.db, budget.UserID, budget.CategoryID); err != nil {
+		return 0, err
+	}
+
 	query := `
-		INSERT INTO budgets (user_id, category_id, amount, month, created_at)
-		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		INSERT INTO budgets (user_id, category_id, amount, currency, month, rollover, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, category_id, month) DO NOTHING
+		RETURNING id`
 	var id int64
-	err := r.db.QueryRow(query, budget.UserID, budget.CategoryID, budget.Amount, budget.Month, budget.CreatedAt).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, budget.UserID, budget.CategoryID, budget.Amount, budget.Currency, budget.Month, budget.Rollover, budget.CreatedAt).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("budget for category %d in %s already exists: %w", budget.CategoryID, budget.Month, ErrAlreadyExists)
+	}
 	if err != nil {
-		logger.Error("Failed to save budget: ", err)
+		logger.ErrorContext(ctx, "Failed to save budget: ", err)
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *Repository) GetBudgets(userID int64, month string) ([]models.Budget, error) {
-	query := `
-		SELECT id, user_id, category_id, amount, month, created_at
-		FROM budgets WHERE user_id = $1 AND month = $2`
-	rows, err := r.db.Query(query, userID, month)
+// GetBudgets возвращает бюджеты месяца вместе с бюджетами по шаблонам и перенесёнными остатками.
+// Чтение ничего не меняет в базе.
+func (r *Repository) GetBudgets(ctx context.Context, userID int64, month string) ([]models.Budget, error) {
+	query := budgetPlanSQL + `
+		SELECT ` + budgetColumns + `
+		FROM chain WHERE month = $2
+		ORDER BY id = 0, id, category_id`
+	rows, err := r.db.QueryContext(ctx, query, userID, month)
 	if err != nil {
-		logger.Error("Failed to get budgets: ", err)
+		logger.ErrorContext(ctx, "Failed to get budgets: ", err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	var budgets []models.Budget
 	for rows.Next() {
-		var b models.Budget
-		err := rows.Scan(&b.ID, &b.UserID, &b.CategoryID, &b.Amount, &b.Month, &b.CreatedAt)
+		b, err := scanBudget(rows)
 		if err != nil {
-			logger.Error("Failed to scan budget: ", err)
+			logger.ErrorContext(ctx, "Failed to scan budget: ", err)
 			return nil, err
 		}
-		budgets = append(budgets, b)
+		budgets = append(budgets, *b)
 	}
 	return budgets, nil
 }
 
-func (r *Repository) CheckBudget(userID, categoryID int64, month string) (float64, float64, error) {
-	var budgetAmount float64
-	query := `SELECT amount FROM budgets WHERE user_id=$1 AND category_id=$2 AND month=$3`
-	err := r.db.QueryRow(query, userID, categoryID, month).Scan(&budgetAmount)
-	if err == sql.ErrNoRows {
-		return 0, 0, nil
+const budgetColumns = `id, user_id, category_id, amount, currency, month, rollover, rollover_amount, template_id, created_at`
+
+func scanBudget(row rowScanner) (*models.Budget, error) {
+	var b models.Budget
+	var templateID sql.NullInt64
+	err := row.Scan(&b.ID, &b.UserID, &b.CategoryID, &b.Amount, &b.Currency, &b.Month, &b.Rollover, &b.RolloverAmount, &templateID, &b.CreatedAt)
+	if err != nil {
+		return nil, err
 	}
+	if templateID.Valid {
+		b.TemplateID = &templateID.Int64
+	}
+	return &b, nil
+}
+
+// budgetStatusQuery считает расходы по каждому бюджету месяца в валюте бюджета.
+// missing — число расходов этого и предыдущих месяцев цепочки переноса, для которых
+// не нашёлся курс пересчёта.
+var budgetStatusQuery = budgetPlanSQL + `
+	SELECT b.id, b.category_id, c.name, b.month, b.currency, b.amount + b.rollover_amount, b.rollover_amount,
+		b.spent, b.missing + b.carry_missing
+	FROM chain b
+	JOIN categories c ON c.id = b.category_id
+	WHERE b.month = $2 AND ($3::bigint IS NULL OR b.category_id = $3)
+	ORDER BY c.name, b.id`
+
+// GetBudgetStatuses возвращает исполнение всех бюджетов пользователя за месяц.
+func (r *Repository) GetBudgetStatuses(ctx context.Context, userID int64, month string) ([]models.BudgetStatus, error) {
+	return r.budgetStatuses(ctx, userID, month, nil)
+}
+
+// CheckBudget возвращает исполнение бюджета категории за месяц или nil, если бюджет не задан.
+func (r *Repository) CheckBudget(ctx context.Context, userID, categoryID int64, month string) (*models.BudgetStatus, error) {
+	statuses, err := r.budgetStatuses(ctx, userID, month, &categoryID)
 	if err != nil {
-		logger.Error("Failed to query budget: ", err)
-		return 0, 0, err
+		return nil, err
 	}
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	return &statuses[0], nil
+}
 
-	var spent float64
-	query = `SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id=$1 AND category_id=$2 AND type='expense' AND to_char(date, 'YYYY-MM')=$3`
-	err = r.db.QueryRow(query, userID, categoryID, month).Scan(&spent)
+func (r *Repository) budgetStatuses(ctx context.Context, userID int64, month string, categoryID *int64) ([]models.BudgetStatus, error) {
+	rows, err := r.db.QueryContext(ctx, budgetStatusQuery, userID, month, categoryID)
 	if err != nil {
-		logger.Error("Failed to query spent amount: ", err)
-		return 0, 0, err
+		logger.ErrorContext(ctx, "Failed to get budget status: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []models.BudgetStatus
+	for rows.Next() {
+		var s models.BudgetStatus
+		var missing int
+		err := rows.Scan(&s.BudgetID, &s.CategoryID, &s.CategoryName, &s.Month, &s.Currency, &s.Budgeted, &s.Rollover, &s.Spent, &missing)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to scan budget status: ", err)
+			return nil, err
+		}
+		if missing > 0 {
+			return nil, fmt.Errorf("no exchange rate to %s for %d expenses in category %q: %w", s.Currency, missing, s.CategoryName, ErrMissingExchangeRate)
+		}
+		s.Remaining = s.Budgeted - s.Spent
+		if s.Budgeted > 0 {
+			s.Percentage = math.Round(float64(s.Spent)*10000/float64(s.Budgeted)) / 100
+		}
+		statuses = append(statuses, s)
+	}
+	if err := rows.Err(); err != nil {
+		logger.ErrorContext(ctx, "Failed to read budget status: ", err)
+		return nil, err
 	}
-	return budgetAmount, spent, nil
+	return statuses, nil
+}
+
+func (r *Repository) SaveIncome(ctx context.Context, userID int64, tx *models.Transaction) (int64, error) {
+	return saveIncome(ctx, r.db, userID, tx)
 }
 
-func (r *Repository) SaveIncome(userID int64, tx *models.Transaction) (int64, error) {
+func saveIncome(ctx context.Context, q querier, userID int64, tx *models.Transaction) (int64, error) {
+	if err := validateTransactionCategories(ctx, q, userID, tx); err != nil {
+		return 0, err
+	}
+	if err := validateTransactionAccount(ctx, q, userID, tx, nil); err != nil {
+		return 0, err
+	}
+
 	query := `
-		INSERT INTO incomes (user_id, amount, category_id, subcategory_id, description, tags, date, note)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+		INSERT INTO incomes (user_id, amount, currency, category_id, subcategory_id, account_id, description, tags, date, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
 	var id int64
-	err := r.db.QueryRow(query, userID, tx.Amount, tx.CategoryID, tx.SubcategoryID, tx.Description, pq.Array(tx.Tags), tx.Date, tx.Note).Scan(&id)
+	err := q.QueryRowContext(ctx, query, userID, tx.Amount, tx.Currency, tx.CategoryID, tx.SubcategoryID, tx.AccountID, tx.Description, pq.Array(tx.Tags), tx.Date, tx.Note).Scan(&id)
 	if err != nil {
-		logger.Error("Failed to save income: ", err)
+		logger.ErrorContext(ctx, "Failed to save income: ", err)
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *Repository) DeleteBudget(id, userID int64) error {
-	query := `DELETE FROM budgets WHERE id=$1 AND user_id=$2`
-	result, err := r.db.Exec(query, id, userID)
-	if err != nil {
-		logger.Error("Failed to delete budget: ", err)
-		return err
-	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		logger.Error("Failed to check rows affected: ", err)
+// DeleteBudget удаляет бюджет. Если бюджет создан по шаблону, месяц отмечается в
+// budget_template_months, чтобы шаблон не вернул бюджет при следующем чтении.
+func (r *Repository) DeleteBudget(ctx context.Context, id, userID int64) error {
+	query := `
+		WITH deleted AS (
+			DELETE FROM budgets WHERE id=$1 AND user_id=$2 RETURNING template_id, month
+		), skipped AS (
+			INSERT INTO budget_template_months (template_id, month)
+			SELECT template_id, month FROM deleted WHERE template_id IS NOT NULL
+			ON CONFLICT (template_id, month) DO NOTHING
+		)
+		SELECT COUNT(*) FROM deleted`
+	var deleted int64
+	if err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&deleted); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete budget: ", err)
 		return err
 	}
-	if rowsAffected == 0 {
+	if deleted == 0 {
 		return fmt.Errorf("no budget found with id %d for user %d", id, userID)
 	}
 	return nil
 }
 
-func (r *Repository) SaveExpense(userID int64, tx *models.Transaction) (int64, error) {
+// validateTransactionCategories проверяет, что категория и подкатегория транзакции существуют
+// и доступны пользователю, категория не в архиве, а подкатегория относится к выбранной категории.
+func validateTransactionCategories(ctx context.Context, q querier, userID int64, tx *models.Transaction) error {
 	var exists bool
-	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, tx.CategoryID).Scan(&exists)
+	err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND `+visibleToUserSQL("categories", "$2")+` AND NOT archived)`,
+		tx.CategoryID, userID).Scan(&exists)
 	if err != nil {
-		logger.Error("Failed to check category existence: ", err)
-		return 0, err
+		logger.ErrorContext(ctx, "Failed to check category existence: ", err)
+		return err
 	}
 	if !exists {
-		logger.Error("Category does not exist: ", tx.CategoryID)
-		return 0, fmt.Errorf("category_id %d does not exist", tx.CategoryID)
+		logger.ErrorContext(ctx, "Category does not exist or is archived: ", tx.CategoryID)
+		return fmt.Errorf("category_id %d does not exist or is archived: %w", tx.CategoryID, ErrInvalidReference)
 	}
 
 	if tx.SubcategoryID != nil {
-		err = r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM subcategories WHERE id = $1)`, *tx.SubcategoryID).Scan(&exists)
+		err = q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM subcategories WHERE id = $1 AND category_id = $2 AND `+visibleToUserSQL("subcategories", "$3")+`)`,
+			*tx.SubcategoryID, tx.CategoryID, userID).Scan(&exists)
 		if err != nil {
-			logger.Error("Failed to check subcategory existence: ", err)
-			return 0, err
+			logger.ErrorContext(ctx, "Failed to check subcategory existence: ", err)
+			return err
 		}
 		if !exists {
-			logger.Error("Subcategory does not exist: ", *tx.SubcategoryID)
-			return 0, fmt.Errorf("subcategory_id %d does not exist", *tx.SubcategoryID)
+			logger.ErrorContext(ctx, "Subcategory does not exist: ", *tx.SubcategoryID)
+			return fmt.Errorf("subcategory_id %d does not exist: %w", *tx.SubcategoryID, ErrInvalidReference)
 		}
 	}
+	return nil
+}
+
+func (r *Repository) SaveExpense(ctx context.Context, userID int64, tx *models.Transaction) (int64, error) {
+	return saveExpense(ctx, r.db, userID, tx)
+}
+
+func saveExpense(ctx context.Context, q querier, userID int64, tx *models.Transaction) (int64, error) {
+	if err := validateTransactionCategories(ctx, q, userID, tx); err != nil {
+		return 0, err
+	}
+	if err := validateTransactionAccount(ctx, q, userID, tx, nil); err != nil {
+		return 0, err
+	}
 
 	query := `
-		INSERT INTO expenses (user_id, amount, category_id, subcategory_id, description, tags, date, note)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+		INSERT INTO expenses (user_id, amount, currency, category_id, subcategory_id, account_id, description, tags, date, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
 	var id int64
-	err = r.db.QueryRow(query, userID, tx.Amount, tx.CategoryID, tx.SubcategoryID, tx.Description, pq.Array(tx.Tags), tx.Date, tx.Note).Scan(&id)
+	err := q.QueryRowContext(ctx, query, userID, tx.Amount, tx.Currency, tx.CategoryID, tx.SubcategoryID, tx.AccountID, tx.Description, pq.Array(tx.Tags), tx.Date, tx.Note).Scan(&id)
 	if err != nil {
-		logger.Error("Failed to save expense: ", err)
+		logger.ErrorContext(ctx, "Failed to save expense: ", err)
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *Repository) GetTransactions(userID int64, txType string) ([]models.Transaction, error) {
-	table := "incomes"
+// transactionTable возвращает таблицу, в которой хранятся транзакции указанного типа.
+func transactionTable(txType string) string {
 	if txType == "expense" {
-		table = "expenses"
+		return "expenses"
 	}
-	query := `
-		SELECT id, user_id, amount, category_id, subcategory_id, description, tags, date, note
-		FROM ` + table + ` WHERE user_id = $1`
-	rows, err := r.db.Query(query, userID)
+	return "incomes"
+}
+
+// Параметры сортировки и пагинации GET /transactions
+const (
+	DefaultTransactionLimit = 50
+	MaxTransactionLimit     = 500
+)
+
+// transactionCursor хранит ключ последней записи страницы для keyset-пагинации
+// вместе с сортировкой, для которой этот ключ получен.
+type transactionCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	Type  string `json:"t"`
+	ID    int64  `json:"id"`
+}
+
+func encodeTransactionCursor(c transactionCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeTransactionCursor(s string) (*transactionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
 	if err != nil {
-		logger.Error("Failed to get transactions: ", err)
-		return nil, err
+		return nil, fmt.Errorf("malformed cursor: %w", ErrInvalidCursor)
 	}
-	defer rows.Close()
+	var c transactionCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Value == "" || (c.Type != "income" && c.Type != "expense") {
+		return nil, fmt.Errorf("malformed cursor: %w", ErrInvalidCursor)
+	}
+	return &c, nil
+}
 
-	var transactions []models.Transaction
-	for rows.Next() {
-		var tx models.Transaction
-		var subcategoryID sql.NullInt64
-		var tags pq.StringArray
-		err := rows.Scan(&tx.ID, &tx.UserID, &tx.Amount, &tx.CategoryID, &subcategoryID, &tx.Description, &tags, &tx.Date, &tx.Note)
+// transactionSortKey возвращает поле сортировки GET /transactions: по умолчанию — дата.
+func transactionSortKey(filter *models.TransactionFilter) string {
+	if filter.SortBy == "amount" {
+		return "amount"
+	}
+	return "date"
+}
+
+// cursorValue проверяет, что курсор выдан для той же сортировки, и разбирает его ключ
+// в значение нужного типа, чтобы в запрос не попала произвольная строка.
+func (c *transactionCursor) cursorValue(sortKey string, desc bool) (interface{}, error) {
+	if c.Sort != sortKey || c.Desc != desc {
+		return nil, fmt.Errorf("cursor does not match sort order: %w", ErrInvalidCursor)
+	}
+	if sortKey == "amount" {
+		amount, err := money.Parse(c.Value)
 		if err != nil {
-			logger.Error("Failed to scan transaction: ", err)
-			return nil, err
-		}
-		if subcategoryID.Valid {
-			val := subcategoryID.Int64
-			tx.SubcategoryID = &val
+			return nil, fmt.Errorf("malformed cursor: %w", ErrInvalidCursor)
 		}
-		tx.Tags = tags
-		transactions = append(transactions, tx)
+		return amount, nil
+	}
+	date, err := time.Parse("2006-01-02", c.Value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", ErrInvalidCursor)
 	}
-	return transactions, nil
+	return date, nil
 }
 
-func (r *Repository) SaveGoal(userID int64, goal *models.Goal) (int64, error) {
-	query := `
-		INSERT INTO goals (user_id, name, target_amount, current_amount, deadline, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	var id int64
-	err := r.db.QueryRow(query, userID, goal.Name, goal.TargetAmount, goal.CurrentAmount, goal.Deadline, goal.CreatedAt).Scan(&id)
-	if err != nil {
-		logger.Error("Failed to save goal: ", err)
-		return 0, err
+// transactionSource возвращает подзапрос с транзакциями пользователя нужного типа.
+// Для типа "all" доходы и расходы объединяются через UNION ALL.
+func transactionSource(txType string) string {
+	const columns = `id, user_id, amount, currency, category_id, subcategory_id, account_id, description, tags, date, note`
+	income := `SELECT ` + columns + `, 'income' AS type FROM incomes WHERE user_id = $1`
+	expense := `SELECT ` + columns + `, 'expense' AS type FROM expenses WHERE user_id = $1`
+	switch txType {
+	case "income":
+		return income
+	case "expense":
+		return expense
+	default:
+		return income + ` UNION ALL ` + expense
 	}
-	return id, nil
 }
 
-func (r *Repository) UpdateGoal(id, userID int64, goal *models.Goal) error {
-	query := `
-		UPDATE goals SET name=$1, target_amount=$2, current_amount=$3, deadline=$4
-		WHERE id=$5 AND user_id=$6`
-	_, err := r.db.Exec(query, goal.Name, goal.TargetAmount, goal.CurrentAmount, goal.Deadline, id, userID)
+// transactionColumns — столбцы подзапроса transactionSource в порядке scanTransaction.
+const transactionColumns = `t.id, t.user_id, t.amount, t.currency, t.category_id, t.subcategory_id, t.account_id, t.description, t.tags, t.date, t.note, t.type`
+
+func scanTransaction(row rowScanner) (*models.Transaction, error) {
+	var tx models.Transaction
+	var subcategoryID, accountID sql.NullInt64
+	var tags pq.StringArray
+	err := row.Scan(&tx.ID, &tx.UserID, &tx.Amount, &tx.Currency, &tx.CategoryID, &subcategoryID, &accountID, &tx.Description, &tags, &tx.Date, &tx.Note, &tx.Type)
 	if err != nil {
-		logger.Error("Failed to update goal: ", err)
-		return err
+		return nil, err
 	}
-	return nil
+	if subcategoryID.Valid {
+		val := subcategoryID.Int64
+		tx.SubcategoryID = &val
+	}
+	if accountID.Valid {
+		val := accountID.Int64
+		tx.AccountID = &val
+	}
+	tx.Tags = tags
+	return &tx, nil
 }
 
-func (r *Repository) GetGoals(userID int64) ([]models.Goal, error) {
+// GetTransactions возвращает страницу транзакций пользователя по фильтру и курсор следующей страницы.
+func (r *Repository) GetTransactions(ctx context.Context, userID int64, filter *models.TransactionFilter) ([]models.Transaction, string, error) {
+	args := []interface{}{userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var conditions []string
+	if filter.From != nil {
+		conditions = append(conditions, "t.date >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "t.date <= "+arg(*filter.To))
+	}
+	if filter.CategoryID != nil {
+		conditions = append(conditions, "t.category_id = "+arg(*filter.CategoryID))
+	}
+	if filter.SubcategoryID != nil {
+		conditions = append(conditions, "t.subcategory_id = "+arg(*filter.SubcategoryID))
+	}
+	if filter.AccountID != nil {
+		conditions = append(conditions, "t.account_id = "+arg(*filter.AccountID))
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, arg(filter.Tag)+" = ANY(t.tags)")
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "t.amount >= "+arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "t.amount <= "+arg(*filter.MaxAmount))
+	}
+	if filter.Search != "" {
+		pattern := arg("%" + escapeLike(filter.Search) + "%")
+		conditions = append(conditions, "(t.description ILIKE "+pattern+" OR t.note ILIKE "+pattern+")")
+	}
+
+	sortKey := transactionSortKey(filter)
+	sortColumn, sortCast := "t.date", "date"
+	if sortKey == "amount" {
+		sortColumn, sortCast = "t.amount", "numeric"
+	}
+	direction, comparison := "ASC", ">"
+	if filter.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeTransactionCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		value, err := cursor.cursorValue(sortKey, filter.SortDesc)
+		if err != nil {
+			return nil, "", err
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, t.type, t.id) %s (%s::%s, %s, %s)",
+			sortColumn, comparison, arg(value), sortCast, arg(cursor.Type), arg(cursor.ID)))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultTransactionLimit
+	}
+	if limit > MaxTransactionLimit {
+		limit = MaxTransactionLimit
+	}
+
 	query := `
-		SELECT id, user_id, name, target_amount, current_amount, deadline, created_at
-		FROM goals WHERE user_id = $1`
-	rows, err := r.db.Query(query, userID)
+		SELECT ` + transactionColumns + `
+		FROM (` + transactionSource(filter.Type) + `) t`
+	if len(conditions) > 0 {
+		query += `
+		WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(`
+		ORDER BY %s %s, t.type %s, t.id %s
+		LIMIT %s`, sortColumn, direction, direction, direction, arg(limit+1))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		logger.Error("Failed to get goals: ", err)
-		return nil, err
+		logger.ErrorContext(ctx, "Failed to get transactions: ", err)
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var goals []models.Goal
+	var transactions []models.Transaction
 	for rows.Next() {
-		var g models.Goal
-		err := rows.Scan(&g.ID, &g.UserID, &g.Name, &g.TargetAmount, &g.CurrentAmount, &g.Deadline, &g.CreatedAt)
+		tx, err := scanTransaction(rows)
 		if err != nil {
-			logger.Error("Failed to scan goal: ", err)
-			return nil, err
+			logger.ErrorContext(ctx, "Failed to scan transaction: ", err)
+			return nil, "", err
 		}
-		goals = append(goals, g)
+		transactions = append(transactions, *tx)
 	}
-	return goals, nil
+	if err := rows.Err(); err != nil {
+		logger.ErrorContext(ctx, "Failed to iterate transactions: ", err)
+		return nil, "", err
+	}
+
+	var nextCursor string
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		last := transactions[limit-1]
+		value := last.Date.Format("2006-01-02")
+		if sortKey == "amount" {
+			value = last.Amount.String()
+		}
+		nextCursor = encodeTransactionCursor(transactionCursor{
+			Sort: sortKey, Desc: filter.SortDesc, Value: value, Type: last.Type, ID: last.ID,
+		})
+	}
+	return transactions, nextCursor, nil
 }
 
-func (r *Repository) DeleteGoal(id, userID int64) error {
-	query := `DELETE FROM goals WHERE id=$1 AND user_id=$2`
-	_, err := r.db.Exec(query, id, userID)
+// escapeLike экранирует спецсимволы шаблона LIKE.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// UpdateTransaction обновляет доход или расход, принадлежащий пользователю.
+func (r *Repository) UpdateTransaction(ctx context.Context, userID int64, txType string, tx *models.Transaction) error {
+	if err := validateTransactionCategories(ctx, r.db, userID, tx); err != nil {
+		return err
+	}
+	// Транзакция может остаться на счёте, который архивировали после её создания
+	var currentAccountID *int64
+	if tx.AccountID != nil {
+		err := r.db.QueryRowContext(ctx, `SELECT account_id FROM `+transactionTable(txType)+` WHERE id = $1 AND user_id = $2`,
+			tx.ID, userID).Scan(&currentAccountID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no %s found with id %d for user %d: %w", txType, tx.ID, userID, ErrNotFound)
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to get transaction account: ", err)
+			return err
+		}
+	}
+	if err := validateTransactionAccount(ctx, r.db, userID, tx, currentAccountID); err != nil {
+		return err
+	}
+
+	// Пустая валюта оставляет текущую; итоговая возвращается в tx.Currency
+	query := `
+		UPDATE ` + transactionTable(txType) + `
+		SET amount=$1, currency=COALESCE(NULLIF($2, ''), currency), category_id=$3, subcategory_id=$4, account_id=$5,
+			description=$6, tags=$7, date=$8, note=$9
+		WHERE id=$10 AND user_id=$11
+		RETURNING currency`
+	err := r.db.QueryRowContext(ctx, query, tx.Amount, tx.Currency, tx.CategoryID, tx.SubcategoryID, tx.AccountID, tx.Description,
+		pq.Array(tx.Tags), tx.Date, tx.Note, tx.ID, userID).Scan(&tx.Currency)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no %s found with id %d for user %d: %w", txType, tx.ID, userID, ErrNotFound)
+	}
 	if err != nil {
-		logger.Error("Failed to delete goal: ", err)
+		logger.ErrorContext(ctx, "Failed to update transaction: ", err)
 		return err
 	}
 	return nil
 }
 
-func (r *Repository) SaveCategory(category *models.Category) (int64, error) {
-	var exists bool
-	err := r.db.QueryRow(`
-		SELECT EXISTS (
-			SELECT 1 FROM categories WHERE name = $1 AND type = $2
-		)`, category.Name, category.Type).Scan(&exists)
+// DeleteTransaction удаляет доход или расход, принадлежащий пользователю.
+func (r *Repository) DeleteTransaction(ctx context.Context, userID int64, txType string, id int64) error {
+	query := `DELETE FROM ` + transactionTable(txType) + ` WHERE id=$1 AND user_id=$2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
 	if err != nil {
-		logger.Error("Failed to check category existence: ", err)
-		return 0, err
+		logger.ErrorContext(ctx, "Failed to delete transaction: ", err)
+		return err
 	}
-	if exists {
-		logger.Info("Category already exists: ", category.Name, category.Type)
-		var id int64
-		err = r.db.QueryRow(`SELECT id FROM categories WHERE name = $1 AND type = $2`, category.Name, category.Type).Scan(&id)
-		return id, nil
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no %s found with id %d for user %d: %w", txType, id, userID, ErrNotFound)
 	}
+	return nil
+}
 
-	query := `INSERT INTO categories (name, type) VALUES ($1, $2) RETURNING id`
+func (r *Repository) SaveGoal(ctx context.Context, userID int64, goal *models.Goal) (int64, error) {
+	query := `
+		INSERT INTO goals (user_id, name, target_amount, currency, deadline, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
 	var id int64
-	err = r.db.QueryRow(query, category.Name, category.Type).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, userID, goal.Name, goal.TargetAmount, goal.Currency, goal.Deadline, goal.CreatedAt).Scan(&id)
 	if err != nil {
-		logger.Error("Failed to save category: ", err)
+		logger.ErrorContext(ctx, "Failed to save goal: ", err)
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *Repository) SaveSubcategory(subcategory *models.Subcategory) (int64, error) {
-	var exists bool
-	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, subcategory.CategoryID).Scan(&exists)
+// UpdateGoal меняет название, целевую сумму, срок и валюту цели. Пустая валюта оставляет
+// текущую; сменить валюту можно только у цели без взносов, иначе возвращается ErrConflict.
+func (r *Repository) UpdateGoal(ctx context.Context, id, userID int64, goal *models.Goal) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Error("Failed to check category existence: ", err)
-		return 0, err
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
 	}
-	if !exists {
-		logger.Error("Category does not exist: ", subcategory.CategoryID)
-		return 0, fmt.Errorf("category_id %d does not exist", subcategory.CategoryID)
+	defer tx.Rollback()
+
+	var currency string
+	var hasContributions bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT currency, EXISTS(SELECT 1 FROM goal_contributions WHERE goal_id = goals.id)
+		FROM goals WHERE id = $1 AND user_id = $2 FOR UPDATE`, id, userID).Scan(&currency, &hasContributions)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no goal found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to lock goal: ", err)
+		return err
+	}
+	if goal.Currency != "" && goal.Currency != currency {
+		if hasContributions {
+			return fmt.Errorf("cannot change currency of goal %d with contributions: %w", id, ErrConflict)
+		}
+		currency = goal.Currency
 	}
 
-	query := `INSERT INTO subcategories (category_id, name) VALUES ($1, $2) RETURNING id`
-	var id int64
-	err = r.db.QueryRow(query, subcategory.CategoryID, subcategory.Name).Scan(&id)
+	query := `
+		UPDATE goals SET name=$1, target_amount=$2, currency=$3, deadline=$4
+		WHERE id=$5 AND user_id=$6`
+	_, err = tx.ExecContext(ctx, query, goal.Name, goal.TargetAmount, currency, goal.Deadline, id, userID)
 	if err != nil {
-		logger.Error("Failed to save subcategory: ", err)
-		return 0, err
+		logger.ErrorContext(ctx, "Failed to update goal: ", err)
+		return err
 	}
-	return id, nil
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit goal update: ", err)
+		return err
+	}
+	return nil
 }
 
-func (r *Repository) GetCategories(userID int64, txType string) ([]models.Category, error) {
-	query := `SELECT id, name, type FROM categories WHERE type = $1`
-	rows, err := r.db.Query(query, txType)
+// goalColumns выбирает цель вместе с накопленной суммой из взносов.
+const goalColumns = `g.id, g.user_id, g.name, g.target_amount,
+	COALESCE((SELECT SUM(gc.amount) FROM goal_contributions gc WHERE gc.goal_id = g.id), 0),
+	g.currency, g.deadline, g.created_at`
+
+func scanGoal(row rowScanner) (*models.Goal, error) {
+	var g models.Goal
+	err := row.Scan(&g.ID, &g.UserID, &g.Name, &g.TargetAmount, &g.CurrentAmount, &g.Currency, &g.Deadline, &g.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *Repository) GetGoals(ctx context.Context, userID int64) ([]models.Goal, error) {
+	query := `SELECT ` + goalColumns + ` FROM goals g WHERE g.user_id = $1 ORDER BY g.id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		logger.Error("Failed to get categories: ", err)
+		logger.ErrorContext(ctx, "Failed to get goals: ", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []models.Category
+	var goals []models.Goal
 	for rows.Next() {
-		var c models.Category
-		err := rows.Scan(&c.ID, &c.Name, &c.Type)
+		g, err := scanGoal(rows)
 		if err != nil {
-			logger.Error("Failed to scan category: ", err)
+			logger.ErrorContext(ctx, "Failed to scan goal: ", err)
 			return nil, err
 		}
-		categories = append(categories, c)
+		goals = append(goals, *g)
 	}
-	return categories, nil
+	return goals, nil
 }
 
-func (r *Repository) GetSubcategories(categoryID int64) ([]models.Subcategory, error) {
-	query := `SELECT id, category_id, name FROM subcategories WHERE category_id = $1`
-	rows, err := r.db.Query(query, categoryID)
+func (r *Repository) GetGoal(ctx context.Context, id, userID int64) (*models.Goal, error) {
+	query := `SELECT ` + goalColumns + ` FROM goals g WHERE g.id = $1 AND g.user_id = $2`
+	goal, err := scanGoal(r.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no goal found with id %d for user %d: %w", id, userID, ErrNotFound)
+	}
 	if err != nil {
-		logger.Error("Failed to get subcategories: ", err)
+		logger.ErrorContext(ctx, "Failed to get goal: ", err)
 		return nil, err
 	}
-	defer rows.Close()
+	return goal, nil
+}
 
-	var subcategories []models.Subcategory
-	for rows.Next() {
-		var s models.Subcategory
-		err := rows.Scan(&s.ID, &s.CategoryID, &s.Name)
+func (r *Repository) DeleteGoal(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM goals WHERE id=$1 AND user_id=$2`
+	_, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to delete goal: ", err)
+		return err
+	}
+	return nil
+}
+
+// exchangeRateSQL возвращает SQL-выражение курса валюты currency к валюте base на дату date.
+// Берётся последний курс, действовавший на эту дату; если задан только обратный курс, он инвертируется.
+// Если курса нет, выражение равно NULL.
+func exchangeRateSQL(currency, date, base string) string {
+	return `(CASE WHEN ` + currency + ` = ` + base + ` THEN 1 ELSE (
+			SELECT fx.rate FROM (
+				SELECT rate_date, rate FROM exchange_rates
+				WHERE base_currency = ` + currency + ` AND quote_currency = ` + base + ` AND rate_date <= ` + date + `
+				UNION ALL
+				SELECT rate_date, 1 / rate FROM exchange_rates
+				WHERE base_currency = ` + base + ` AND quote_currency = ` + currency + ` AND rate_date <= ` + date + `
+			) fx ORDER BY fx.rate_date DESC LIMIT 1) END)`
+}
+
+// convertedAmountSQL возвращает сумму транзакции alias в валюте base, округлённую до копеек.
+func convertedAmountSQL(alias, base string) string {
+	return `ROUND(` + alias + `.amount * ` + exchangeRateSQL(alias+".currency", alias+".date", base) + `, 2)`
+}
+
+// checkExchangeRates проверяет, что все транзакции пользователя за месяц (или за всё время,
+// если month пуст) можно пересчитать в базовую валюту.
+func (r *Repository) checkExchangeRates(ctx context.Context, userID int64, baseCurrency, month string) error {
+	query := `
+		SELECT t.currency, t.date
+		FROM (
+			SELECT currency, date FROM incomes WHERE user_id = $1
+			UNION ALL
+			SELECT currency, date FROM expenses WHERE user_id = $1
+		) t
+		WHERE ($3 = '' OR TO_CHAR(t.date, 'YYYY-MM') = $3)
+			AND ` + exchangeRateSQL("t.currency", "t.date", "$2") + ` IS NULL
+		LIMIT 1`
+	var currency string
+	var date time.Time
+	err := r.db.QueryRowContext(ctx, query, userID, baseCurrency, month).Scan(&currency, &date)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check exchange rates: ", err)
+		return err
+	}
+	return fmt.Errorf("no exchange rate from %s to %s on %s: %w", currency, baseCurrency, date.Format("2006-01-02"), ErrMissingExchangeRate)
+}
+
+// SaveExchangeRates сохраняет курсы валют, заменяя уже загруженные курсы на те же даты.
+func (r *Repository) SaveExchangeRates(ctx context.Context, rates []models.ExchangeRate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO exchange_rates (base_currency, quote_currency, rate_date, rate)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base_currency, quote_currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate`
+	for _, rate := range rates {
+		_, err := tx.ExecContext(ctx, query, rate.BaseCurrency, rate.QuoteCurrency, rate.Date, rate.Rate)
 		if err != nil {
-			logger.Error("Failed to scan subcategory: ", err)
-			return nil, err
+			logger.ErrorContext(ctx, "Failed to save exchange rate: ", err)
+			return err
 		}
-		subcategories = append(subcategories, s)
 	}
-	return subcategories, nil
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit exchange rates: ", err)
+		return err
+	}
+	return nil
 }
 
-func (r *Repository) SpendingByCategory(userID int64, month string) ([]Spending, error) {
+func (r *Repository) SpendingByCategory(ctx context.Context, userID int64, month, baseCurrency string) ([]Spending, error) {
+	if err := r.checkExchangeRates(ctx, userID, baseCurrency, month); err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT c.name, SUM(e.amount) as total
+		SELECT c.name, SUM(` + convertedAmountSQL("e", "$3") + `) as total
 		FROM expenses e
 		JOIN categories c ON e.category_id = c.id
 		WHERE e.user_id = $1 AND TO_CHAR(e.date, 'YYYY-MM') = $2
 		GROUP BY c.name`
-	rows, err := r.db.Query(query, userID, month)
+	rows, err := r.db.QueryContext(ctx, query, userID, month, baseCurrency)
 	if err != nil {
-		logger.Error("Failed to get spending data: ", err)
+		logger.ErrorContext(ctx, "Failed to get spending data: ", err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	var spending []Spending
 	for rows.Next() {
-		var s Spending
+		s := Spending{Currency: baseCurrency}
 		err := rows.Scan(&s.Category, &s.Total)
 		if err != nil {
-			logger.Error("Failed to scan spending data: ", err)
+			logger.ErrorContext(ctx, "Failed to scan spending data: ", err)
 			return nil, err
 		}
 		spending = append(spending, s)
@@ -367,35 +787,40 @@ func (r *Repository) SpendingByCategory(userID int64, month string) ([]Spending,
 }
 
 type Spending struct {
-	Category string  `json:"category"`
-	Total    float64 `json:"total"`
+	Category string       `json:"category"`
+	Total    money.Amount `json:"total"`
+	Currency string       `json:"currency"`
 }
 
-func (r *Repository) IncomeExpenseTrends(userID int64) ([]Trend, error) {
+func (r *Repository) IncomeExpenseTrends(ctx context.Context, userID int64, baseCurrency string) ([]Trend, error) {
+	if err := r.checkExchangeRates(ctx, userID, baseCurrency, ""); err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT TO_CHAR(date, 'YYYY-MM') as month, 
 			SUM(CASE WHEN t.table_name = 'incomes' THEN amount ELSE 0 END) as income,
 			SUM(CASE WHEN t.table_name = 'expenses' THEN amount ELSE 0 END) as expense
 		FROM (
-			SELECT date, amount, 'incomes' as table_name FROM incomes WHERE user_id = $1
+			SELECT i.date, ` + convertedAmountSQL("i", "$2") + ` as amount, 'incomes' as table_name FROM incomes i WHERE i.user_id = $1
 			UNION ALL
-			SELECT date, amount, 'expenses' as table_name FROM expenses WHERE user_id = $1
+			SELECT e.date, ` + convertedAmountSQL("e", "$2") + ` as amount, 'expenses' as table_name FROM expenses e WHERE e.user_id = $1
 		) t
 		GROUP BY TO_CHAR(date, 'YYYY-MM')
 		ORDER BY month`
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID, baseCurrency)
 	if err != nil {
-		logger.Error("Failed to get trends data: ", err)
+		logger.ErrorContext(ctx, "Failed to get trends data: ", err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	var trends []Trend
 	for rows.Next() {
-		var t Trend
+		t := Trend{Currency: baseCurrency}
 		err := rows.Scan(&t.Month, &t.Income, &t.Expense)
 		if err != nil {
-			logger.Error("Failed to scan trends data: ", err)
+			logger.ErrorContext(ctx, "Failed to scan trends data: ", err)
 			return nil, err
 		}
 		trends = append(trends, t)
@@ -404,31 +829,36 @@ func (r *Repository) IncomeExpenseTrends(userID int64) ([]Trend, error) {
 }
 
 type Trend struct {
-	Month   string  `json:"month"`
-	Income  float64 `json:"income"`
-	Expense float64 `json:"expense"`
+	Month    string       `json:"month"`
+	Income   money.Amount `json:"income"`
+	Expense  money.Amount `json:"expense"`
+	Currency string       `json:"currency"`
 }
 
-func (r *Repository) AverageSpendingByDayOfWeek(userID int64) ([]AverageSpending, error) {
+func (r *Repository) AverageSpendingByDayOfWeek(ctx context.Context, userID int64, baseCurrency string) ([]AverageSpending, error) {
+	if err := r.checkExchangeRates(ctx, userID, baseCurrency, ""); err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT EXTRACT(DOW FROM date) as day_of_week, AVG(amount) as avg_amount
-		FROM expenses
-		WHERE user_id = $1
-		GROUP BY EXTRACT(DOW FROM date)
+		SELECT EXTRACT(DOW FROM e.date) as day_of_week, ROUND(AVG(` + convertedAmountSQL("e", "$2") + `), 2) as avg_amount
+		FROM expenses e
+		WHERE e.user_id = $1
+		GROUP BY EXTRACT(DOW FROM e.date)
 		ORDER BY day_of_week`
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID, baseCurrency)
 	if err != nil {
-		logger.Error("Failed to get average spending by day of week: ", err)
+		logger.ErrorContext(ctx, "Failed to get average spending by day of week: ", err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	var spending []AverageSpending
 	for rows.Next() {
-		var s AverageSpending
+		s := AverageSpending{Currency: baseCurrency}
 		err := rows.Scan(&s.DayOfWeek, &s.AverageAmount)
 		if err != nil {
-			logger.Error("Failed to scan average spending data: ", err)
+			logger.ErrorContext(ctx, "Failed to scan average spending data: ", err)
 			return nil, err
 		}
 		spending = append(spending, s)
@@ -437,42 +867,54 @@ func (r *Repository) AverageSpendingByDayOfWeek(userID int64) ([]AverageSpending
 }
 
 type AverageSpending struct {
-	DayOfWeek     float64 `json:"day_of_week"`
-	AverageAmount float64 `json:"average_amount"`
+	DayOfWeek     float64      `json:"day_of_week"`
+	AverageAmount money.Amount `json:"average_amount"`
+	Currency      string       `json:"currency"`
 }
 
-func (r *Repository) ForecastSavings(userID, goalID int64) (float64, error) {
-	var goal models.Goal
-	err := r.db.QueryRow(`
-		SELECT target_amount, current_amount
-		FROM goals
-		WHERE id = $1 AND user_id = $2`, goalID, userID).Scan(&goal.TargetAmount, &goal.CurrentAmount)
+func (r *Repository) ForecastSavings(ctx context.Context, userID, goalID int64, baseCurrency string) (float64, error) {
+	if err := r.checkExchangeRates(ctx, userID, baseCurrency, ""); err != nil {
+		return 0, err
+	}
+
+	// Цель пересчитывается в базовую валюту по курсу на сегодня
+	var goalCurrency string
+	var target, current sql.Null[money.Amount]
+	err := r.db.QueryRowContext(ctx, `
+		SELECT g.currency,
+			ROUND(g.target_amount * `+exchangeRateSQL("g.currency", "CURRENT_DATE", "$3")+`, 2),
+			ROUND(COALESCE((SELECT SUM(gc.amount) FROM goal_contributions gc WHERE gc.goal_id = g.id), 0) * `+exchangeRateSQL("g.currency", "CURRENT_DATE", "$3")+`, 2)
+		FROM goals g
+		WHERE g.id = $1 AND g.user_id = $2`, goalID, userID, baseCurrency).Scan(&goalCurrency, &target, &current)
 	if err == sql.ErrNoRows {
-		logger.Error("Goal not found: ", goalID)
+		logger.ErrorContext(ctx, "Goal not found: ", goalID)
 		return 0, fmt.Errorf("goal with id %d does not exist for user %d", goalID, userID)
 	}
 	if err != nil {
-		logger.Error("Failed to get goal: ", err)
+		logger.ErrorContext(ctx, "Failed to get goal: ", err)
 		return 0, err
 	}
+	if !target.Valid || !current.Valid {
+		return 0, fmt.Errorf("no exchange rate from %s to %s: %w", goalCurrency, baseCurrency, ErrMissingExchangeRate)
+	}
 
 	query := `
-		SELECT COALESCE(AVG(income - expense), 0) as avg_savings
+		SELECT COALESCE(ROUND(AVG(income - expense), 2), 0) as avg_savings
 		FROM (
 			SELECT TO_CHAR(date, 'YYYY-MM') as month,
 				SUM(CASE WHEN t.table_name = 'incomes' THEN amount ELSE 0 END) as income,
 				SUM(CASE WHEN t.table_name = 'expenses' THEN amount ELSE 0 END) as expense
 			FROM (
-				SELECT date, amount, 'incomes' as table_name FROM incomes WHERE user_id = $1
+				SELECT i.date, ` + convertedAmountSQL("i", "$2") + ` as amount, 'incomes' as table_name FROM incomes i WHERE i.user_id = $1
 				UNION ALL
-				SELECT date, amount, 'expenses' as table_name FROM expenses WHERE user_id = $1
+				SELECT e.date, ` + convertedAmountSQL("e", "$2") + ` as amount, 'expenses' as table_name FROM expenses e WHERE e.user_id = $1
 			) t
 			GROUP BY TO_CHAR(date, 'YYYY-MM')
 		) monthly`
-	var avgSavings float64
-	err = r.db.QueryRow(query, userID).Scan(&avgSavings)
+	var avgSavings money.Amount
+	err = r.db.QueryRowContext(ctx, query, userID, baseCurrency).Scan(&avgSavings)
 	if err != nil {
-		logger.Error("Failed to calculate average savings: ", err)
+		logger.ErrorContext(ctx, "Failed to calculate average savings: ", err)
 		return 0, err
 	}
 
@@ -480,7 +922,7 @@ func (r *Repository) ForecastSavings(userID, goalID int64) (float64, error) {
 		return 0, fmt.Errorf("cannot achieve goal: average savings is zero or negative")
 	}
 
-	remainingAmount := goal.TargetAmount - goal.CurrentAmount
-	monthsToGoal := remainingAmount / avgSavings
+	remainingAmount := target.V - current.V
+	monthsToGoal := float64(remainingAmount) / float64(avgSavings)
 	return monthsToGoal, nil
 }
@@ -1,10 +1,15 @@
 package repository
 
 import (
-	"budgetbuddy/internal/finance/migrations"
 	"budgetbuddy/internal/finance/models"
+	"budgetbuddy/migrations"
 	"budgetbuddy/pkg/config"
+	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/money"
+	"context"
 	"database/sql"
+	"fmt"
+	"os"
 	"testing"
 	"time"
 
@@ -14,6 +19,11 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
 // Тесты с sqlmock (юнит-тесты)
 func setupTestDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
@@ -29,41 +39,37 @@ func TestSaveCategory(t *testing.T) {
 	category := &models.Category{Name: "Food", Type: "expense"}
 
 	t.Run("New Category", func(t *testing.T) {
-		mock.ExpectQuery(`.*SELECT EXISTS.*`).
-			WithArgs("Food", "expense").
-			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery(`SELECT id FROM categories\s+WHERE name = \$1 AND type = \$2 AND \(categories.user_id IS NULL OR categories.user_id = \$3\)`).
+			WithArgs("Food", "expense", int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
-		mock.ExpectQuery(`.*INSERT INTO categories.*`).
-			WithArgs("Food", "expense").
+		mock.ExpectQuery(`INSERT INTO categories \(user_id, name, type\) VALUES \(\$1, \$2, \$3\) RETURNING id`).
+			WithArgs(int64(1), "Food", "expense").
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-		id, err := repo.SaveCategory(category)
+		id, err := repo.SaveCategory(context.Background(), 1, category)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), id)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("Existing Category", func(t *testing.T) {
-		mock.ExpectQuery(`.*SELECT EXISTS.*`).
-			WithArgs("Food", "expense").
-			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
-
-		mock.ExpectQuery(`.*SELECT id FROM categories.*`).
-			WithArgs("Food", "expense").
+		mock.ExpectQuery(`SELECT id FROM categories`).
+			WithArgs("Food", "expense", int64(1)).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-		id, err := repo.SaveCategory(category)
+		id, err := repo.SaveCategory(context.Background(), 1, category)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), id)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("DB Error", func(t *testing.T) {
-		mock.ExpectQuery(`.*SELECT EXISTS.*`).
-			WithArgs("Food", "expense").
+		mock.ExpectQuery(`SELECT id FROM categories`).
+			WithArgs("Food", "expense", int64(1)).
 			WillReturnError(sql.ErrConnDone)
 
-		id, err := repo.SaveCategory(category)
+		id, err := repo.SaveCategory(context.Background(), 1, category)
 		assert.Error(t, err)
 		assert.Equal(t, int64(0), id)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -78,27 +84,27 @@ func TestSaveSubcategory(t *testing.T) {
 	subcategory := &models.Subcategory{CategoryID: 2, Name: "Groceries"}
 
 	t.Run("Valid Subcategory", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1\)`).
-			WithArgs(int64(2)).
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1 AND \(categories.user_id IS NULL OR categories.user_id = \$2\)\)`).
+			WithArgs(int64(2), int64(1)).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-		mock.ExpectQuery(`INSERT INTO subcategories \(category_id, name\) VALUES \(\$1, \$2\) RETURNING id`).
-			WithArgs(int64(2), "Groceries").
+		mock.ExpectQuery(`INSERT INTO subcategories \(category_id, user_id, name\) VALUES \(\$1, \$2, \$3\) RETURNING id`).
+			WithArgs(int64(2), int64(1), "Groceries").
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-		id, err := repo.SaveSubcategory(subcategory)
+		id, err := repo.SaveSubcategory(context.Background(), 1, subcategory)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), id)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
-	t.Run("Invalid Category", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1\)`).
-			WithArgs(int64(2)).
+	t.Run("Foreign Category", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1`).
+			WithArgs(int64(2), int64(1)).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
-		id, err := repo.SaveSubcategory(subcategory)
-		assert.Error(t, err)
+		id, err := repo.SaveSubcategory(context.Background(), 1, subcategory)
+		assert.ErrorIs(t, err, ErrInvalidReference)
 		assert.Contains(t, err.Error(), "category_id 2 does not exist")
 		assert.Equal(t, int64(0), id)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -112,7 +118,8 @@ func TestSaveExpense(t *testing.T) {
 	repo := &Repository{db: db}
 	userID := int64(1)
 	tx := &models.Transaction{
-		Amount:        200.75,
+		Amount:        money.MustParse("200.75"),
+		Currency:      "RUB",
 		CategoryID:    2,
 		SubcategoryID: int64Ptr(1),
 		Description:   "Grocery shopping",
@@ -122,30 +129,30 @@ func TestSaveExpense(t *testing.T) {
 	}
 
 	t.Run("Valid Expense", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1\)`).
-			WithArgs(int64(2)).
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1 AND \(categories.user_id IS NULL OR categories.user_id = \$2\) AND NOT archived\)`).
+			WithArgs(int64(2), userID).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM subcategories WHERE id = \$1\)`).
-			WithArgs(int64(1)).
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM subcategories WHERE id = \$1 AND category_id = \$2 AND \(subcategories.user_id IS NULL OR subcategories.user_id = \$3\)\)`).
+			WithArgs(int64(1), int64(2), userID).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-		mock.ExpectQuery(`INSERT INTO expenses \(user_id, amount, category_id, subcategory_id, description, tags, date, note\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8\) RETURNING id`).
-			WithArgs(userID, 200.75, int64(2), int64(1), "Grocery shopping", pq.Array([]string{"food", "expense"}), tx.Date, "Weekly groceries").
+		mock.ExpectQuery(`INSERT INTO expenses \(user_id, amount, currency, category_id, subcategory_id, account_id, description, tags, date, note\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10\) RETURNING id`).
+			WithArgs(userID, "200.75", "RUB", int64(2), int64(1), nil, "Grocery shopping", pq.Array([]string{"food", "expense"}), tx.Date, "Weekly groceries").
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-		id, err := repo.SaveExpense(userID, tx)
+		id, err := repo.SaveExpense(context.Background(), userID, tx)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), id)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("Invalid Category", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1\)`).
-			WithArgs(int64(2)).
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1 AND \(categories.user_id IS NULL OR categories.user_id = \$2\) AND NOT archived\)`).
+			WithArgs(int64(2), userID).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
-		id, err := repo.SaveExpense(userID, tx)
+		id, err := repo.SaveExpense(context.Background(), userID, tx)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "category_id 2 does not exist")
 		assert.Equal(t, int64(0), id)
@@ -153,15 +160,15 @@ func TestSaveExpense(t *testing.T) {
 	})
 
 	t.Run("Invalid Subcategory", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1\)`).
-			WithArgs(int64(2)).
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1 AND \(categories.user_id IS NULL OR categories.user_id = \$2\) AND NOT archived\)`).
+			WithArgs(int64(2), userID).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM subcategories WHERE id = \$1\)`).
-			WithArgs(int64(1)).
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM subcategories WHERE id = \$1 AND category_id = \$2 AND \(subcategories.user_id IS NULL OR subcategories.user_id = \$3\)\)`).
+			WithArgs(int64(1), int64(2), userID).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
-		id, err := repo.SaveExpense(userID, tx)
+		id, err := repo.SaveExpense(context.Background(), userID, tx)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "subcategory_id 1 does not exist")
 		assert.Equal(t, int64(0), id)
@@ -169,20 +176,488 @@ func TestSaveExpense(t *testing.T) {
 	})
 }
 
+func TestUpdateTransaction(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	userID := int64(1)
+	tx := &models.Transaction{
+		ID:          5,
+		Amount:      money.MustParse("150.50"),
+		Currency:    "USD",
+		CategoryID:  2,
+		Description: "Dinner",
+		Tags:        []string{"food"},
+		Date:        time.Date(2025, 7, 3, 0, 0, 0, 0, time.UTC),
+		Note:        "Fixed amount",
+	}
+
+	t.Run("Valid Update", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1 AND \(categories.user_id IS NULL OR categories.user_id = \$2\) AND NOT archived\)`).
+			WithArgs(int64(2), userID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		mock.ExpectQuery(`UPDATE expenses\s+SET amount=\$1, currency=COALESCE\(NULLIF\(\$2, ''\), currency\), category_id=\$3, subcategory_id=\$4, account_id=\$5,\s+description=\$6, tags=\$7, date=\$8, note=\$9\s+WHERE id=\$10 AND user_id=\$11\s+RETURNING currency`).
+			WithArgs("150.50", "USD", int64(2), nil, nil, "Dinner", pq.Array([]string{"food"}), tx.Date, "Fixed amount", int64(5), userID).
+			WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("USD"))
+
+		err := repo.UpdateTransaction(context.Background(), userID, "expense", tx)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Keeps Stored Currency", func(t *testing.T) {
+		withoutCurrency := *tx
+		withoutCurrency.Currency = ""
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1`).
+			WithArgs(int64(2), userID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`UPDATE expenses`).
+			WithArgs("150.50", "", int64(2), nil, nil, "Dinner", pq.Array([]string{"food"}), tx.Date, "Fixed amount", int64(5), userID).
+			WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("EUR"))
+
+		err := repo.UpdateTransaction(context.Background(), userID, "expense", &withoutCurrency)
+		require.NoError(t, err)
+		assert.Equal(t, "EUR", withoutCurrency.Currency)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Foreign Transaction", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1 AND \(categories.user_id IS NULL OR categories.user_id = \$2\) AND NOT archived\)`).
+			WithArgs(int64(2), userID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		mock.ExpectQuery(`UPDATE incomes`).
+			WillReturnRows(sqlmock.NewRows([]string{"currency"}))
+
+		err := repo.UpdateTransaction(context.Background(), userID, "income", tx)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Category", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM categories WHERE id = \$1 AND \(categories.user_id IS NULL OR categories.user_id = \$2\) AND NOT archived\)`).
+			WithArgs(int64(2), userID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		err := repo.UpdateTransaction(context.Background(), userID, "expense", tx)
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.Contains(t, err.Error(), "category_id 2 does not exist")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteTransaction(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("Own Transaction", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM incomes WHERE id=\$1 AND user_id=\$2`).
+			WithArgs(int64(3), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.DeleteTransaction(context.Background(), 1, "income", 3)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Foreign Transaction", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM expenses WHERE id=\$1 AND user_id=\$2`).
+			WithArgs(int64(3), int64(2)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.DeleteTransaction(context.Background(), 2, "expense", 3)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetTransactions(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	columns := []string{"id", "user_id", "amount", "currency", "category_id", "subcategory_id", "account_id", "description", "tags", "date", "note", "type"}
+	day := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("First Page", func(t *testing.T) {
+		from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+		filter := &models.TransactionFilter{Type: "all", From: &from, Tag: "food", Search: "50%", SortBy: "date", SortDesc: true, Limit: 2}
+
+		mock.ExpectQuery(`FROM incomes WHERE user_id = \$1 UNION ALL .* FROM expenses WHERE user_id = \$1\) t\s+WHERE t.date >= \$2 AND \$3 = ANY\(t.tags\) AND \(t.description ILIKE \$4 OR t.note ILIKE \$4\)\s+ORDER BY t.date DESC, t.type DESC, t.id DESC\s+LIMIT \$5`).
+			WithArgs(int64(1), from, "food", `%50\%%`, 3).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(3, 1, "10.00", "RUB", 2, nil, nil, "Lunch", "{food}", day, "", "income").
+				AddRow(7, 1, "20.00", "RUB", 2, nil, nil, "Dinner", "{food}", day, "", "expense").
+				AddRow(6, 1, "30.00", "RUB", 2, nil, nil, "Snack", "{food}", day, "", "expense"))
+
+		transactions, next, err := repo.GetTransactions(context.Background(), 1, filter)
+		assert.NoError(t, err)
+		assert.Len(t, transactions, 2)
+		assert.Equal(t, "expense", transactions[1].Type)
+		assert.NotEmpty(t, next)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		cursor, err := decodeTransactionCursor(next)
+		require.NoError(t, err)
+		assert.Equal(t, transactionCursor{Sort: "date", Desc: true, Value: "2025-07-02", Type: "expense", ID: 7}, *cursor)
+	})
+
+	t.Run("Next Page By Amount", func(t *testing.T) {
+		next := encodeTransactionCursor(transactionCursor{Sort: "amount", Value: "20.5", Type: "expense", ID: 7})
+		filter := &models.TransactionFilter{Type: "expense", SortBy: "amount", Cursor: next, Limit: 10}
+
+		mock.ExpectQuery(`FROM expenses WHERE user_id = \$1\) t\s+WHERE \(t.amount, t.type, t.id\) > \(\$2::numeric, \$3, \$4\)\s+ORDER BY t.amount ASC, t.type ASC, t.id ASC\s+LIMIT \$5`).
+			WithArgs(int64(1), "20.50", "expense", int64(7), 11).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(8, 1, "25.00", "RUB", 2, nil, nil, "Taxi", "{}", day, "", "expense"))
+
+		transactions, next, err := repo.GetTransactions(context.Background(), 1, filter)
+		assert.NoError(t, err)
+		assert.Len(t, transactions, 1)
+		assert.Empty(t, next)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		filter := &models.TransactionFilter{Type: "all", Cursor: "not-a-cursor"}
+
+		_, _, err := repo.GetTransactions(context.Background(), 1, filter)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cursor From Another Sort", func(t *testing.T) {
+		// Курсор, выданный для сортировки по дате, не применяется к сортировке по сумме
+		// и к обратному порядку: запрос в базу не уходит
+		byDate := encodeTransactionCursor(transactionCursor{Sort: "date", Desc: true, Value: "2025-07-02", Type: "expense", ID: 7})
+		for _, filter := range []*models.TransactionFilter{
+			{Type: "all", SortBy: "amount", SortDesc: true, Cursor: byDate},
+			{Type: "all", SortBy: "date", SortDesc: false, Cursor: byDate},
+		} {
+			_, _, err := repo.GetTransactions(context.Background(), 1, filter)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Malformed Cursor Value", func(t *testing.T) {
+		cursor := encodeTransactionCursor(transactionCursor{Sort: "amount", Value: "1e9; DROP", Type: "expense", ID: 7})
+		filter := &models.TransactionFilter{Type: "all", SortBy: "amount", Cursor: cursor}
+
+		_, _, err := repo.GetTransactions(context.Background(), 1, filter)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSpendingByCategory(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("Converted To Base Currency", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT t.currency, t.date`).
+			WithArgs(int64(1), "RUB", "2025-07").
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "date"}))
+
+		mock.ExpectQuery(`SELECT c.name, SUM\(ROUND\(e.amount \* \(CASE WHEN e.currency = \$3 THEN 1 ELSE .*exchange_rates`).
+			WithArgs(int64(1), "2025-07", "RUB").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "total"}).AddRow("Food", "1570.00"))
+
+		spending, err := repo.SpendingByCategory(context.Background(), 1, "2025-07", "RUB")
+		assert.NoError(t, err)
+		assert.Equal(t, []Spending{{Category: "Food", Total: money.MustParse("1570"), Currency: "RUB"}}, spending)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing Rate", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT t.currency, t.date`).
+			WithArgs(int64(1), "RUB", "2025-07").
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "date"}).AddRow("USD", time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)))
+
+		_, err := repo.SpendingByCategory(context.Background(), 1, "2025-07", "RUB")
+		assert.ErrorIs(t, err, ErrMissingExchangeRate)
+		assert.Contains(t, err.Error(), "from USD to RUB on 2025-07-02")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSaveBudget(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	budget := &models.Budget{
+		UserID:     1,
+		CategoryID: 2,
+		Amount:     money.MustParse("10000"),
+		Currency:   "RUB",
+		Month:      "2025-07",
+		Rollover:   true,
+		CreatedAt:  time.Now(),
+	}
+
+	t.Run("New Budget", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM categories WHERE id = \$1 AND type = 'expense' AND \(categories.user_id IS NULL OR categories.user_id = \$2\)\)`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`INSERT INTO budgets .*ON CONFLICT \(user_id, category_id, month\) DO NOTHING`).
+			WithArgs(int64(1), int64(2), "10000.00", "RUB", "2025-07", true, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+		id, err := repo.SaveBudget(context.Background(), budget)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Duplicate Month", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM categories`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`INSERT INTO budgets`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, err := repo.SaveBudget(context.Background(), budget)
+		assert.ErrorIs(t, err, ErrAlreadyExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Category", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM categories`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		_, err := repo.SaveBudget(context.Background(), budget)
+		assert.ErrorIs(t, err, ErrInvalidReference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetBudgets(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	createdAt := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	// Чтение — один SELECT без записи: шаблоны и переносы считаются в запросе
+	mock.ExpectQuery(`WITH RECURSIVE bounds AS .*FROM budget_templates t JOIN template_months m .*SELECT id, user_id, category_id, amount, currency, month, rollover, rollover_amount, template_id, created_at\s+FROM chain WHERE month = \$2`).
+		WithArgs(int64(1), "2025-07").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "category_id", "amount", "currency", "month", "rollover", "rollover_amount", "template_id", "created_at"}).
+			AddRow(5, 1, 2, "10000.00", "RUB", "2025-07", true, "-350.00", nil, createdAt).
+			AddRow(0, 1, 4, "3000.00", "RUB", "2025-07", false, "0", 7, createdAt))
+
+	budgets, err := repo.GetBudgets(context.Background(), 1, "2025-07")
+	require.NoError(t, err)
+	require.Len(t, budgets, 2)
+	assert.Equal(t, money.MustParse("-350"), budgets[0].RolloverAmount)
+	assert.Nil(t, budgets[0].TemplateID)
+	assert.Zero(t, budgets[1].ID)
+	assert.Equal(t, int64Ptr(7), budgets[1].TemplateID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCopyBudgets(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("Keep Existing", func(t *testing.T) {
+		mock.ExpectExec(`WITH RECURSIVE .*INSERT INTO budgets .*FROM plan WHERE month = \$2\s+ON CONFLICT \(user_id, category_id, month\) DO NOTHING`).
+			WithArgs(int64(1), "2025-07", "2025-08", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		copied, err := repo.CopyBudgets(context.Background(), 1, "2025-07", "2025-08", false)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), copied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		mock.ExpectExec(`ON CONFLICT \(user_id, category_id, month\) DO UPDATE SET amount = EXCLUDED.amount`).
+			WithArgs(int64(1), "2025-07", "2025-08", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+
+		copied, err := repo.CopyBudgets(context.Background(), 1, "2025-07", "2025-08", true)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), copied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteBudget(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	t.Run("Marks Template Month", func(t *testing.T) {
+		mock.ExpectQuery(`DELETE FROM budgets WHERE id=\$1 AND user_id=\$2 RETURNING template_id, month.*INSERT INTO budget_template_months`).
+			WithArgs(int64(5), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		assert.NoError(t, repo.DeleteBudget(context.Background(), 5, 1))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mock.ExpectQuery(`DELETE FROM budgets`).
+			WithArgs(int64(6), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		assert.Error(t, repo.DeleteBudget(context.Background(), 6, 1))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSaveBudgetTemplate(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	template := &models.BudgetTemplate{
+		UserID:     1,
+		CategoryID: 2,
+		Amount:     money.MustParse("10000"),
+		Currency:   "RUB",
+		Rollover:   true,
+		StartMonth: "2025-07",
+		CreatedAt:  time.Now(),
+	}
+
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM categories`).
+		WithArgs(int64(2), int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`INSERT INTO budget_templates .*ON CONFLICT \(user_id, category_id\) DO NOTHING`).
+		WithArgs(int64(1), int64(2), "10000.00", "RUB", true, "2025-07", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := repo.SaveBudgetTemplate(context.Background(), template)
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateBudgetTemplate(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+
+	// Без валюты и начального месяца в запросе остаются сохранённые
+	mock.ExpectExec(`UPDATE budget_templates SET amount=\$1, currency=COALESCE\(NULLIF\(\$2, ''\), currency\), rollover=\$3, start_month=COALESCE\(NULLIF\(\$4, ''\), start_month\)`).
+		WithArgs("500.00", "", false, "", int64(3), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.UpdateBudgetTemplate(context.Background(), &models.BudgetTemplate{
+		ID:     3,
+		UserID: 1,
+		Amount: money.MustParse("500"),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBudgetStatuses(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	columns := []string{"id", "category_id", "name", "month", "currency", "budgeted", "rollover_amount", "spent", "missing"}
+
+	t.Run("Spent Versus Limit", func(t *testing.T) {
+		mock.ExpectQuery(`WITH RECURSIVE .*FROM expenses e.*FROM chain b\s+JOIN categories c ON c.id = b.category_id`).
+			WithArgs(int64(1), "2025-07", nil).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(3, 2, "Food", "2025-07", "RUB", "10000.00", "500.00", "8500.50", 0).
+				AddRow(4, 5, "Transport", "2025-07", "RUB", "3000.00", "0.00", "0", 0))
+
+		statuses, err := repo.GetBudgetStatuses(context.Background(), 1, "2025-07")
+		require.NoError(t, err)
+		require.Len(t, statuses, 2)
+		assert.Equal(t, models.BudgetStatus{
+			BudgetID: 3, CategoryID: 2, CategoryName: "Food", Month: "2025-07", Currency: "RUB",
+			Budgeted: money.MustParse("10000"), Rollover: money.MustParse("500"), Spent: money.MustParse("8500.50"),
+			Remaining: money.MustParse("1499.50"), Percentage: 85.01,
+		}, statuses[0])
+		assert.Equal(t, money.MustParse("3000"), statuses[1].Remaining)
+		assert.Equal(t, 0.0, statuses[1].Percentage)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing Rate", func(t *testing.T) {
+		mock.ExpectQuery(`FROM chain b`).
+			WithArgs(int64(1), "2025-07", nil).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 2, "Food", "2025-07", "RUB", "10000.00", "0.00", "100.00", 1))
+
+		_, err := repo.GetBudgetStatuses(context.Background(), 1, "2025-07")
+		assert.ErrorIs(t, err, ErrMissingExchangeRate)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCheckBudget(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &Repository{db: db}
+	columns := []string{"id", "category_id", "name", "month", "currency", "budgeted", "rollover_amount", "spent", "missing"}
+
+	t.Run("Exceeded", func(t *testing.T) {
+		mock.ExpectQuery(`FROM chain b`).
+			WithArgs(int64(1), "2025-01", int64(2)).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 2, "Food", "2025-01", "RUB", "1000.00", "0.00", "1250.00", 0))
+
+		status, err := repo.CheckBudget(context.Background(), 1, 2, "2025-01")
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, money.MustParse("-250"), status.Remaining)
+		assert.Equal(t, 125.0, status.Percentage)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No Budget", func(t *testing.T) {
+		mock.ExpectQuery(`FROM chain b`).
+			WithArgs(int64(1), "2025-07", int64(9)).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		status, err := repo.CheckBudget(context.Background(), 1, 9, "2025-07")
+		assert.NoError(t, err)
+		assert.Nil(t, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 // Вспомогательная функция для указателя на int64
 func int64Ptr(i int64) *int64 {
 	return &i
 }
 
 // Интеграционные тесты с реальной базой
-func TestSaveCategoryWithDB(t *testing.T) {
+// Подключение к тестовой базе; тест пропускается, если база недоступна
+func setupIntegrationDB(t *testing.T) *sql.DB {
 	cfg := config.NewTestConfig()
 	db, err := sql.Open("postgres", cfg.DBUrl)
 	require.NoError(t, err)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		t.Skip("Test database is not available: ", err)
+	}
+	return db
+}
+
+func TestSaveCategoryWithDB(t *testing.T) {
+	db := setupIntegrationDB(t)
 	defer db.Close()
 
 	// Применяем миграции
-	err = migrations.RunMigrations(cfg)
+	err := migrations.RunMigrations(db)
 	require.NoError(t, err)
 
 	repo := &Repository{db: db}
@@ -192,7 +667,7 @@ func TestSaveCategoryWithDB(t *testing.T) {
 	_, err = db.Exec("TRUNCATE TABLE categories RESTART IDENTITY CASCADE")
 	require.NoError(t, err)
 
-	id, err := repo.SaveCategory(category)
+	id, err := repo.SaveCategory(context.Background(), 1, category)
 	assert.NoError(t, err)
 	assert.NotZero(t, id)
 
@@ -205,20 +680,18 @@ func TestSaveCategoryWithDB(t *testing.T) {
 }
 
 func TestSaveSubcategoryWithDB(t *testing.T) {
-	cfg := config.NewTestConfig()
-	db, err := sql.Open("postgres", cfg.DBUrl)
-	require.NoError(t, err)
+	db := setupIntegrationDB(t)
 	defer db.Close()
 
 	// Применяем миграции
-	err = migrations.RunMigrations(cfg)
+	err := migrations.RunMigrations(db)
 	require.NoError(t, err)
 
 	repo := &Repository{db: db}
 
 	// Создаём категорию
 	category := &models.Category{Name: "Food", Type: "expense"}
-	catID, err := repo.SaveCategory(category)
+	catID, err := repo.SaveCategory(context.Background(), 1, category)
 	require.NoError(t, err)
 
 	subcategory := &models.Subcategory{CategoryID: catID, Name: "Groceries"}
@@ -227,7 +700,7 @@ func TestSaveSubcategoryWithDB(t *testing.T) {
 	_, err = db.Exec("TRUNCATE TABLE subcategories RESTART IDENTITY CASCADE")
 	require.NoError(t, err)
 
-	id, err := repo.SaveSubcategory(subcategory)
+	id, err := repo.SaveSubcategory(context.Background(), 1, subcategory)
 	assert.NoError(t, err)
 	assert.NotZero(t, id)
 
@@ -241,13 +714,11 @@ func TestSaveSubcategoryWithDB(t *testing.T) {
 }
 
 func TestSaveExpenseWithDB(t *testing.T) {
-	cfg := config.NewTestConfig()
-	db, err := sql.Open("postgres", cfg.DBUrl)
-	require.NoError(t, err)
+	db := setupIntegrationDB(t)
 	defer db.Close()
 
 	// Применяем миграции
-	err = migrations.RunMigrations(cfg)
+	err := migrations.RunMigrations(db)
 	require.NoError(t, err)
 
 	repo := &Repository{db: db}
@@ -255,16 +726,17 @@ func TestSaveExpenseWithDB(t *testing.T) {
 
 	// Создаём категорию
 	category := &models.Category{Name: "Food", Type: "expense"}
-	catID, err := repo.SaveCategory(category)
+	catID, err := repo.SaveCategory(context.Background(), 1, category)
 	require.NoError(t, err)
 
 	// Создаём подкатегорию
 	subcategory := &models.Subcategory{CategoryID: catID, Name: "Groceries"}
-	subcatID, err := repo.SaveSubcategory(subcategory)
+	subcatID, err := repo.SaveSubcategory(context.Background(), 1, subcategory)
 	require.NoError(t, err)
 
 	tx := &models.Transaction{
-		Amount:        200.75,
+		Amount:        money.MustParse("200.75"),
+		Currency:      "RUB",
 		CategoryID:    catID,
 		SubcategoryID: int64Ptr(subcatID),
 		Description:   "Grocery shopping",
@@ -277,7 +749,7 @@ func TestSaveExpenseWithDB(t *testing.T) {
 	_, err = db.Exec("TRUNCATE TABLE expenses RESTART IDENTITY CASCADE")
 	require.NoError(t, err)
 
-	id, err := repo.SaveExpense(userID, tx)
+	id, err := repo.SaveExpense(context.Background(), userID, tx)
 	assert.NoError(t, err)
 	assert.NotZero(t, id)
 
@@ -298,3 +770,79 @@ func TestSaveExpenseWithDB(t *testing.T) {
 	assert.Equal(t, tx.Date.UTC(), savedDate.UTC()) // Нормализуем временные зоны
 	assert.Equal(t, "Weekly groceries", savedNote)
 }
+
+// createTestUser заводит пользователя для интеграционных тестов с уникальным адресом
+func createTestUser(t *testing.T, db *sql.DB) int64 {
+	var id int64
+	err := db.QueryRow(`INSERT INTO users (email, password, name, created_at) VALUES ($1, 'x', 'Test', NOW()) RETURNING id`,
+		fmt.Sprintf("budget-%d@example.com", time.Now().UnixNano())).Scan(&id)
+	require.NoError(t, err)
+	return id
+}
+
+func TestBudgetRolloverWithDB(t *testing.T) {
+	db := setupIntegrationDB(t)
+	defer db.Close()
+
+	// Применяем миграции
+	err := migrations.RunMigrations(db)
+	require.NoError(t, err)
+
+	repo := &Repository{db: db}
+	ctx := context.Background()
+	expense := func(userID, categoryID int64, amount string, date time.Time) {
+		_, err := repo.SaveExpense(ctx, userID, &models.Transaction{Amount: money.MustParse(amount), Currency: "RUB", CategoryID: categoryID, Date: date})
+		require.NoError(t, err)
+	}
+
+	t.Run("Unvisited Middle Month", func(t *testing.T) {
+		userID := createTestUser(t, db)
+		catID, err := repo.SaveCategory(ctx, userID, &models.Category{Name: "Food", Type: "expense"})
+		require.NoError(t, err)
+		_, err = repo.SaveBudgetTemplate(ctx, &models.BudgetTemplate{
+			UserID: userID, CategoryID: catID, Amount: money.MustParse("1000"), Currency: "RUB",
+			Rollover: true, StartMonth: "2025-05", CreatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+		expense(userID, catID, "400", time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC))
+
+		// Июнь никто не открывал: остаток мая переходит через него в июль
+		budgets, err := repo.GetBudgets(ctx, userID, "2025-07")
+		require.NoError(t, err)
+		require.Len(t, budgets, 1)
+		assert.Equal(t, money.MustParse("1600"), budgets[0].RolloverAmount)
+
+		// Чтение не создаёт бюджетов и не отмечает шаблон применённым
+		var stored int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM budgets WHERE user_id = $1`, userID).Scan(&stored))
+		assert.Zero(t, stored)
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM budget_template_months m JOIN budget_templates t ON t.id = m.template_id WHERE t.user_id = $1`, userID).Scan(&stored))
+		assert.Zero(t, stored)
+	})
+
+	t.Run("Late Expense", func(t *testing.T) {
+		userID := createTestUser(t, db)
+		catID, err := repo.SaveCategory(ctx, userID, &models.Category{Name: "Food", Type: "expense"})
+		require.NoError(t, err)
+		for _, month := range []string{"2025-05", "2025-06", "2025-07"} {
+			_, err := repo.SaveBudget(ctx, &models.Budget{
+				UserID: userID, CategoryID: catID, Amount: money.MustParse("1000"), Currency: "RUB",
+				Month: month, Rollover: true, CreatedAt: time.Now(),
+			})
+			require.NoError(t, err)
+		}
+
+		status, err := repo.CheckBudget(ctx, userID, catID, "2025-07")
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, money.MustParse("2000"), status.Rollover)
+
+		// Расход, внесённый в май задним числом, уменьшает перенос в июль
+		expense(userID, catID, "300", time.Date(2025, 5, 20, 0, 0, 0, 0, time.UTC))
+		status, err = repo.CheckBudget(ctx, userID, catID, "2025-07")
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, money.MustParse("1700"), status.Rollover)
+		assert.Equal(t, money.MustParse("2700"), status.Budgeted)
+	})
+}
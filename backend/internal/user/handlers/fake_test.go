@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/internal/user/repository"
+)
+
+// fakeRepo — Repository в памяти для тестов обработчиков. Повторяет поведение
+// *repository.Repository, которое видят обработчики, включая сигнальные ошибки.
+type fakeRepo struct {
+	mu             sync.Mutex
+	users          map[int64]*models.User
+	totp           map[int64]*models.TOTP
+	recoveryCodes  map[int64]map[string]bool
+	sessions       map[string]*fakeSession
+	emailTokens    map[string]*fakeEmailToken
+	passwordTokens map[string]*fakePasswordToken
+	nextUserID     int64
+}
+
+type fakeSession struct {
+	models.Session
+	previousHash string
+	revoked      bool
+}
+
+type fakeEmailToken struct {
+	models.EmailToken
+	used bool
+}
+
+type fakePasswordToken struct {
+	models.PasswordResetToken
+	used bool
+}
+
+var _ Repository = (*fakeRepo)(nil)
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		users:          make(map[int64]*models.User),
+		totp:           make(map[int64]*models.TOTP),
+		recoveryCodes:  make(map[int64]map[string]bool),
+		sessions:       make(map[string]*fakeSession),
+		emailTokens:    make(map[string]*fakeEmailToken),
+		passwordTokens: make(map[string]*fakePasswordToken),
+	}
+}
+
+func (f *fakeRepo) SaveUser(ctx context.Context, user *models.User) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if u.Email == user.Email {
+			return 0, fmt.Errorf("duplicate email %s", user.Email)
+		}
+	}
+	f.nextUserID++
+	saved := *user
+	saved.ID = f.nextUserID
+	f.users[saved.ID] = &saved
+	f.totp[saved.ID] = &models.TOTP{}
+	return saved.ID, nil
+}
+
+func (f *fakeRepo) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if u.Email == email {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeRepo) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	user := *u
+	return &user, nil
+}
+
+func (f *fakeRepo) GetUserProfile(ctx context.Context, userID int64) (*models.User, error) {
+	user, err := f.GetUserByID(ctx, userID)
+	if user != nil {
+		user.Password = ""
+	}
+	return user, err
+}
+
+func (f *fakeRepo) update(userID int64, fn func(*models.User)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u, ok := f.users[userID]; ok {
+		fn(u)
+	}
+	return nil
+}
+
+func (f *fakeRepo) UpdateUserName(ctx context.Context, userID int64, name string) error {
+	return f.update(userID, func(u *models.User) { u.Name = name })
+}
+
+func (f *fakeRepo) UpdateUserPassword(ctx context.Context, userID int64, hashedPassword string) error {
+	return f.update(userID, func(u *models.User) { u.Password = hashedPassword })
+}
+
+func (f *fakeRepo) UpdateUserBaseCurrency(ctx context.Context, userID int64, currency string) error {
+	return f.update(userID, func(u *models.User) { u.BaseCurrency = currency })
+}
+
+func (f *fakeRepo) CreateSession(ctx context.Context, session *models.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions[session.ID] = &fakeSession{Session: *session}
+	return nil
+}
+
+func (f *fakeRepo) RotateSession(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (*models.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.sessions {
+		if s.RefreshTokenHash == tokenHash && !s.revoked && s.ExpiresAt.After(time.Now()) {
+			s.previousHash, s.RefreshTokenHash, s.ExpiresAt = s.RefreshTokenHash, newHash, expiresAt
+			session := s.Session
+			return &session, nil
+		}
+	}
+	reused := false
+	for _, s := range f.sessions {
+		if s.previousHash == tokenHash && !s.revoked {
+			s.revoked, reused = true, true
+		}
+	}
+	if reused {
+		return nil, repository.ErrRefreshTokenReused
+	}
+	return nil, repository.ErrSessionNotFound
+}
+
+func (f *fakeRepo) IsSessionActive(ctx context.Context, userID int64, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.sessions[id]
+	return ok && s.UserID == userID && !s.revoked && s.ExpiresAt.After(time.Now()), nil
+}
+
+func (f *fakeRepo) RevokeSession(ctx context.Context, userID int64, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.sessions[id]
+	if !ok || s.UserID != userID || s.revoked {
+		return fmt.Errorf("session %s of user %d: %w", id, userID, repository.ErrSessionNotFound)
+	}
+	s.revoked = true
+	return nil
+}
+
+func (f *fakeRepo) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.revokeUserSessions(userID), nil
+}
+
+func (f *fakeRepo) revokeUserSessions(userID int64) int64 {
+	var n int64
+	for _, s := range f.sessions {
+		if s.UserID == userID && !s.revoked {
+			s.revoked = true
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeRepo) CreateEmailToken(ctx context.Context, token *models.EmailToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for hash, t := range f.emailTokens {
+		if t.UserID == token.UserID && t.Purpose == token.Purpose && !t.used {
+			delete(f.emailTokens, hash)
+		}
+	}
+	f.emailTokens[token.TokenHash] = &fakeEmailToken{EmailToken: *token}
+	return nil
+}
+
+func (f *fakeRepo) EmailTaken(ctx context.Context, email string, userID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.emailTaken(email, userID), nil
+}
+
+func (f *fakeRepo) emailTaken(email string, userID int64) bool {
+	for _, u := range f.users {
+		if u.Email == email && u.ID != userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeRepo) ConfirmEmailToken(ctx context.Context, tokenHash string) (*models.EmailToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.emailTokens[tokenHash]
+	if !ok || t.used || !t.ExpiresAt.After(time.Now()) {
+		return nil, repository.ErrInvalidToken
+	}
+	u, ok := f.users[t.UserID]
+	if !ok {
+		return nil, repository.ErrInvalidToken
+	}
+	switch t.Purpose {
+	case models.EmailTokenChange:
+		if f.emailTaken(t.Email, t.UserID) {
+			return nil, fmt.Errorf("%s: %w", t.Email, repository.ErrEmailTaken)
+		}
+		u.Email = t.Email
+	default:
+		if u.Email != t.Email {
+			return nil, repository.ErrInvalidToken
+		}
+	}
+	u.EmailVerified = true
+	t.used = true
+	token := t.EmailToken
+	return &token, nil
+}
+
+func (f *fakeRepo) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for hash, t := range f.passwordTokens {
+		if t.UserID == token.UserID && !t.used {
+			delete(f.passwordTokens, hash)
+		}
+	}
+	f.passwordTokens[token.TokenHash] = &fakePasswordToken{PasswordResetToken: *token}
+	return nil
+}
+
+func (f *fakeRepo) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.passwordTokens[tokenHash]
+	if !ok || t.used || !t.ExpiresAt.After(time.Now()) {
+		return 0, repository.ErrInvalidToken
+	}
+	t.used = true
+	if u, ok := f.users[t.UserID]; ok {
+		u.Password = hashedPassword
+	}
+	f.revokeUserSessions(t.UserID)
+	return t.UserID, nil
+}
+
+func (f *fakeRepo) GetTOTP(ctx context.Context, userID int64) (*models.TOTP, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.totp[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	state := *t
+	return &state, nil
+}
+
+func (f *fakeRepo) SetPendingTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.totp[userID]
+	if !ok || t.Enabled {
+		return repository.ErrTwoFactorEnabled
+	}
+	t.Secret, t.LastStep = secret, nil
+	return nil
+}
+
+func (f *fakeRepo) EnableTOTP(ctx context.Context, userID, step int64, codeHashes []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.totp[userID]
+	if !ok || t.Secret == "" || t.Enabled {
+		return repository.ErrTwoFactorEnabled
+	}
+	t.Enabled, t.LastStep = true, &step
+	f.recoveryCodes[userID] = make(map[string]bool)
+	for _, hash := range codeHashes {
+		f.recoveryCodes[userID][hash] = false
+	}
+	return nil
+}
+
+func (f *fakeRepo) UseTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.totp[userID]
+	if !ok || !t.Enabled || (t.LastStep != nil && *t.LastStep >= step) {
+		return false, nil
+	}
+	t.LastStep = &step
+	return true, nil
+}
+
+func (f *fakeRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	used, ok := f.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	f.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+func (f *fakeRepo) DisableTOTP(ctx context.Context, userID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.totp[userID]; ok {
+		f.totp[userID] = &models.TOTP{}
+	}
+	delete(f.recoveryCodes, userID)
+	return nil
+}
@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"budgetbuddy/internal/user/models"
@@ -10,20 +17,52 @@ import (
 	"budgetbuddy/pkg/auth"
 	"budgetbuddy/pkg/config"
 	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/mail"
 	"budgetbuddy/pkg/middleware"
+	"budgetbuddy/pkg/money"
+	"budgetbuddy/pkg/totp"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Ограничения для маршрутов входа и регистрации: по IP-адресу, по учётной записи
+// и блокировка после серии неудачных попыток
+var (
+	authIPLimit      = middleware.PerMinute(20, 10)
+	authAccountLimit = middleware.PerMinute(10, 5)
+)
+
+const (
+	lockoutThreshold = 5
+	lockoutBase      = time.Minute
+	lockoutMax       = time.Hour
+)
+
+// invalidCredentials — единый ответ на неверный адрес или пароль, чтобы по нему нельзя
+// было узнать, зарегистрирован ли адрес.
+const invalidCredentials = "Invalid email or password"
+
 type Handlers struct {
-	repo      *repository.Repository
+	repo      Repository
 	jwtSecret string
+	mailer    mail.Sender
+	appURL    string
+
+	ipLimiter      *middleware.RateLimiter
+	accountLimiter *middleware.RateLimiter
+	lockout        *middleware.Lockout
 }
 
-func NewHandlers(repo *repository.Repository, cfg *config.Config) *Handlers {
+func NewHandlers(repo Repository, cfg *config.Config) *Handlers {
+	store := middleware.NewMemoryStore()
 	return &Handlers{
-		repo:      repo,
-		jwtSecret: cfg.JWTSecret,
+		repo:           repo,
+		jwtSecret:      cfg.JWTSecret,
+		mailer:         mail.NewSender(cfg),
+		appURL:         cfg.AppURL,
+		ipLimiter:      middleware.NewRateLimiter("auth-ip", store, authIPLimit),
+		accountLimiter: middleware.NewRateLimiter("auth-account", store, authAccountLimit),
+		lockout:        middleware.NewLockout(lockoutThreshold, lockoutBase, lockoutMax),
 	}
 }
 
@@ -46,14 +85,26 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func SetupRoutes(mux *http.ServeMux, repo *repository.Repository, cfg *config.Config) {
+func SetupRoutes(mux *http.ServeMux, repo Repository, cfg *config.Config) {
 	h := NewHandlers(repo, cfg)
 	// corsMiddleware к маршрутам
-	mux.HandleFunc("/register", corsMiddleware(h.RegisterHandler))
-	mux.HandleFunc("/login", corsMiddleware(h.LoginHandler))
-	mux.HandleFunc("/profile", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.GetProfile)))
-	mux.HandleFunc("/profile/update", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.UpdateProfile)))
-	mux.HandleFunc("/password", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.UpdatePassword)))
+	mux.HandleFunc("/register", corsMiddleware(h.ipLimiter.ByIP(h.RegisterHandler)))
+	mux.HandleFunc("/login", corsMiddleware(h.ipLimiter.ByIP(h.LoginHandler)))
+	mux.HandleFunc("/login/2fa", corsMiddleware(h.ipLimiter.ByIP(h.LoginTwoFactor)))
+	mux.HandleFunc("/2fa/setup", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.SetupTwoFactor)))
+	mux.HandleFunc("/2fa/enable", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.EnableTwoFactor)))
+	mux.HandleFunc("/2fa/disable", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.DisableTwoFactor)))
+	mux.HandleFunc("/token/refresh", corsMiddleware(h.ipLimiter.ByIP(h.RefreshToken)))
+	mux.HandleFunc("/logout", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.Logout)))
+	mux.HandleFunc("/logout-all", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.LogoutAll)))
+	mux.HandleFunc("/profile", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.GetProfile)))
+	mux.HandleFunc("/profile/email", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.ChangeEmail)))
+	mux.HandleFunc("/email/verify", corsMiddleware(h.ipLimiter.ByIP(h.VerifyEmail)))
+	mux.HandleFunc("/email/verify/resend", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.ResendVerification)))
+	mux.HandleFunc("/profile/update", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.UpdateProfile)))
+	mux.HandleFunc("/password/forgot", corsMiddleware(h.ipLimiter.ByIP(h.ForgotPassword)))
+	mux.HandleFunc("/password/reset", corsMiddleware(h.ipLimiter.ByIP(h.ResetPassword)))
+	mux.HandleFunc("/password", corsMiddleware(middleware.AuthMiddleware(h.jwtSecret, h.repo, h.UpdatePassword)))
 }
 
 func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
@@ -65,54 +116,79 @@ func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		logger.Error("Failed to decode register request: ", err)
+		logger.ErrorContext(r.Context(), "Failed to decode register request: ", err)
 		return
 	}
 
-	existingUser, err := h.repo.FindUserByEmail(req.Email)
-	if err != nil {
-		http.Error(w, "Failed to check user existence", http.StatusInternalServerError)
-		logger.Error("Failed to check user existence: ", err)
+	if !validEmail(req.Email) {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
 		return
 	}
-	if existingUser != nil {
-		http.Error(w, "Email already registered", http.StatusConflict)
-		logger.Error("Email already registered: ", req.Email)
+	if req.BaseCurrency == "" {
+		req.BaseCurrency = money.DefaultCurrency
+	}
+	if !money.ValidCurrency(req.BaseCurrency) {
+		http.Error(w, "Invalid base currency, use ISO 4217 code", http.StatusBadRequest)
 		return
 	}
 
+	if !h.allowAccount(w, req.Email) {
+		return
+	}
+
+	// Пароль хешируется до проверки адреса, чтобы время ответа не выдавало занятые адреса
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
-		logger.Error("Failed to hash password: ", err)
+		logger.ErrorContext(r.Context(), "Failed to hash password: ", err)
+		return
+	}
+
+	existingUser, err := h.repo.FindUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		http.Error(w, "Failed to check user existence", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to check user existence: ", err)
+		return
+	}
+	// Ответ для занятого адреса тот же, что и для нового: о попытке узнаёт только владелец из письма
+	if existingUser != nil {
+		if err := h.sendAlreadyRegistered(existingUser.Email); err != nil {
+			logger.ErrorContext(r.Context(), "Failed to send already registered email: ", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
 	user := &models.User{
-		Email:     req.Email,
-		Password:  string(hashedPassword),
-		Name:      req.Name,
-		CreatedAt: time.Now(),
+		Email:        req.Email,
+		Password:     string(hashedPassword),
+		Name:         req.Name,
+		BaseCurrency: req.BaseCurrency,
+		CreatedAt:    time.Now(),
 	}
 
-	_, err = h.repo.SaveUser(user)
+	userID, err := h.repo.SaveUser(r.Context(), user)
 	if err != nil {
 		http.Error(w, "Failed to save user", http.StatusInternalServerError)
-		logger.Error("Failed to save user: ", err)
+		logger.ErrorContext(r.Context(), "Failed to save user: ", err)
 		return
 	}
 
-	token, err := auth.GenerateJWT(req.Email)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		logger.Error("Failed to generate token: ", err)
-		return
+	// Письмо не блокирует регистрацию: если оно не дошло, его можно запросить повторно
+	if err := h.sendEmailToken(r.Context(), userID, req.Email, models.EmailTokenVerify); err != nil {
+		logger.ErrorContext(r.Context(), "Failed to send verification email: ", err)
 	}
+	w.WriteHeader(http.StatusAccepted)
+}
 
-	response := models.LoginResponse{Token: token}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+// sendAlreadyRegistered сообщает владельцу адреса о попытке зарегистрироваться с ним повторно.
+func (h *Handlers) sendAlreadyRegistered(email string) error {
+	return h.mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Your BudgetBuddy account already exists",
+		Body: fmt.Sprintf("Someone tried to create a BudgetBuddy account with this address, but it is already registered.\n\n"+
+			"If it was you, sign in or reset your password at %s. Otherwise, ignore this email.\n", h.appURL),
+	})
 }
 
 func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -124,41 +200,216 @@ func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		logger.Error("Failed to decode login request: ", err)
+		logger.ErrorContext(r.Context(), "Failed to decode login request: ", err)
+		return
+	}
+
+	key := accountKey(req.Email)
+	if wait := h.lockout.Check(key); wait > 0 {
+		middleware.TooManyRequests(w, wait)
+		logger.ErrorContext(r.Context(), "Login attempt for locked account: ", req.Email)
+		return
+	}
+	if !h.allowAccount(w, req.Email) {
 		return
 	}
 
-	user, err := h.repo.FindUserByEmail(req.Email)
+	user, err := h.repo.FindUserByEmail(r.Context(), req.Email)
 	if err != nil {
 		http.Error(w, "Failed to find user", http.StatusInternalServerError)
-		logger.Error("Failed to find user: ", err)
+		logger.ErrorContext(r.Context(), "Failed to find user: ", err)
 		return
 	}
-	if user == nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
-		logger.Error("User not found: ", req.Email)
+	// Для неизвестного адреса пароль всё равно сравнивается с хешем, чтобы время ответа
+	// не выдавало, зарегистрирован ли адрес
+	passwordHash := dummyPasswordHash()
+	if user != nil {
+		passwordHash = []byte(user.Password)
+	}
+	if err := bcrypt.CompareHashAndPassword(passwordHash, []byte(req.Password)); err != nil || user == nil {
+		h.lockout.Fail(key)
+		http.Error(w, invalidCredentials, http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Invalid credentials for: ", req.Email)
 		return
 	}
+	h.lockout.Reset(key)
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		http.Error(w, "Invalid password", http.StatusUnauthorized)
-		logger.Error("Invalid password for user: ", req.Email)
+	totpState, err := h.repo.GetTOTP(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to find user", http.StatusInternalServerError)
+		return
+	}
+	if totpState.Enabled {
+		challenge, err := auth.GenerateChallengeToken(h.jwtSecret, user.ID)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to generate challenge token: ", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int64(auth.ChallengeTokenTTL / time.Second),
+		})
 		return
 	}
 
-	token, err := auth.GenerateJWT(req.Email)
+	h.startSession(r.Context(), w, http.StatusOK, user.ID)
+}
+
+// accountKey приводит адрес к виду, под которым учитываются попытки входа в учётную запись.
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// allowAccount ограничивает частоту попыток для одного адреса независимо от IP-адреса
+// и при превышении отвечает 429.
+func (h *Handlers) allowAccount(w http.ResponseWriter, email string) bool {
+	allowed, retryAfter := h.accountLimiter.Allow(accountKey(email))
+	if !allowed {
+		middleware.TooManyRequests(w, retryAfter)
+		logger.Error("Rate limit exceeded for account: ", email)
+	}
+	return allowed
+}
+
+// dummyPasswordHash — хеш для сравнения при входе с незарегистрированным адресом.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("budgetbuddy"), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("Failed to hash dummy password: ", err)
+	}
+	return hash
+})
+
+// startSession создаёт сессию входа и отвечает парой токенов.
+func (h *Handlers) startSession(ctx context.Context, w http.ResponseWriter, status int, userID int64) {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		logger.ErrorContext(ctx, "Failed to generate session ID: ", err)
+		return
+	}
+	refreshToken, refreshHash, err := auth.GenerateToken()
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		logger.ErrorContext(ctx, "Failed to generate refresh token: ", err)
+		return
+	}
+	now := time.Now()
+	session := &models.Session{
+		ID:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: refreshHash,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(auth.RefreshTokenTTL),
+	}
+	if err := h.repo.CreateSession(ctx, session); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	h.writeTokens(w, status, session, refreshToken)
+}
+
+// writeTokens выпускает access-токен сессии и отвечает им вместе с refresh-токеном.
+func (h *Handlers) writeTokens(w http.ResponseWriter, status int, session *models.Session, refreshToken string) {
+	token, err := auth.GenerateJWT(h.jwtSecret, session.UserID, session.ID)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		logger.Error("Failed to generate token: ", err)
 		return
 	}
 
-	response := models.LoginResponse{Token: token}
+	response := models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(auth.AccessTokenTTL / time.Second),
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(response)
 }
 
+// RefreshToken обменивает refresh-токен на новую пару токенов той же сессии.
+// Старый refresh-токен после этого недействителен.
+func (h *Handlers) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode refresh token request: ", err)
+		return
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.GenerateToken()
+	if err != nil {
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to generate refresh token: ", err)
+		return
+	}
+	session, err := h.repo.RotateSession(r.Context(), auth.HashToken(req.RefreshToken), refreshHash, time.Now().Add(auth.RefreshTokenTTL))
+	if errors.Is(err, repository.ErrSessionNotFound) || errors.Is(err, repository.ErrRefreshTokenReused) {
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+	h.writeTokens(w, http.StatusOK, session, refreshToken)
+}
+
+// Logout отзывает текущую сессию: её access- и refresh-токены перестают приниматься.
+func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	err = h.repo.RevokeSession(r.Context(), userID, principal.SessionID)
+	if err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll отзывает все сессии пользователя, включая текущую.
+func (h *Handlers) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+	revoked, err := h.repo.RevokeUserSessions(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	logger.InfoContext(r.Context(), "Revoked ", revoked, " sessions of user ", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 //добавлены новые хэндлеры
 
 func (h *Handlers) GetProfile(w http.ResponseWriter, r *http.Request) {
@@ -169,13 +420,13 @@ func (h *Handlers) GetProfile(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil || userID == 0 {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
-	user, err := h.repo.GetUserProfile(userID)
+	user, err := h.repo.GetUserProfile(r.Context(), userID)
 	if err != nil {
 		http.Error(w, "Failed to get profile", http.StatusInternalServerError)
-		logger.Error("Failed to get profile: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get profile: ", err)
 		return
 	}
 	if user == nil {
@@ -183,8 +434,10 @@ func (h *Handlers) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	response := models.UserProfileResponse{
-		Email: user.Email,
-		Name:  user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          user.Name,
+		BaseCurrency:  user.BaseCurrency,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -199,24 +452,35 @@ func (h *Handlers) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil || userID == 0 {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 	var req models.UpdateProfileRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		logger.Error("Failed to decode update profile request: ", err)
+		logger.ErrorContext(r.Context(), "Failed to decode update profile request: ", err)
 		return
 	}
 	if req.Name == "" {
 		http.Error(w, "Name is required", http.StatusBadRequest)
 		return
 	}
-	if err := h.repo.UpdateUserName(userID, req.Name); err != nil {
+	if req.BaseCurrency != "" && !money.ValidCurrency(req.BaseCurrency) {
+		http.Error(w, "Invalid base currency, use ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+	if err := h.repo.UpdateUserName(r.Context(), userID, req.Name); err != nil {
 		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
-		logger.Error("Failed to update profile: ", err)
+		logger.ErrorContext(r.Context(), "Failed to update profile: ", err)
 		return
 	}
+	if req.BaseCurrency != "" {
+		if err := h.repo.UpdateUserBaseCurrency(r.Context(), userID, req.BaseCurrency); err != nil {
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to update base currency: ", err)
+			return
+		}
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -228,44 +492,536 @@ func (h *Handlers) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.getUserIDFromToken(r)
 	if err != nil || userID == 0 {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		logger.Error("Failed to get user ID: ", err)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
 		return
 	}
 	var req models.UpdatePasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		logger.Error("Failed to decode update password request: ", err)
+		logger.ErrorContext(r.Context(), "Failed to decode update password request: ", err)
 		return
 	}
 	if req.NewPassword == "" || req.OldPassword == "" {
 		http.Error(w, "Old and new passwords are required", http.StatusBadRequest)
 		return
 	}
-	user, err := h.repo.FindUserByEmail(r.Header.Get("X-User-Email"))
+	user, err := h.repo.GetUserByID(r.Context(), userID)
 	if err != nil || user == nil {
 		http.Error(w, "User not found", http.StatusUnauthorized)
-		logger.Error("User not found: ", r.Header.Get("X-User-Email"))
+		logger.ErrorContext(r.Context(), "User not found: ", userID)
 		return
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
 		http.Error(w, "Invalid old password", http.StatusUnauthorized)
-		logger.Error("Invalid old password for user: ", r.Header.Get("X-User-Email"))
+		logger.ErrorContext(r.Context(), "Invalid old password for user: ", userID)
 		return
 	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
-		logger.Error("Failed to hash password: ", err)
+		logger.ErrorContext(r.Context(), "Failed to hash password: ", err)
 		return
 	}
-	if err := h.repo.UpdateUserPassword(userID, string(hashedPassword)); err != nil {
+	if err := h.repo.UpdateUserPassword(r.Context(), userID, string(hashedPassword)); err != nil {
 		http.Error(w, "Failed to update password", http.StatusInternalServerError)
-		logger.Error("Failed to update password: ", err)
+		logger.ErrorContext(r.Context(), "Failed to update password: ", err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handlers) getUserIDFromToken(r *http.Request) (int64, error) {
-	return h.repo.GetUserIDByEmail(r.Header.Get("X-User-Email"))
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return 0, errors.New("request is not authenticated")
+	}
+	return principal.UserID, nil
+}
+
+// EmailTokenTTL — срок действия ссылки из письма подтверждения адреса.
+const EmailTokenTTL = 24 * time.Hour
+
+// validEmail проверяет, что строка — один адрес без имени, например user@example.com.
+func validEmail(email string) bool {
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// sendEmailToken создаёт токен подтверждения адреса email и отправляет ссылку с ним на этот адрес.
+func (h *Handlers) sendEmailToken(ctx context.Context, userID int64, email, purpose string) error {
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = h.repo.CreateEmailToken(ctx, &models.EmailToken{
+		TokenHash: hash,
+		UserID:    userID,
+		Email:     email,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(EmailTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", h.appURL, url.QueryEscape(token))
+	msg := mail.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Open the link below to confirm your BudgetBuddy email address:\n\n%s\n\n"+
+			"The link is valid for %d hours. If you did not request this, ignore this email.\n", link, int(EmailTokenTTL.Hours())),
+	}
+	if purpose == models.EmailTokenChange {
+		msg.Subject = "Confirm your new email address"
+		msg.Body = fmt.Sprintf("Open the link below to use this address for your BudgetBuddy account:\n\n%s\n\n"+
+			"The link is valid for %d hours. Until then your current address stays active.\n", link, int(EmailTokenTTL.Hours()))
+	}
+	return h.mailer.Send(msg)
+}
+
+// VerifyEmail применяет токен из письма: подтверждает адрес или завершает его смену.
+func (h *Handlers) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode verify email request: ", err)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.repo.ConfirmEmailToken(r.Context(), auth.HashToken(req.Token))
+	switch {
+	case errors.Is(err, repository.ErrInvalidToken):
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	case errors.Is(err, repository.ErrEmailTaken):
+		http.Error(w, "Email already registered", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+	logger.InfoContext(r.Context(), "Confirmed email (", token.Purpose, ") for user ", token.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification повторно отправляет письмо для подтверждения текущего адреса.
+func (h *Handlers) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+	user, err := h.repo.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+	if user.EmailVerified {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+	if err := h.sendEmailToken(r.Context(), userID, user.Email, models.EmailTokenVerify); err != nil {
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to send verification email: ", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ChangeEmail начинает смену адреса: после проверки пароля на новый адрес отправляется
+// письмо со ссылкой, и адрес меняется только после перехода по ней.
+func (h *Handlers) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode change email request: ", err)
+		return
+	}
+	if !validEmail(req.Email) {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.repo.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Invalid password on email change for user: ", userID)
+		return
+	}
+	if req.Email == user.Email {
+		http.Error(w, "New email must differ from the current one", http.StatusBadRequest)
+		return
+	}
+	taken, err := h.repo.EmailTaken(r.Context(), req.Email, userID)
+	if err != nil {
+		http.Error(w, "Failed to check email", http.StatusInternalServerError)
+		return
+	}
+	if taken {
+		http.Error(w, "Email already registered", http.StatusConflict)
+		return
+	}
+
+	if err := h.sendEmailToken(r.Context(), userID, req.Email, models.EmailTokenChange); err != nil {
+		http.Error(w, "Failed to send confirmation email", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to send email change confirmation: ", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// PasswordResetTTL — срок действия ссылки сброса пароля.
+const PasswordResetTTL = time.Hour
+
+// ForgotPassword отправляет ссылку для сброса пароля. Ответ не зависит от того,
+// зарегистрирован ли адрес, а письмо отправляется в фоне, чтобы это нельзя было
+// определить и по времени ответа.
+func (h *Handlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode forgot password request: ", err)
+		return
+	}
+	if !validEmail(req.Email) {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+	if !h.allowAccount(w, req.Email) {
+		return
+	}
+
+	// Письмо отправляется после ответа, поэтому отмена запроса не должна его прерывать
+	ctx := context.WithoutCancel(r.Context())
+	go func(email string) {
+		if err := h.sendPasswordReset(ctx, email); err != nil {
+			logger.ErrorContext(r.Context(), "Failed to send password reset email: ", err)
+		}
+	}(req.Email)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handlers) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := h.repo.FindUserByEmail(ctx, email)
+	if err != nil || user == nil {
+		return err
+	}
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = h.repo.CreatePasswordResetToken(ctx, &models.PasswordResetToken{
+		TokenHash: hash,
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(PasswordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", h.appURL, url.QueryEscape(token))
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Open the link below to set a new BudgetBuddy password:\n\n%s\n\n"+
+			"The link is valid for %d minutes and can be used once. If you did not request this, ignore this email.\n",
+			link, int(PasswordResetTTL.Minutes())),
+	})
+}
+
+// ResetPassword устанавливает новый пароль по токену из письма и завершает все сессии пользователя.
+func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode reset password request: ", err)
+		return
+	}
+	if req.Token == "" || req.NewPassword == "" {
+		http.Error(w, "Token and new password are required", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to hash password: ", err)
+		return
+	}
+	userID, err := h.repo.ResetPassword(r.Context(), auth.HashToken(req.Token), string(hashedPassword))
+	if errors.Is(err, repository.ErrInvalidToken) {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	logger.InfoContext(r.Context(), "Password reset for user ", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Параметры двухфакторной аутентификации: название сервиса в приложении-аутентификаторе,
+// допустимое расхождение часов в интервалах по 30 секунд и число резервных кодов.
+const (
+	totpIssuer        = "BudgetBuddy"
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+// LoginTwoFactor — второй шаг входа: проверяет код из приложения или резервный код
+// и создаёт сессию.
+func (h *Handlers) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode two-factor login request: ", err)
+		return
+	}
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		http.Error(w, "Provide either code or recovery_code", http.StatusBadRequest)
+		return
+	}
+	userID, err := auth.ParseChallengeToken(h.jwtSecret, req.ChallengeToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired challenge token", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Invalid challenge token: ", err)
+		return
+	}
+
+	key := fmt.Sprintf("2fa:%d", userID)
+	if wait := h.lockout.Check(key); wait > 0 {
+		middleware.TooManyRequests(w, wait)
+		logger.ErrorContext(r.Context(), "Two-factor attempt for locked user: ", userID)
+		return
+	}
+
+	var accepted bool
+	if req.RecoveryCode != "" {
+		accepted, err = h.repo.UseRecoveryCode(r.Context(), userID, auth.HashToken(totp.NormalizeRecoveryCode(req.RecoveryCode)))
+	} else {
+		accepted, err = h.checkTOTPCode(r.Context(), userID, req.Code)
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !accepted {
+		h.lockout.Fail(key)
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Invalid two-factor code for user: ", userID)
+		return
+	}
+	h.lockout.Reset(key)
+	h.startSession(r.Context(), w, http.StatusOK, userID)
+}
+
+// checkTOTPCode проверяет код включённой двухфакторной аутентификации. Каждый код
+// принимается один раз.
+func (h *Handlers) checkTOTPCode(ctx context.Context, userID int64, code string) (bool, error) {
+	state, err := h.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !state.Enabled {
+		return false, nil
+	}
+	step, ok := totp.Validate(state.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+	return h.repo.UseTOTPStep(ctx, userID, step)
+}
+
+// SetupTwoFactor начинает настройку двухфакторной аутентификации: создаёт секрет и возвращает
+// его вместе с otpauth-URI. Аутентификация включается после подтверждения кодом в POST /2fa/enable.
+func (h *Handlers) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+	user, err := h.repo.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to generate totp secret: ", err)
+		return
+	}
+	err = h.repo.SetPendingTOTPSecret(r.Context(), userID, secret)
+	if errors.Is(err, repository.ErrTwoFactorEnabled) {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set up two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor подтверждает настройку кодом из приложения, включает двухфакторную
+// аутентификацию и возвращает резервные коды. Коды показываются только в этом ответе.
+func (h *Handlers) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode two-factor enable request: ", err)
+		return
+	}
+
+	state, err := h.repo.GetTOTP(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	if state.Enabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if state.Secret == "" {
+		http.Error(w, "Start two-factor setup first", http.StatusBadRequest)
+		return
+	}
+	step, ok := totp.Validate(state.Secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Failed to generate recovery codes: ", err)
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashToken(totp.NormalizeRecoveryCode(code))
+	}
+	err = h.repo.EnableTOTP(r.Context(), userID, step, hashes)
+	if errors.Is(err, repository.ErrTwoFactorEnabled) {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Enabled two-factor authentication for user ", userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TwoFactorEnableResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor выключает двухфакторную аутентификацию после подтверждения паролем.
+func (h *Handlers) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, err := h.getUserIDFromToken(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Failed to get user ID: ", err)
+		return
+	}
+
+	var req models.TwoFactorDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Failed to decode two-factor disable request: ", err)
+		return
+	}
+	user, err := h.repo.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		logger.ErrorContext(r.Context(), "Invalid password on two-factor disabling for user: ", userID)
+		return
+	}
+
+	if err := h.repo.DisableTOTP(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	logger.InfoContext(r.Context(), "Disabled two-factor authentication for user ", userID)
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/config"
+	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/mail"
+	"budgetbuddy/pkg/middleware"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+type fakeMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *fakeMailer) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func newTestHandlers() (*Handlers, *fakeRepo, *fakeMailer) {
+	repo := newFakeRepo()
+	mailer := &fakeMailer{}
+	h := NewHandlers(repo, config.NewTestConfig())
+	h.mailer = mailer
+	return h, repo, mailer
+}
+
+func call(handler http.HandlerFunc, method, target string, body interface{}, token string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// register регистрирует пользователя и входит от его имени: регистрация сама сессию не открывает.
+func register(t *testing.T, h *Handlers, email, password string) models.LoginResponse {
+	w := call(h.RegisterHandler, http.MethodPost, "/register",
+		models.RegisterRequest{Email: email, Password: password, Name: "User"}, "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	w = call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: email, Password: password}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tokens models.LoginResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tokens))
+	return tokens
+}
+
+func TestRegisterAndProfile(t *testing.T) {
+	h, _, mailer := newTestHandlers()
+	tokens := register(t, h, "user@example.com", "secret-password")
+	assert.NotEmpty(t, tokens.Token)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	require.Len(t, mailer.messages, 1)
+	assert.Equal(t, "user@example.com", mailer.messages[0].To)
+
+	profile := middleware.AuthMiddleware(h.jwtSecret, h.repo, h.GetProfile)
+	w := call(profile, http.MethodGet, "/profile", nil, tokens.Token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp models.UserProfileResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "user@example.com", resp.Email)
+	assert.False(t, resp.EmailVerified)
+
+	// Повторная регистрация неотличима от новой, а владелец адреса получает письмо
+	fresh := call(h.RegisterHandler, http.MethodPost, "/register",
+		models.RegisterRequest{Email: "new@example.com", Password: "other", Name: "Other"}, "")
+	taken := call(h.RegisterHandler, http.MethodPost, "/register",
+		models.RegisterRequest{Email: "user@example.com", Password: "other", Name: "Other"}, "")
+	assert.Equal(t, http.StatusAccepted, taken.Code)
+	assert.Equal(t, fresh.Code, taken.Code)
+	assert.Equal(t, fresh.Body.String(), taken.Body.String())
+	require.Len(t, mailer.messages, 3)
+	assert.Equal(t, "user@example.com", mailer.messages[2].To)
+	assert.Equal(t, "Your BudgetBuddy account already exists", mailer.messages[2].Subject)
+
+	// Пароль существующей учётной записи не меняется
+	w = call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "user@example.com", Password: "other"}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLoginUniformErrors(t *testing.T) {
+	h, _, _ := newTestHandlers()
+	register(t, h, "user@example.com", "secret-password")
+
+	w := call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "user@example.com", Password: "secret-password"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	wrongPassword := call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "user@example.com", Password: "wrong"}, "")
+	unknownUser := call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "nobody@example.com", Password: "wrong"}, "")
+	assert.Equal(t, http.StatusUnauthorized, wrongPassword.Code)
+	assert.Equal(t, wrongPassword.Code, unknownUser.Code)
+	assert.Equal(t, wrongPassword.Body.String(), unknownUser.Body.String())
+}
+
+func TestLoginLockout(t *testing.T) {
+	h, _, _ := newTestHandlers()
+	// Частота попыток здесь не ограничивается, чтобы проверить именно блокировку
+	h.accountLimiter = middleware.NewRateLimiter("test", middleware.NewMemoryStore(), middleware.PerMinute(100, 100))
+	register(t, h, "user@example.com", "secret-password")
+
+	for i := 0; i < lockoutThreshold; i++ {
+		w := call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "user@example.com", Password: "wrong"}, "")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	// Пока учётная запись заблокирована, не проходит даже верный пароль
+	w := call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "User@Example.com ", Password: "secret-password"}, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	h, _, _ := newTestHandlers()
+	tokens := register(t, h, "user@example.com", "secret-password")
+
+	w := call(h.RefreshToken, http.MethodPost, "/token/refresh", models.RefreshTokenRequest{RefreshToken: tokens.RefreshToken}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rotated models.LoginResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rotated))
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+	// Повторное использование заменённого токена отзывает сессию целиком
+	w = call(h.RefreshToken, http.MethodPost, "/token/refresh", models.RefreshTokenRequest{RefreshToken: tokens.RefreshToken}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = call(h.RefreshToken, http.MethodPost, "/token/refresh", models.RefreshTokenRequest{RefreshToken: rotated.RefreshToken}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	profile := middleware.AuthMiddleware(h.jwtSecret, h.repo, h.GetProfile)
+	w = call(profile, http.MethodGet, "/profile", nil, rotated.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestPasswordReset(t *testing.T) {
+	h, repo, mailer := newTestHandlers()
+	tokens := register(t, h, "user@example.com", "secret-password")
+
+	user, err := repo.FindUserByEmail(context.Background(), "user@example.com")
+	require.NoError(t, err)
+	require.NoError(t, h.sendPasswordReset(context.Background(), user.Email))
+	require.Len(t, mailer.messages, 2)
+	body := mailer.messages[1].Body
+	token := body[strings.Index(body, "token=")+len("token="):]
+	token = strings.Fields(token)[0]
+
+	w := call(h.ResetPassword, http.MethodPost, "/password/reset",
+		models.ResetPasswordRequest{Token: token, NewPassword: "new-password"}, "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	// Старые сессии отозваны, вход — только с новым паролем
+	profile := middleware.AuthMiddleware(h.jwtSecret, h.repo, h.GetProfile)
+	w = call(profile, http.MethodGet, "/profile", nil, tokens.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "user@example.com", Password: "new-password"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Повторно ссылка не действует
+	w = call(h.ResetPassword, http.MethodPost, "/password/reset",
+		models.ResetPasswordRequest{Token: token, NewPassword: "another-password"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "user@example.com", Password: "another-password"}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestPasswordResetExpired(t *testing.T) {
+	h, repo, mailer := newTestHandlers()
+	register(t, h, "user@example.com", "secret-password")
+	require.NoError(t, h.sendPasswordReset(context.Background(), "user@example.com"))
+	token := mailedToken(t, mailer)
+
+	repo.mu.Lock()
+	for _, reset := range repo.passwordTokens {
+		reset.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+	repo.mu.Unlock()
+
+	w := call(h.ResetPassword, http.MethodPost, "/password/reset",
+		models.ResetPasswordRequest{Token: token, NewPassword: "new-password"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = call(h.LoginHandler, http.MethodPost, "/login", models.LoginRequest{Email: "user@example.com", Password: "secret-password"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestForgotPassword(t *testing.T) {
+	h, _, mailer := newTestHandlers()
+	register(t, h, "user@example.com", "secret-password")
+
+	// Ответ для неизвестного адреса тот же, но письмо не отправляется
+	known := call(h.ForgotPassword, http.MethodPost, "/password/forgot", models.ForgotPasswordRequest{Email: "user@example.com"}, "")
+	unknown := call(h.ForgotPassword, http.MethodPost, "/password/forgot", models.ForgotPasswordRequest{Email: "nobody@example.com"}, "")
+	assert.Equal(t, http.StatusAccepted, known.Code)
+	assert.Equal(t, known.Code, unknown.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+	require.Eventually(t, func() bool {
+		mailer.mu.Lock()
+		defer mailer.mu.Unlock()
+		return len(mailer.messages) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "user@example.com", mailer.messages[1].To)
+	assert.Equal(t, "Reset your password", mailer.messages[1].Subject)
+}
+
+// mailedToken возвращает токен из ссылки в последнем отправленном письме
+func mailedToken(t *testing.T, mailer *fakeMailer) string {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	require.NotEmpty(t, mailer.messages)
+	body := mailer.messages[len(mailer.messages)-1].Body
+	i := strings.Index(body, "token=")
+	require.GreaterOrEqual(t, i, 0, body)
+	return strings.Fields(body[i+len("token="):])[0]
+}
+
+func TestVerifyEmail(t *testing.T) {
+	h, repo, mailer := newTestHandlers()
+	register(t, h, "user@example.com", "secret-password")
+	token := mailedToken(t, mailer)
+
+	w := call(h.VerifyEmail, http.MethodPost, "/email/verify", models.VerifyEmailRequest{Token: "unknown"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(h.VerifyEmail, http.MethodPost, "/email/verify", models.VerifyEmailRequest{Token: token}, "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	user, err := repo.FindUserByEmail(context.Background(), "user@example.com")
+	require.NoError(t, err)
+	assert.True(t, user.EmailVerified)
+
+	// Токен одноразовый
+	w = call(h.VerifyEmail, http.MethodPost, "/email/verify", models.VerifyEmailRequest{Token: token}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestResendVerification(t *testing.T) {
+	h, _, mailer := newTestHandlers()
+	tokens := register(t, h, "user@example.com", "secret-password")
+	first := mailedToken(t, mailer)
+	resend := middleware.AuthMiddleware(h.jwtSecret, h.repo, h.ResendVerification)
+
+	w := call(resend, http.MethodPost, "/email/verify/resend", nil, tokens.Token)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.Len(t, mailer.messages, 2)
+	assert.Equal(t, "user@example.com", mailer.messages[1].To)
+	second := mailedToken(t, mailer)
+
+	// Новое письмо отменяет ссылку из предыдущего
+	w = call(h.VerifyEmail, http.MethodPost, "/email/verify", models.VerifyEmailRequest{Token: first}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = call(h.VerifyEmail, http.MethodPost, "/email/verify", models.VerifyEmailRequest{Token: second}, "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = call(resend, http.MethodPost, "/email/verify/resend", nil, tokens.Token)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Len(t, mailer.messages, 2)
+}
+
+func TestChangeEmail(t *testing.T) {
+	h, repo, mailer := newTestHandlers()
+	tokens := register(t, h, "user@example.com", "secret-password")
+	register(t, h, "other@example.com", "secret-password")
+	change := middleware.AuthMiddleware(h.jwtSecret, h.repo, h.ChangeEmail)
+
+	w := call(change, http.MethodPost, "/profile/email", models.ChangeEmailRequest{Email: "new@example.com", Password: "wrong"}, tokens.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = call(change, http.MethodPost, "/profile/email", models.ChangeEmailRequest{Email: "other@example.com", Password: "secret-password"}, tokens.Token)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = call(change, http.MethodPost, "/profile/email", models.ChangeEmailRequest{Email: "not-an-email", Password: "secret-password"}, tokens.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(change, http.MethodPost, "/profile/email", models.ChangeEmailRequest{Email: "new@example.com", Password: "secret-password"}, tokens.Token)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.Len(t, mailer.messages, 3)
+	assert.Equal(t, "new@example.com", mailer.messages[2].To)
+
+	// До перехода по ссылке действует прежний адрес
+	user, err := repo.FindUserByEmail(context.Background(), "user@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+
+	w = call(h.VerifyEmail, http.MethodPost, "/email/verify", models.VerifyEmailRequest{Token: mailedToken(t, mailer)}, "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	user, err = repo.FindUserByEmail(context.Background(), "new@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.True(t, user.EmailVerified)
+}
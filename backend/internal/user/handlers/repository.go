@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/middleware"
+)
+
+// Repository — методы репозитория user-service, которые используют обработчики.
+// Реализуется *repository.Repository; в тестах обработчиков — фейком в памяти.
+type Repository interface {
+	middleware.SessionStore
+	ConfirmEmailToken(ctx context.Context, tokenHash string) (*models.EmailToken, error)
+	CreateEmailToken(ctx context.Context, token *models.EmailToken) error
+	CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	CreateSession(ctx context.Context, session *models.Session) error
+	DisableTOTP(ctx context.Context, userID int64) error
+	EmailTaken(ctx context.Context, email string, userID int64) (bool, error)
+	EnableTOTP(ctx context.Context, userID, step int64, codeHashes []string) error
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetTOTP(ctx context.Context, userID int64) (*models.TOTP, error)
+	GetUserByID(ctx context.Context, userID int64) (*models.User, error)
+	GetUserProfile(ctx context.Context, userID int64) (*models.User, error)
+	ResetPassword(ctx context.Context, tokenHash, hashedPassword string) (int64, error)
+	RevokeSession(ctx context.Context, userID int64, id string) error
+	RevokeUserSessions(ctx context.Context, userID int64) (int64, error)
+	RotateSession(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (*models.Session, error)
+	SaveUser(ctx context.Context, user *models.User) (int64, error)
+	SetPendingTOTPSecret(ctx context.Context, userID int64, secret string) error
+	UpdateUserBaseCurrency(ctx context.Context, userID int64, currency string) error
+	UpdateUserName(ctx context.Context, userID int64, name string) error
+	UpdateUserPassword(ctx context.Context, userID int64, hashedPassword string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	UseTOTPStep(ctx context.Context, userID, step int64) (bool, error)
+}
@@ -3,17 +3,20 @@ package models
 import "time"
 
 type User struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            int64     `json:"id"`
+	Email         string    `json:"email"`
+	Password      string    `json:"-"`
+	Name          string    `json:"name"`
+	BaseCurrency  string    `json:"base_currency"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	Name         string `json:"name"`
+	BaseCurrency string `json:"base_currency,omitempty"`
 }
 
 type LoginRequest struct {
@@ -21,22 +24,133 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// LoginResponse — пара токенов: короткоживущий access-токен (Token) и refresh-токен
+// для его обновления через POST /token/refresh. ExpiresIn — срок действия Token в секундах.
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Session — сессия входа. ID записывается в claim jti access-токенов сессии;
+// refresh-токен хранится только в виде хеша.
+type Session struct {
+	ID               string
+	UserID           int64
+	RefreshTokenHash string
+	CreatedAt        time.Time
+	ExpiresAt        time.Time
 }
 
 //ДОБАВЛЕНЫ СТРУКТУРЫ ДЛЯ НОВЫХ ЗАПРОСОВ
 
 type UserProfileResponse struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	BaseCurrency  string `json:"base_currency"`
 }
 
 type UpdateProfileRequest struct {
-	Name string `json:"name"`
+	Name         string `json:"name"`
+	BaseCurrency string `json:"base_currency,omitempty"`
 }
 
 type UpdatePasswordRequest struct {
 	OldPassword string `json:"old_password"`
 	NewPassword string `json:"new_password"`
 }
+
+// DeleteAccountRequest — подтверждение удаления учётной записи паролем.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// Назначение токена из письма
+const (
+	EmailTokenVerify = "verify"
+	EmailTokenChange = "change"
+)
+
+// EmailToken — токен подтверждения адреса Email. Сам токен отправляется в письме,
+// в базе хранится только хеш.
+type EmailToken struct {
+	TokenHash string
+	UserID    int64
+	Email     string
+	Purpose   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ChangeEmailRequest — смена адреса; новый адрес начинает действовать после подтверждения
+// по ссылке из письма, отправленного на него.
+type ChangeEmailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// PasswordResetToken — одноразовый токен сброса пароля; в базе хранится только хеш.
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// TOTP — состояние двухфакторной аутентификации пользователя. Secret задан и при Enabled = false,
+// если настройка начата, но ещё не подтверждена кодом.
+type TOTP struct {
+	Secret   string
+	Enabled  bool
+	LastStep *int64
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+}
+
+// TwoFactorChallengeResponse — ответ на вход по паролю при включённой двухфакторной
+// аутентификации: вход завершается запросом POST /login/2fa с ChallengeToken и кодом.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+// TwoFactorLoginRequest — второй шаг входа: код из приложения или резервный код.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CreateEmailToken сохраняет токен подтверждения адреса. Неиспользованные токены того же
+// назначения становятся недействительными: работает только ссылка из последнего письма.
+func (r *Repository) CreateEmailToken(ctx context.Context, token *models.EmailToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM email_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		token.UserID, token.Purpose)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to delete previous email tokens: ", err)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO email_tokens (token_hash, user_id, email, purpose, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.TokenHash, token.UserID, token.Email, token.Purpose, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save email token: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit email token: ", err)
+		return err
+	}
+	return nil
+}
+
+// EmailTaken сообщает, что адрес занят пользователем, отличным от userID.
+func (r *Repository) EmailTaken(ctx context.Context, email string, userID int64) (bool, error) {
+	var taken bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)`, email, userID).Scan(&taken)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check email: ", err)
+		return false, err
+	}
+	return taken, nil
+}
+
+// ConfirmEmailToken применяет токен из письма с хешем tokenHash: отмечает адрес подтверждённым,
+// а для токена смены адреса ещё и заменяет им текущий. Токен используется однократно.
+func (r *Repository) ConfirmEmailToken(ctx context.Context, tokenHash string) (*models.EmailToken, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var token models.EmailToken
+	err = tx.QueryRowContext(ctx, `
+		SELECT token_hash, user_id, email, purpose, created_at, expires_at FROM email_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		FOR UPDATE`, tokenHash).Scan(&token.TokenHash, &token.UserID, &token.Email, &token.Purpose, &token.CreatedAt, &token.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to find email token: ", err)
+		return nil, err
+	}
+
+	var result sql.Result
+	switch token.Purpose {
+	case models.EmailTokenChange:
+		var taken bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND id <> $2)`, token.Email, token.UserID).Scan(&taken)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to check email: ", err)
+			return nil, err
+		}
+		if taken {
+			return nil, fmt.Errorf("%s: %w", token.Email, ErrEmailTaken)
+		}
+		result, err = tx.ExecContext(ctx, `UPDATE users SET email = $1, email_verified = TRUE WHERE id = $2`, token.Email, token.UserID)
+	default:
+		// Если адрес успели сменить, токен старого адреса уже ничего не подтверждает
+		result, err = tx.ExecContext(ctx, `UPDATE users SET email_verified = TRUE WHERE id = $1 AND email = $2`, token.UserID, token.Email)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to confirm email: ", err)
+		return nil, err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE email_tokens SET used_at = NOW() WHERE token_hash = $1`, tokenHash); err != nil {
+		logger.ErrorContext(ctx, "Failed to mark email token used: ", err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit email confirmation: ", err)
+		return nil, err
+	}
+	return &token, nil
+}
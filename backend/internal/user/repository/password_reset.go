@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+)
+
+// CreatePasswordResetToken сохраняет токен сброса пароля. Прежние неиспользованные токены
+// пользователя становятся недействительными.
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, token.UserID); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete previous password reset tokens: ", err)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		token.TokenHash, token.UserID, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save password reset token: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit password reset token: ", err)
+		return err
+	}
+	return nil
+}
+
+// ResetPassword меняет пароль по токену сброса с хешем tokenHash и возвращает id пользователя.
+// Токен используется однократно, все сессии пользователя отзываются.
+func (r *Repository) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to use password reset token: ", err)
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET password = $1 WHERE id = $2`, hashedPassword, userID); err != nil {
+		logger.ErrorContext(ctx, "Failed to update user password: ", err)
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		logger.ErrorContext(ctx, "Failed to revoke user sessions: ", err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit password reset: ", err)
+		return 0, err
+	}
+	return userID, nil
+}
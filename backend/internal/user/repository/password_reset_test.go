@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+func setupTestDB(t *testing.T) (*Repository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err, "Failed to create sqlmock")
+	t.Cleanup(func() { db.Close() })
+	return NewRepository(db), mock
+}
+
+func TestCreatePasswordResetToken(t *testing.T) {
+	repo, mock := setupTestDB(t)
+	now := time.Now()
+	token := &models.PasswordResetToken{TokenHash: "hash", UserID: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	// Прежние неиспользованные токены удаляются вместе с созданием нового
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM password_reset_tokens WHERE user_id = \$1 AND used_at IS NULL`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO password_reset_tokens \(token_hash, user_id, created_at, expires_at\)`).
+		WithArgs("hash", int64(1), now, now.Add(time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.CreatePasswordResetToken(context.Background(), token))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetPassword(t *testing.T) {
+	repo, mock := setupTestDB(t)
+	useToken := `UPDATE password_reset_tokens SET used_at = NOW\(\)\s+WHERE token_hash = \$1 AND used_at IS NULL AND expires_at > NOW\(\)\s+RETURNING user_id`
+
+	t.Run("Valid Token", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(useToken).
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+		mock.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2`).
+			WithArgs("new-hash", int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE sessions SET revoked_at = NOW\(\) WHERE user_id = \$1 AND revoked_at IS NULL`).
+			WithArgs(int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		userID, err := repo.ResetPassword(context.Background(), "hash", "new-hash")
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), userID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	// Просроченный и уже использованный токены не проходят условие UPDATE, и пароль не меняется
+	for _, name := range []string{"Expired Token", "Reused Token"} {
+		t.Run(name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(useToken).
+				WithArgs("hash").
+				WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+			mock.ExpectRollback()
+
+			_, err := repo.ResetPassword(context.Background(), "hash", "new-hash")
+			assert.ErrorIs(t, err, ErrInvalidToken)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
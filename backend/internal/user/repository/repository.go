@@ -1,104 +1,189 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 
 	"budgetbuddy/internal/user/models"
-	"budgetbuddy/pkg/config"
 	"budgetbuddy/pkg/logger"
 
 	_ "github.com/lib/pq"
 )
 
+var (
+	// ErrSessionNotFound возвращается, когда refresh-токен не найден, истёк или его сессия отозвана.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrInvalidToken возвращается, когда токен из письма не найден, истёк или уже использован.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrEmailTaken возвращается, когда адрес уже занят другим пользователем.
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrTwoFactorEnabled возвращается при попытке заново настроить уже включённую
+	// двухфакторную аутентификацию.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+	// ErrRefreshTokenReused возвращается при повторном использовании уже заменённого
+	// refresh-токена; сессия при этом отзывается, так как токен мог быть украден.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
 type Repository struct {
 	db *sql.DB
 }
 
-func NewRepository(cfg *config.Config) (*Repository, error) {
-	db, err := sql.Open("postgres", cfg.DBUrl)
-	if err != nil {
-		return nil, err
-	}
-	return &Repository{db: db}, nil
-}
-
-func (r *Repository) Close() {
-	r.db.Close()
+// NewRepository создаёт репозиторий поверх общего пула соединений; закрывает пул его владелец.
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
 }
 
-func (r *Repository) SaveUser(user *models.User) (int64, error) {
-	query := `INSERT INTO users (email, password, name, created_at) 
-              VALUES ($1, $2, $3, $4) RETURNING id`
+func (r *Repository) SaveUser(ctx context.Context, user *models.User) (int64, error) {
+	query := `INSERT INTO users (email, password, name, base_currency, created_at) 
+              VALUES ($1, $2, $3, $4, $5) RETURNING id`
 	var id int64
-	err := r.db.QueryRow(query, user.Email, user.Password, user.Name, user.CreatedAt).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, user.Email, user.Password, user.Name, user.BaseCurrency, user.CreatedAt).Scan(&id)
 	if err != nil {
-		logger.Error("Failed to save user: ", err)
+		logger.ErrorContext(ctx, "Failed to save user: ", err)
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *Repository) FindUserByEmail(email string) (*models.User, error) {
-	query := `SELECT id, email, password, name, created_at FROM users WHERE email = $1`
+func (r *Repository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT id, email, password, name, base_currency, email_verified, created_at FROM users WHERE email = $1`
 	user := &models.User{}
-	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.CreatedAt)
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.BaseCurrency, &user.EmailVerified, &user.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		logger.Error("Failed to find user: ", err)
+		logger.ErrorContext(ctx, "Failed to find user: ", err)
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *Repository) GetUserIDByEmail(email string) (int64, error) {
-	query := `SELECT id FROM users WHERE email = $1`
-	var id int64
-	err := r.db.QueryRow(query, email).Scan(&id)
+// GetUserByID возвращает пользователя вместе с хешем пароля или nil, если его нет.
+func (r *Repository) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
+	query := `SELECT id, email, password, name, base_currency, email_verified, created_at FROM users WHERE id = $1`
+	user := &models.User{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.BaseCurrency, &user.EmailVerified, &user.CreatedAt)
 	if err == sql.ErrNoRows {
-		logger.Error("User not found for email: ", email)
-		return 0, nil
+		return nil, nil
 	}
 	if err != nil {
-		logger.Error("Failed to get user ID: ", err)
-		return 0, err
+		logger.ErrorContext(ctx, "Failed to find user: ", err)
+		return nil, err
 	}
-	return id, nil
+	return user, nil
+}
+
+// DeleteUser удаляет пользователя; его сессии удаляются каскадно, поэтому выпущенные
+// токены перестают приниматься. purge вызывается в той же транзакции БД до удаления
+// пользователя и должен удалить его данные в других сервисах; если purge возвращает
+// ошибку, ничего не удаляется.
+func (r *Repository) DeleteUser(ctx context.Context, userID int64, purge func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := purge(tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete user: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit user deletion: ", err)
+		return err
+	}
+	return nil
+}
+
+// RestoreProfile выполняет restore (восстановление данных finance-service) и задаёт имя
+// и базовую валюту профиля в одной транзакции БД. Пустые name и baseCurrency не меняются.
+func (r *Repository) RestoreProfile(ctx context.Context, userID int64, name, baseCurrency string, restore func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := restore(tx); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET name = COALESCE(NULLIF($1, ''), name), base_currency = COALESCE(NULLIF($2, ''), base_currency)
+		WHERE id = $3`, name, baseCurrency, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to restore user profile: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit profile restore: ", err)
+		return err
+	}
+	return nil
 }
 
 //добавлен метод для профиля и пароля
-func (r *Repository) GetUserProfile(userID int64) (*models.User, error) {
-	query := `SELECT id, email, name, created_at FROM users WHERE id = $1`
+func (r *Repository) GetUserProfile(ctx context.Context, userID int64) (*models.User, error) {
+	query := `SELECT id, email, name, base_currency, email_verified, created_at FROM users WHERE id = $1`
 	user := &models.User{}
-	err := r.db.QueryRow(query, userID).Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt)
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&user.ID, &user.Email, &user.Name, &user.BaseCurrency, &user.EmailVerified, &user.CreatedAt)
 	if err == sql.ErrNoRows {
-		logger.Error("User not found: ", userID)
+		logger.ErrorContext(ctx, "User not found: ", userID)
 		return nil, nil
 	}
 	if err != nil {
-		logger.Error("Failed to get user profile: ", err)
+		logger.ErrorContext(ctx, "Failed to get user profile: ", err)
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *Repository) UpdateUserName(userID int64, name string) error {
+func (r *Repository) UpdateUserName(ctx context.Context, userID int64, name string) error {
 	query := `UPDATE users SET name = $1 WHERE id = $2`
-	_, err := r.db.Exec(query, name, userID)
+	_, err := r.db.ExecContext(ctx, query, name, userID)
 	if err != nil {
-		logger.Error("Failed to update user name: ", err)
+		logger.ErrorContext(ctx, "Failed to update user name: ", err)
 		return err
 	}
 	return nil
 }
 
-func (r *Repository) UpdateUserPassword(userID int64, hashedPassword string) error {
+func (r *Repository) UpdateUserPassword(ctx context.Context, userID int64, hashedPassword string) error {
 	query := `UPDATE users SET password = $1 WHERE id = $2`
-	_, err := r.db.Exec(query, hashedPassword, userID)
+	_, err := r.db.ExecContext(ctx, query, hashedPassword, userID)
 	if err != nil {
-		logger.Error("Failed to update user password: ", err)
+		logger.ErrorContext(ctx, "Failed to update user password: ", err)
 		return err
 	}
 	return nil
 }
+
+func (r *Repository) UpdateUserBaseCurrency(ctx context.Context, userID int64, currency string) error {
+	query := `UPDATE users SET base_currency = $1 WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, currency, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to update user base currency: ", err)
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetUserBaseCurrency(ctx context.Context, userID int64) (string, error) {
+	query := `SELECT base_currency FROM users WHERE id = $1`
+	var currency string
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&currency)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get user base currency: ", err)
+		return "", err
+	}
+	return currency, nil
+}
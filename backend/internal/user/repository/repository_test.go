@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreProfile(t *testing.T) {
+	repo, mock := setupTestDB(t)
+
+	t.Run("Same Transaction", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO accounts`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`UPDATE users SET name = COALESCE\(NULLIF\(\$1, ''\), name\)`).
+			WithArgs("User", "EUR", int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.RestoreProfile(context.Background(), 1, "User", "EUR", func(tx *sql.Tx) error {
+			_, err := tx.Exec(`INSERT INTO accounts (user_id) VALUES (1)`)
+			return err
+		})
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Restore Fails", func(t *testing.T) {
+		// Профиль не меняется, если данные восстановить не удалось
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := repo.RestoreProfile(context.Background(), 1, "User", "EUR", func(tx *sql.Tx) error {
+			return errors.New("restore failed")
+		})
+		assert.EqualError(t, err, "restore failed")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreateSession сохраняет новую сессию входа.
+func (r *Repository) CreateSession(ctx context.Context, session *models.Session) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, refresh_token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		session.ID, session.UserID, session.RefreshTokenHash, session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to create session: ", err)
+		return err
+	}
+	return nil
+}
+
+// RotateSession заменяет refresh-токен сессии с хешем tokenHash на newHash и продлевает
+// сессию до expiresAt. Предъявление уже заменённого токена отзывает сессию и возвращает
+// ErrRefreshTokenReused.
+func (r *Repository) RotateSession(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (*models.Session, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var session models.Session
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, created_at FROM sessions
+		WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		FOR UPDATE`, tokenHash).Scan(&session.ID, &session.UserID, &session.CreatedAt)
+	if err == sql.ErrNoRows {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE sessions SET revoked_at = NOW()
+			WHERE previous_token_hash = $1 AND revoked_at IS NULL`, tokenHash)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to revoke session: ", err)
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			if err := tx.Commit(); err != nil {
+				logger.ErrorContext(ctx, "Failed to commit session revocation: ", err)
+				return nil, err
+			}
+			logger.ErrorContext(ctx, "Refresh token reused, session revoked")
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to find session: ", err)
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sessions SET refresh_token_hash = $1, previous_token_hash = refresh_token_hash, expires_at = $2
+		WHERE id = $3`, newHash, expiresAt, session.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to rotate session: ", err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit session rotation: ", err)
+		return nil, err
+	}
+	session.RefreshTokenHash = newHash
+	session.ExpiresAt = expiresAt
+	return &session, nil
+}
+
+// IsSessionActive сообщает, что сессия пользователя существует, не отозвана и не истекла.
+func (r *Repository) IsSessionActive(ctx context.Context, userID int64, id string) (bool, error) {
+	var active bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW())`,
+		id, userID).Scan(&active)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check session: ", err)
+		return false, err
+	}
+	return active, nil
+}
+
+// RevokeSession отзывает сессию пользователя.
+func (r *Repository) RevokeSession(ctx context.Context, userID int64, id string) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to revoke session: ", err)
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("session %s of user %d: %w", id, userID, ErrSessionNotFound)
+	}
+	return nil
+}
+
+// RevokeUserSessions отзывает все сессии пользователя и возвращает их число.
+func (r *Repository) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to revoke user sessions: ", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
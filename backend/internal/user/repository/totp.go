@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"budgetbuddy/internal/user/models"
+	"budgetbuddy/pkg/logger"
+	"context"
+	"database/sql"
+)
+
+// GetTOTP возвращает состояние двухфакторной аутентификации пользователя.
+func (r *Repository) GetTOTP(ctx context.Context, userID int64) (*models.TOTP, error) {
+	var totp models.TOTP
+	var secret sql.NullString
+	var lastStep sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1`, userID).
+		Scan(&secret, &totp.Enabled, &lastStep)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get totp settings: ", err)
+		return nil, err
+	}
+	totp.Secret = secret.String
+	if lastStep.Valid {
+		totp.LastStep = &lastStep.Int64
+	}
+	return &totp, nil
+}
+
+// SetPendingTOTPSecret сохраняет секрет начатой настройки двухфакторной аутентификации.
+// Если она уже включена, возвращается ErrTwoFactorEnabled.
+func (r *Repository) SetPendingTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE users SET totp_secret = $1, totp_last_step = NULL WHERE id = $2 AND NOT totp_enabled`,
+		secret, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to save totp secret: ", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTOTP включает двухфакторную аутентификацию с подтверждённым интервалом step
+// и заменяет резервные коды пользователя на codeHashes.
+func (r *Repository) EnableTOTP(ctx context.Context, userID, step int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users SET totp_enabled = TRUE, totp_last_step = $1
+		WHERE id = $2 AND totp_secret IS NOT NULL AND NOT totp_enabled`, step, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to enable totp: ", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrTwoFactorEnabled
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete recovery codes: ", err)
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			logger.ErrorContext(ctx, "Failed to save recovery code: ", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit totp enabling: ", err)
+		return err
+	}
+	return nil
+}
+
+// UseTOTPStep принимает код интервала step, если он новее последнего принятого.
+// Возвращает false, если код этого или более раннего интервала уже использовался.
+func (r *Repository) UseTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users SET totp_last_step = $1
+		WHERE id = $2 AND totp_enabled AND (totp_last_step IS NULL OR totp_last_step < $1)`, step, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to use totp step: ", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UseRecoveryCode отмечает резервный код с хешем codeHash использованным.
+// Возвращает false, если такого неиспользованного кода нет.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to use recovery code: ", err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check rows affected: ", err)
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DisableTOTP выключает двухфакторную аутентификацию и удаляет секрет и резервные коды.
+func (r *Repository) DisableTOTP(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to begin transaction: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL WHERE id = $1`, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to disable totp: ", err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		logger.ErrorContext(ctx, "Failed to delete recovery codes: ", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.ErrorContext(ctx, "Failed to commit totp disabling: ", err)
+		return err
+	}
+	return nil
+}
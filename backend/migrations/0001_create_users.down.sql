@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS email_tokens;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS goal_contributions;
+DROP TABLE IF EXISTS transfer_legs;
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS recurring_occurrences;
+DROP TABLE IF EXISTS recurring_rules;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS budgets;
+DROP TABLE IF EXISTS budget_template_months;
+DROP TABLE IF EXISTS budget_templates;
+DROP TABLE IF EXISTS goals;
+DROP TABLE IF EXISTS expenses;
+DROP TABLE IF EXISTS incomes;
+DROP TABLE IF EXISTS accounts;
+DROP TABLE IF EXISTS subcategories;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,247 @@
+-- Финансовые данные пользователей. Как и 0001, миграция идемпотентна и доводит базы,
+-- созданные прежними проверками при старте, до текущей схемы.
+
+-- Категории и подкатегории с user_id = NULL — системные и видны всем пользователям,
+-- остальные принадлежат пользователю
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id),
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL CHECK (type IN ('income', 'expense')),
+    archived BOOLEAN NOT NULL DEFAULT FALSE
+);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS subcategories (
+    id SERIAL PRIMARY KEY,
+    category_id INTEGER REFERENCES categories(id),
+    user_id INTEGER REFERENCES users(id),
+    name VARCHAR(255) NOT NULL
+);
+ALTER TABLE subcategories ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id);
+
+-- Уникальность названия: среди системных категорий и среди категорий одного пользователя
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_type_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_system_name ON categories (name, type) WHERE user_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_user_name ON categories (user_id, name, type) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_subcategories_category ON subcategories (category_id);
+
+-- Системные категории по умолчанию
+INSERT INTO categories (name, type) VALUES
+    ('Продукты', 'expense'),
+    ('Транспорт', 'expense'),
+    ('Жильё', 'expense'),
+    ('Здоровье', 'expense'),
+    ('Развлечения', 'expense'),
+    ('Кафе и рестораны', 'expense'),
+    ('Зарплата', 'income'),
+    ('Подарки', 'income')
+ON CONFLICT (name, type) WHERE user_id IS NULL DO NOTHING;
+
+-- Счета пользователя с начальным остатком
+CREATE TABLE IF NOT EXISTS accounts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(10) NOT NULL CHECK (type IN ('cash', 'card', 'savings', 'credit')),
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    opening_balance NUMERIC(18,2) NOT NULL DEFAULT 0,
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS incomes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    amount NUMERIC(18,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    category_id INTEGER REFERENCES categories(id),
+    subcategory_id INTEGER REFERENCES subcategories(id),
+    account_id INTEGER REFERENCES accounts(id),
+    description TEXT,
+    tags TEXT[],
+    date DATE NOT NULL,
+    note TEXT
+);
+
+CREATE TABLE IF NOT EXISTS expenses (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    amount NUMERIC(18,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    category_id INTEGER REFERENCES categories(id),
+    subcategory_id INTEGER REFERENCES subcategories(id),
+    account_id INTEGER REFERENCES accounts(id),
+    description TEXT,
+    tags TEXT[],
+    date DATE NOT NULL,
+    note TEXT
+);
+
+CREATE TABLE IF NOT EXISTS goals (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    target_amount NUMERIC(18,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    deadline DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Шаблоны бюджетов, которые автоматически применяются ко всем месяцам начиная со start_month
+CREATE TABLE IF NOT EXISTS budget_templates (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    category_id INTEGER NOT NULL REFERENCES categories(id),
+    amount NUMERIC(18,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    rollover BOOLEAN NOT NULL DEFAULT FALSE,
+    start_month VARCHAR(7) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, category_id)
+);
+
+-- Месяцы, к которым шаблон уже применён, чтобы удалённый пользователем бюджет не создавался заново
+CREATE TABLE IF NOT EXISTS budget_template_months (
+    template_id INTEGER NOT NULL REFERENCES budget_templates(id) ON DELETE CASCADE,
+    month VARCHAR(7) NOT NULL,
+    PRIMARY KEY (template_id, month)
+);
+
+CREATE TABLE IF NOT EXISTS budgets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id),
+    category_id INTEGER REFERENCES categories(id),
+    amount NUMERIC(18,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    month VARCHAR(7) NOT NULL,
+    rollover BOOLEAN NOT NULL DEFAULT FALSE,
+    rollover_amount NUMERIC(18,2) NOT NULL DEFAULT 0,
+    template_id INTEGER REFERENCES budget_templates(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Столбцы, добавленные после первых версий таблиц
+ALTER TABLE incomes ADD COLUMN IF NOT EXISTS subcategory_id INTEGER REFERENCES subcategories(id);
+ALTER TABLE incomes ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE incomes ADD COLUMN IF NOT EXISTS tags TEXT[];
+ALTER TABLE incomes ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE incomes ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS subcategory_id INTEGER REFERENCES subcategories(id);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS tags TEXT[];
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id);
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS rollover BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS rollover_amount NUMERIC(18,2) NOT NULL DEFAULT 0;
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS template_id INTEGER REFERENCES budget_templates(id) ON DELETE SET NULL;
+
+-- Расширение точности денежных столбцов, созданных как DECIMAL(10,2)
+ALTER TABLE incomes ALTER COLUMN amount TYPE NUMERIC(18,2);
+ALTER TABLE expenses ALTER COLUMN amount TYPE NUMERIC(18,2);
+ALTER TABLE goals ALTER COLUMN target_amount TYPE NUMERIC(18,2);
+ALTER TABLE budgets ALTER COLUMN amount TYPE NUMERIC(18,2);
+
+-- Один бюджет на категорию в месяц: дубликаты, созданные до появления ограничения,
+-- удаляются с сохранением последнего
+DELETE FROM budgets a USING budgets b
+WHERE a.user_id = b.user_id AND a.category_id = b.category_id AND a.month = b.month AND a.id < b.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_user_category_month ON budgets (user_id, category_id, month);
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    base_currency VARCHAR(3) NOT NULL,
+    quote_currency VARCHAR(3) NOT NULL,
+    rate_date DATE NOT NULL,
+    rate NUMERIC(20,10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (base_currency, quote_currency, rate_date)
+);
+
+CREATE TABLE IF NOT EXISTS recurring_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    type VARCHAR(50) NOT NULL CHECK (type IN ('income', 'expense')),
+    amount NUMERIC(18,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    category_id INTEGER REFERENCES categories(id),
+    subcategory_id INTEGER REFERENCES subcategories(id),
+    description TEXT NOT NULL DEFAULT '',
+    tags TEXT[],
+    note TEXT NOT NULL DEFAULT '',
+    frequency VARCHAR(10) NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly', 'yearly')),
+    repeat_interval INTEGER NOT NULL DEFAULT 1 CHECK (repeat_interval > 0),
+    start_date DATE NOT NULL,
+    end_date DATE,
+    max_count INTEGER CHECK (max_count > 0),
+    occurrence_count INTEGER NOT NULL DEFAULT 0,
+    next_date DATE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_recurring_rules_next_date ON recurring_rules (next_date) WHERE next_date IS NOT NULL;
+
+-- Ключ (rule_id, occurrence_date) не даёт планировщику создать одно и то же повторение дважды
+CREATE TABLE IF NOT EXISTS recurring_occurrences (
+    rule_id INTEGER NOT NULL REFERENCES recurring_rules(id) ON DELETE CASCADE,
+    occurrence_date DATE NOT NULL,
+    transaction_id INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (rule_id, occurrence_date)
+);
+
+-- Перевод хранится как пара движений по счетам (списание и зачисление)
+-- и не учитывается в доходах и расходах
+CREATE TABLE IF NOT EXISTS transfers (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    date DATE NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS transfer_legs (
+    transfer_id INTEGER NOT NULL REFERENCES transfers(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES accounts(id),
+    amount NUMERIC(18,2) NOT NULL CHECK (amount <> 0),
+    PRIMARY KEY (transfer_id, account_id)
+);
+CREATE INDEX IF NOT EXISTS idx_transfers_user_date ON transfers (user_id, date, id);
+CREATE INDEX IF NOT EXISTS idx_transfer_legs_account ON transfer_legs (account_id);
+
+-- Накопленная сумма цели считается как сумма взносов; взнос может ссылаться на расход или перевод
+CREATE TABLE IF NOT EXISTS goal_contributions (
+    id SERIAL PRIMARY KEY,
+    goal_id INTEGER NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    amount NUMERIC(18,2) NOT NULL CHECK (amount <> 0),
+    date DATE NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    expense_id INTEGER UNIQUE REFERENCES expenses(id) ON DELETE CASCADE,
+    transfer_id INTEGER UNIQUE REFERENCES transfers(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE goal_contributions ADD COLUMN IF NOT EXISTS transfer_id INTEGER UNIQUE REFERENCES transfers(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_goal_contributions_goal ON goal_contributions (goal_id, date, id);
+
+-- Перенос накопленных сумм из goals.current_amount во взносы
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'goals' AND column_name = 'current_amount'
+    ) THEN
+        INSERT INTO goal_contributions (goal_id, user_id, amount, date, note)
+        SELECT id, user_id, current_amount, created_at::date, 'Initial balance' FROM goals WHERE current_amount <> 0;
+        ALTER TABLE goals DROP COLUMN current_amount;
+    END IF;
+END
+$$;
+
+-- Индексы по счетам и для выборки и keyset-пагинации транзакций пользователя
+CREATE INDEX IF NOT EXISTS idx_incomes_account ON incomes (account_id) WHERE account_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_expenses_account ON expenses (account_id) WHERE account_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_incomes_user_date ON incomes (user_id, date, id);
+CREATE INDEX IF NOT EXISTS idx_expenses_user_date ON expenses (user_id, date, id);
+CREATE INDEX IF NOT EXISTS idx_incomes_user_amount ON incomes (user_id, amount, id);
+CREATE INDEX IF NOT EXISTS idx_expenses_user_amount ON expenses (user_id, amount, id);
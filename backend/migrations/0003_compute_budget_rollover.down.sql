@@ -0,0 +1 @@
+ALTER TABLE budgets ADD COLUMN IF NOT EXISTS rollover_amount NUMERIC(18,2) NOT NULL DEFAULT 0;
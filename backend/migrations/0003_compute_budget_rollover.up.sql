@@ -0,0 +1,3 @@
+-- Перенесённый остаток бюджета считается при чтении по цепочке месяцев, а не хранится:
+-- сохранённое значение устаревало при расходах задним числом и в неоткрытых месяцах
+ALTER TABLE budgets DROP COLUMN IF EXISTS rollover_amount;
@@ -0,0 +1,3 @@
+-- Разделение категорий по владельцам не откатывается: после него нельзя понять,
+-- какие копии были одной общей категорией
+SELECT 1;
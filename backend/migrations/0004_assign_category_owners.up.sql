@@ -0,0 +1,85 @@
+-- В 0002 у существующих категорий и подкатегорий user_id остался NULL, и пользовательские
+-- категории стали системными. Владелец восстанавливается по записям, которые на них ссылаются;
+-- категория, которой пользовались несколько человек, копируется каждому из них.
+-- NULL остаётся только у системных категорий из 0002.
+CREATE TEMPORARY TABLE seed_categories (name VARCHAR(255), type VARCHAR(50)) ON COMMIT DROP;
+INSERT INTO seed_categories (name, type) VALUES
+    ('Продукты', 'expense'),
+    ('Транспорт', 'expense'),
+    ('Жильё', 'expense'),
+    ('Здоровье', 'expense'),
+    ('Развлечения', 'expense'),
+    ('Кафе и рестораны', 'expense'),
+    ('Зарплата', 'income'),
+    ('Подарки', 'income');
+
+CREATE TEMPORARY TABLE category_owners ON COMMIT DROP AS
+SELECT DISTINCT c.id AS category_id, r.user_id
+FROM categories c
+JOIN (
+    SELECT category_id, user_id FROM incomes
+    UNION SELECT category_id, user_id FROM expenses
+    UNION SELECT category_id, user_id FROM budgets
+    UNION SELECT category_id, user_id FROM budget_templates
+    UNION SELECT category_id, user_id FROM recurring_rules
+    UNION SELECT category_id, user_id FROM subcategories
+) r ON r.category_id = c.id AND r.user_id IS NOT NULL
+WHERE c.user_id IS NULL
+    AND NOT EXISTS (SELECT 1 FROM seed_categories s WHERE s.name = c.name AND s.type = c.type);
+
+INSERT INTO categories (user_id, name, type, archived)
+SELECT o.user_id, c.name, c.type, c.archived
+FROM category_owners o JOIN categories c ON c.id = o.category_id
+ON CONFLICT (user_id, name, type) WHERE user_id IS NOT NULL DO NOTHING;
+
+CREATE TEMPORARY TABLE category_map ON COMMIT DROP AS
+SELECT o.category_id AS old_id, o.user_id, n.id AS new_id
+FROM category_owners o
+JOIN categories c ON c.id = o.category_id
+JOIN categories n ON n.user_id = o.user_id AND n.name = c.name AND n.type = c.type;
+
+-- Подкатегория без владельца копируется каждому владельцу своей категории, а у системной
+-- категории — каждому пользователю, чьи записи на неё ссылаются
+CREATE TEMPORARY TABLE subcategory_owners ON COMMIT DROP AS
+SELECT s.id AS old_id, m.user_id, m.new_id AS category_id, s.name
+FROM subcategories s JOIN category_map m ON m.old_id = s.category_id
+WHERE s.user_id IS NULL
+UNION
+SELECT s.id, r.user_id, s.category_id, s.name
+FROM subcategories s
+JOIN (
+    SELECT subcategory_id, user_id FROM incomes
+    UNION SELECT subcategory_id, user_id FROM expenses
+    UNION SELECT subcategory_id, user_id FROM recurring_rules
+) r ON r.subcategory_id = s.id
+WHERE s.user_id IS NULL AND s.category_id NOT IN (SELECT old_id FROM category_map);
+
+INSERT INTO subcategories (category_id, user_id, name)
+SELECT DISTINCT o.category_id, o.user_id, o.name
+FROM subcategory_owners o
+WHERE NOT EXISTS (
+    SELECT 1 FROM subcategories n WHERE n.category_id = o.category_id AND n.user_id = o.user_id AND n.name = o.name);
+
+CREATE TEMPORARY TABLE subcategory_map ON COMMIT DROP AS
+SELECT o.old_id, o.user_id, (
+    SELECT MIN(n.id) FROM subcategories n WHERE n.category_id = o.category_id AND n.user_id = o.user_id AND n.name = o.name
+) AS new_id
+FROM subcategory_owners o;
+
+UPDATE incomes x SET category_id = m.new_id FROM category_map m WHERE x.category_id = m.old_id AND x.user_id = m.user_id;
+UPDATE expenses x SET category_id = m.new_id FROM category_map m WHERE x.category_id = m.old_id AND x.user_id = m.user_id;
+UPDATE budgets x SET category_id = m.new_id FROM category_map m WHERE x.category_id = m.old_id AND x.user_id = m.user_id;
+UPDATE budget_templates x SET category_id = m.new_id FROM category_map m WHERE x.category_id = m.old_id AND x.user_id = m.user_id;
+UPDATE recurring_rules x SET category_id = m.new_id FROM category_map m WHERE x.category_id = m.old_id AND x.user_id = m.user_id;
+UPDATE subcategories x SET category_id = m.new_id FROM category_map m WHERE x.category_id = m.old_id AND x.user_id = m.user_id;
+
+UPDATE incomes x SET subcategory_id = m.new_id FROM subcategory_map m WHERE x.subcategory_id = m.old_id AND x.user_id = m.user_id;
+UPDATE expenses x SET subcategory_id = m.new_id FROM subcategory_map m WHERE x.subcategory_id = m.old_id AND x.user_id = m.user_id;
+UPDATE recurring_rules x SET subcategory_id = m.new_id FROM subcategory_map m WHERE x.subcategory_id = m.old_id AND x.user_id = m.user_id;
+
+-- Исходные строки больше ни на что не ссылаются; категории и подкатегории, которыми никто
+-- не пользовался, приписать некому, поэтому они удаляются
+DELETE FROM subcategories WHERE user_id IS NULL;
+DELETE FROM categories c
+WHERE c.user_id IS NULL
+    AND NOT EXISTS (SELECT 1 FROM seed_categories s WHERE s.name = c.name AND s.type = c.type);
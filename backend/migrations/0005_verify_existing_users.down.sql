@@ -0,0 +1,2 @@
+-- Какие адреса были подтверждены этой миграцией, не сохраняется
+SELECT 1;
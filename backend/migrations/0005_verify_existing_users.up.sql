@@ -0,0 +1,5 @@
+-- Пользователи, зарегистрированные до появления подтверждения адреса, не получали письма
+-- с токеном: их адреса считаются подтверждёнными
+UPDATE users u SET email_verified = TRUE
+WHERE NOT u.email_verified
+    AND NOT EXISTS (SELECT 1 FROM email_tokens t WHERE t.user_id = u.id AND t.purpose = 'verify');
@@ -0,0 +1,5 @@
+-- Удалённые записи об отзыве не восстанавливаются, возвращается только таблица
+CREATE TABLE IF NOT EXISTS token_revocations (
+    email VARCHAR(255) PRIMARY KEY,
+    revoked_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Отзыв токенов по email заменён сессиями: таблица, созданная прежними версиями, больше не используется
+DROP TABLE IF EXISTS token_revocations;
@@ -0,0 +1,54 @@
+// Package migrations содержит SQL-миграции общей базы user-service и finance-service.
+// Новая миграция — пара файлов со следующим номером: NNNN_name.up.sql и NNNN_name.down.sql.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/migrate"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// NewMigrator создаёт Migrator для миграций этого пакета.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	return migrate.New(db, FS)
+}
+
+// RunMigrations применяет все новые миграции при старте сервиса.
+func RunMigrations(db *sql.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		logger.Error("Failed to load migrations: ", err)
+		return err
+	}
+	applied, err := migrator.Up()
+	if err != nil {
+		logger.Error("Failed to apply migrations: ", err)
+		return err
+	}
+	logger.Info("Migrations executed successfully, applied ", applied)
+	return nil
+}
+
+// CheckApplied возвращает ошибку, если к базе применены не все миграции пакета:
+// проверка готовности сервиса для /readyz.
+func CheckApplied(ctx context.Context, db *sql.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations pending, first %d_%s", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
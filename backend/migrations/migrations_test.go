@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"testing"
+
+	"budgetbuddy/pkg/migrate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := migrate.Load(FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		// Версии идут подряд, и у каждой миграции есть откат
+		assert.Equal(t, int64(i+1), m.Version, m.Name)
+		assert.NotEmpty(t, m.Down, m.Name)
+	}
+}
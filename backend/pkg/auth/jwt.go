@@ -1,23 +1,112 @@
 package auth
 
 import (
-	"budgetbuddy/pkg/config"
-	"budgetbuddy/pkg/logger"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-func GenerateJWT(email string) (string, error) {
-	cfg, err := config.Load()
-	if err != nil {
-		logger.Error("Failed to load config: ", err)
+// Issuer и Audience записываются в claims iss и aud и проверяются AuthMiddleware.
+const (
+	Issuer   = "budgetbuddy"
+	Audience = "budgetbuddy-api"
+)
+
+const (
+	// AccessTokenTTL — срок действия access-токена. Токен короткоживущий:
+	// его отзыв проверяется по сессии, а продлевается он refresh-токеном.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL — срок действия refresh-токена; каждое обновление выдаёт новый.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// GenerateJWT выпускает access-токен пользователя userID (claim sub) в сессии sessionID.
+// Идентификатор сессии записывается в claim jti, по нему AuthMiddleware проверяет,
+// что сессия не отозвана.
+func GenerateJWT(secret string, userID int64, sessionID string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   strconv.FormatInt(userID, 10),
+		Id:        sessionID,
+		Issuer:    Issuer,
+		Audience:  Audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(AccessTokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(secret))
+}
+
+// ChallengeAudience — аудитория токена второго шага входа при двухфакторной аутентификации.
+// Такой токен подтверждает только пароль, поэтому AuthMiddleware его не принимает.
+const ChallengeAudience = "budgetbuddy-2fa"
+
+// ChallengeTokenTTL — время на ввод кода двухфакторной аутентификации после ввода пароля.
+const ChallengeTokenTTL = 5 * time.Minute
+
+// GenerateChallengeToken выпускает токен второго шага входа пользователя userID.
+func GenerateChallengeToken(jwtSecret string, userID int64) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   strconv.FormatInt(userID, 10),
+		Issuer:    Issuer,
+		Audience:  ChallengeAudience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ChallengeTokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(jwtSecret))
+}
+
+// ParseChallengeToken проверяет токен второго шага входа и возвращает id пользователя.
+func ParseChallengeToken(jwtSecret, tokenStr string) (int64, error) {
+	var claims jwt.StandardClaims
+	token, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid challenge token: %v", err)
+	}
+	if !claims.VerifyIssuer(Issuer, true) || !claims.VerifyAudience(ChallengeAudience, true) {
+		return 0, errors.New("invalid challenge token audience")
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, errors.New("invalid challenge token subject")
+	}
+	return userID, nil
+}
+
+// NewSessionID возвращает случайный идентификатор сессии.
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
+	return hex.EncodeToString(b), nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email": email,
-		"exp":   time.Now().Add(time.Hour * 24).Unix(),
-	})
-	return token.SignedString([]byte(cfg.JWTSecret))
+// GenerateToken возвращает новый случайный токен (refresh-токен, токен подтверждения из письма)
+// и его хеш. Клиенту отдаётся токен, в базе хранится только хеш.
+func GenerateToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken возвращает SHA-256 токена в hex.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token, hash, err := GenerateToken()
+	require.NoError(t, err)
+	assert.Len(t, token, 43)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, HashToken(token), hash)
+	assert.NotEqual(t, token, hash)
+
+	other, _, err := GenerateToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestNewSessionID(t *testing.T) {
+	id, err := NewSessionID()
+	require.NoError(t, err)
+	assert.Len(t, id, 32)
+}
+
+func TestChallengeToken(t *testing.T) {
+	token, err := GenerateChallengeToken("secret", 7)
+	require.NoError(t, err)
+
+	userID, err := ParseChallengeToken("secret", token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), userID)
+
+	_, err = ParseChallengeToken("other-secret", token)
+	assert.Error(t, err)
+
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject: "7", Issuer: Issuer, Audience: Audience,
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = ParseChallengeToken("secret", access)
+	assert.Error(t, err)
+}
@@ -14,4 +14,18 @@ func TestNewTestConfig(t *testing.T) {
     assert.Equal(t, "test-secret", cfg.JWTSecret)
     assert.Equal(t, ":8081", cfg.FinanceServicePort)
     assert.Equal(t, ":8080", cfg.UserServicePort)
-}
\ No newline at end of file
+}
+
+// Настройки почты нужны только user-service и проверяются отдельно от Load
+func TestValidateMail(t *testing.T) {
+    cfg := NewTestConfig()
+    assert.NoError(t, cfg.ValidateMail())
+
+    cfg.MailDriver = MailDriverSMTP
+    assert.EqualError(t, cfg.ValidateMail(), "SMTP_HOST environment variable is required, set MAIL_DRIVER=file for local development")
+    cfg.SMTPHost = "smtp.example.com"
+    assert.NoError(t, cfg.ValidateMail())
+
+    cfg.MailDriver = "log"
+    assert.Error(t, cfg.ValidateMail())
+}
@@ -0,0 +1,69 @@
+// Package database открывает общий пул соединений с Postgres для репозиториев сервиса.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"budgetbuddy/pkg/config"
+	"budgetbuddy/pkg/logger"
+
+	_ "github.com/lib/pq"
+)
+
+// Паузы между попытками подключения при старте растут вдвое от initialBackoff до maxBackoff.
+var (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+// Open открывает пул соединений с настройками из cfg и дожидается доступности базы
+// не дольше cfg.DBConnectTimeout: сервисы могут стартовать раньше самой базы.
+func Open(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DBUrl)
+	if err != nil {
+		return nil, err
+	}
+	Configure(db, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DBConnectTimeout)
+	defer cancel()
+	if err := Wait(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Configure задаёт размер пула и время жизни соединений.
+func Configure(db *sql.DB, cfg *config.Config) {
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+}
+
+// Wait проверяет соединение с базой, повторяя попытки с экспоненциальной паузой,
+// пока база не ответит или не истечёт ctx.
+func Wait(ctx context.Context, db *sql.DB) error {
+	delay := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("database is not reachable after %d attempts: %w", attempt, err)
+		}
+		logger.Error("Database is not reachable, retrying in ", delay, ": ", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database is not reachable after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxBackoff)
+	}
+}
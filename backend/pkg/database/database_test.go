@@ -0,0 +1,66 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"budgetbuddy/pkg/logger"
+	"budgetbuddy/pkg/metrics"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setup создаёт базу-заглушку, отвечающую на Ping, и укорачивает паузы между попытками.
+func setup(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	logger.Init()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	initial, max := initialBackoff, maxBackoff
+	initialBackoff, maxBackoff = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { initialBackoff, maxBackoff = initial, max })
+	return db, mock
+}
+
+func TestWaitRetries(t *testing.T) {
+	db, mock := setup(t)
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing()
+
+	require.NoError(t, Wait(context.Background(), db))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWaitTimeout(t *testing.T) {
+	db, mock := setup(t)
+	for i := 0; i < 100; i++ {
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := Wait(ctx, db)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	db, _ := setup(t)
+	db.SetMaxOpenConns(7)
+	reg := metrics.NewRegistry()
+	RegisterMetrics(reg, db)
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.Write(&buf))
+	assert.Contains(t, buf.String(), "# TYPE db_max_open_connections gauge\ndb_max_open_connections 7\n")
+	assert.Contains(t, buf.String(), "# TYPE db_wait_count_total counter\ndb_wait_count_total 0\n")
+}
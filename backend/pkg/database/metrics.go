@@ -0,0 +1,40 @@
+package database
+
+import (
+	"database/sql"
+
+	"budgetbuddy/pkg/metrics"
+)
+
+// RegisterMetrics добавляет в reg статистику пула соединений db (sql.DB.Stats):
+// текущее число соединений — датчиками, накопленные с запуска величины — счётчиками.
+func RegisterMetrics(reg *metrics.Registry, db *sql.DB) {
+	stats := []struct {
+		name, help string
+		register   func(name, help string, fn func() float64)
+		value      func(s sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "Maximum number of open connections to the database.", reg.NewGaugeFunc,
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "Number of established connections, both in use and idle.", reg.NewGaugeFunc,
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "Number of connections currently in use.", reg.NewGaugeFunc,
+			func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "Number of idle connections.", reg.NewGaugeFunc,
+			func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"db_wait_count_total", "Total number of connections waited for.", reg.NewCounterFunc,
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", reg.NewCounterFunc,
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns.", reg.NewCounterFunc,
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_idle_time_closed_total", "Total number of connections closed due to SetConnMaxIdleTime.", reg.NewCounterFunc,
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime.", reg.NewCounterFunc,
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, stat := range stats {
+		value := stat.value
+		stat.register(stat.name, stat.help, func() float64 { return value(db.Stats()) })
+	}
+}
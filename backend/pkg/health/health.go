@@ -0,0 +1,78 @@
+// Package health — эндпоинты проверки состояния сервиса: /healthz отвечает, пока процесс жив,
+// /readyz — только когда доступны зависимости (база, применённые миграции).
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"budgetbuddy/pkg/logger"
+)
+
+// checkTimeout ограничивает время всех проверок готовности одного запроса.
+const checkTimeout = 2 * time.Second
+
+// Check — именованная проверка готовности; ошибка означает, что сервис не готов.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Response — тело ответа /healthz и /readyz; Checks содержит результат каждой проверки: "ok" или "unavailable".
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Register добавляет /healthz и /readyz в mux.
+func Register(mux *http.ServeMux, checks ...Check) {
+	mux.HandleFunc("/healthz", Live)
+	mux.HandleFunc("/readyz", Ready(checks...))
+}
+
+// Live отвечает 200, пока процесс обрабатывает запросы; зависимости не проверяются,
+// чтобы недоступная база не приводила к перезапуску сервиса.
+func Live(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeResponse(w, http.StatusOK, Response{Status: "ok"})
+}
+
+// Ready выполняет все проверки и отвечает 200, если они прошли, иначе 503.
+func Ready(checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		response := Response{Status: "ok", Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for _, check := range checks {
+			if err := check.Run(ctx); err != nil {
+				// Текст ошибки может раскрыть адрес базы или драйвер, поэтому он остаётся в журнале
+				logger.ErrorContext(r.Context(), "Readiness check ", check.Name, " failed: ", err)
+				response.Checks[check.Name] = "unavailable"
+				response.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			response.Checks[check.Name] = "ok"
+		}
+		writeResponse(w, status, response)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, status int, response Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
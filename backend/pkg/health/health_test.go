@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"budgetbuddy/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(mux *http.ServeMux, path string) (int, Response) {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var response Response
+	json.NewDecoder(w.Body).Decode(&response)
+	return w.Code, response
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	logger.Init()
+	var dbErr error
+	mux := http.NewServeMux()
+	Register(mux,
+		Check{Name: "database", Run: func(ctx context.Context) error { return dbErr }},
+		Check{Name: "migrations", Run: func(ctx context.Context) error { return nil }},
+	)
+
+	code, response := get(mux, "/readyz")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Response{Status: "ok", Checks: map[string]string{"database": "ok", "migrations": "ok"}}, response)
+
+	dbErr = errors.New("connection refused")
+	code, response = get(mux, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", response.Status)
+	assert.Equal(t, "unavailable", response.Checks["database"])
+	assert.Equal(t, "ok", response.Checks["migrations"])
+
+	// Liveness не зависит от базы
+	code, response = get(mux, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", response.Status)
+}
@@ -1,51 +1,164 @@
+// Package logger — журнал сервисов поверх log/slog: уровни, вывод текстом или JSON
+// и идентификатор запроса в записях, сделанных с контекстом HTTP-запроса.
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-type Logger struct {
-	infoLogger  *log.Logger
-	warnLogger  *log.Logger
-	errorLogger *log.Logger
-}
+// Форматы вывода журнала.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
 
-var logger *Logger
+var logger = slog.New(newHandler(os.Stdout, slog.LevelInfo, FormatText))
 
+// Init задаёт журнал по умолчанию: уровень info, текстовый вывод в stdout.
+// Вызывается при старте до загрузки конфигурации, после неё — Configure.
 func Init() {
-	logger = &Logger{
-		infoLogger:  log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile),
-		warnLogger:  log.New(os.Stdout, "WARN: ", log.Ldate|log.Ltime|log.Lshortfile),
-		errorLogger: log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
+	logger = slog.New(newHandler(os.Stdout, slog.LevelInfo, FormatText))
+}
+
+// Configure задаёт уровень (debug, info, warn, error) и формат (text, json) журнала.
+func Configure(level, format string) error {
+	return configure(os.Stdout, level, format)
+}
+
+func configure(w io.Writer, level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	format = strings.ToLower(format)
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("invalid log format %q, use %s or %s", format, FormatText, FormatJSON)
+	}
+	logger = slog.New(newHandler(w, l, format))
+	return nil
+}
+
+func newHandler(w io.Writer, level slog.Level, format string) slog.Handler {
+	opts := &slog.HandlerOptions{AddSource: true, Level: level}
+	if format == FormatJSON {
+		return contextHandler{slog.NewJSONHandler(w, opts)}
+	}
+	return contextHandler{slog.NewTextHandler(w, opts)}
+}
+
+// RequestInfo — данные HTTP-запроса, которые добавляются к записям журнала с его контекстом.
+// Пользователь становится известен только после аутентификации, поэтому хранится изменяемым.
+type RequestInfo struct {
+	ID     string
+	userID atomic.Int64
+}
+
+// UserID возвращает пользователя запроса или 0, если запрос не аутентифицирован.
+func (i *RequestInfo) UserID() int64 {
+	return i.userID.Load()
+}
+
+type requestInfoKey struct{}
+
+// WithRequest возвращает контекст запроса с идентификатором id.
+func WithRequest(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, &RequestInfo{ID: id})
+}
+
+// RequestFromContext возвращает данные запроса, установленные WithRequest.
+func RequestFromContext(ctx context.Context) (*RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info, ok
+}
+
+// SetUserID запоминает аутентифицированного пользователя запроса.
+func SetUserID(ctx context.Context, userID int64) {
+	if info, ok := RequestFromContext(ctx); ok {
+		info.userID.Store(userID)
+	}
+}
+
+// contextHandler добавляет к записи request_id и user_id из контекста запроса.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info, ok := RequestFromContext(ctx); ok {
+		r.AddAttrs(slog.String("request_id", info.ID))
+		if userID := info.UserID(); userID != 0 {
+			r.AddAttrs(slog.Int64("user_id", userID))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// write пишет запись с местом вызова функции пакета, а не самого write.
+func write(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if !logger.Enabled(ctx, level) {
+		return
 	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // runtime.Callers, write, функция пакета
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.AddAttrs(attrs...)
+	logger.Handler().Handle(ctx, r)
 }
 
 func Info(v ...interface{}) {
-	logger.infoLogger.Println(v...)
+	write(context.Background(), slog.LevelInfo, fmt.Sprint(v...))
 }
 
 func Warn(v ...interface{}) {
-	logger.warnLogger.Println(v...)
+	write(context.Background(), slog.LevelWarn, fmt.Sprint(v...))
 }
 
 func Error(v ...interface{}) {
-	logger.errorLogger.Println(v...)
+	write(context.Background(), slog.LevelError, fmt.Sprint(v...))
+}
+
+// InfoContext, WarnContext и ErrorContext добавляют к записи данные запроса из ctx.
+func InfoContext(ctx context.Context, v ...interface{}) {
+	write(ctx, slog.LevelInfo, fmt.Sprint(v...))
+}
+
+func WarnContext(ctx context.Context, v ...interface{}) {
+	write(ctx, slog.LevelWarn, fmt.Sprint(v...))
+}
+
+func ErrorContext(ctx context.Context, v ...interface{}) {
+	write(ctx, slog.LevelError, fmt.Sprint(v...))
 }
 
-func Fatal(v ...interface{}) {
-	logger.errorLogger.Println(v...)
-	os.Exit(1)
+// LogAttrs пишет сообщение с именованными полями, например журнал HTTP-запросов.
+func LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	write(ctx, level, msg, attrs...)
 }
 
 func Printf(format string, v ...interface{}) {
-	logger.infoLogger.Printf(format, v...)
+	write(context.Background(), slog.LevelInfo, fmt.Sprintf(format, v...))
 }
 
 func Errorf(format string, v ...interface{}) {
-	logger.errorLogger.Printf(format, v...)
+	write(context.Background(), slog.LevelError, fmt.Sprintf(format, v...))
 }
 
 func Warnf(format string, v ...interface{}) {
-	logger.warnLogger.Printf(format, v...)
+	write(context.Background(), slog.LevelWarn, fmt.Sprintf(format, v...))
 }
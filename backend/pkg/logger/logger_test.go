@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRecordWithRequest(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, configure(&buf, "info", "JSON"))
+	t.Cleanup(Init)
+
+	ctx := WithRequest(context.Background(), "req-1")
+	SetUserID(ctx, 42)
+	ErrorContext(ctx, "Failed to save expense: ", "boom")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "Failed to save expense: boom", record["msg"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, float64(42), record["user_id"])
+	// Источником записи указан вызывающий код, а не пакет logger
+	source := record["source"].(map[string]interface{})
+	assert.Contains(t, source["file"], "logger_test.go")
+}
+
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, configure(&buf, "warn", "text"))
+	t.Cleanup(Init)
+
+	Info("hidden")
+	assert.Empty(t, buf.String())
+	Warn("shown")
+	assert.Contains(t, buf.String(), "msg=shown")
+
+	assert.Error(t, configure(&buf, "verbose", "text"))
+	assert.Error(t, configure(&buf, "info", "xml"))
+}
@@ -0,0 +1,122 @@
+// Package mail отправляет письма пользователям. Sender реализован для SMTP и для локальной
+// разработки и тестов, где письма сохраняются в каталог.
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"budgetbuddy/pkg/config"
+	"budgetbuddy/pkg/logger"
+)
+
+// Message — текстовое письмо одному получателю.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender отправляет письма.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSender возвращает FileSender, если в конфигурации явно выбран MailDriverFile, и SMTPSender иначе.
+func NewSender(cfg *config.Config) Sender {
+	if cfg.MailDriver == config.MailDriverFile {
+		return &FileSender{Dir: cfg.MailDir, From: cfg.MailFrom}
+	}
+	return &SMTPSender{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.MailFrom,
+	}
+}
+
+// build собирает письмо в формате RFC 5322. Переводы строк в заголовках запрещены,
+// чтобы через адрес или тему нельзя было добавить свои заголовки.
+func (m Message) build(from string, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("mail header contains a line break")
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
+
+// SMTPSender отправляет письма через SMTP-сервер. Аутентификация PLAIN используется,
+// если задан Username; net/smtp сам включает STARTTLS, если сервер его поддерживает.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	data, err := msg.build(s.From, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	if err := smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, []string{msg.To}, data); err != nil {
+		logger.Error("Failed to send mail: ", err)
+		return err
+	}
+	return nil
+}
+
+// FileSender — замена SMTP для локальной разработки и тестов: письмо сохраняется в каталог Dir
+// файлом .eml. Содержимое писем в журнал не попадает: в нём ссылки с токенами.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+func (s *FileSender) Send(msg Message) error {
+	now := time.Now()
+	data, err := msg.build(s.From, now)
+	if err != nil {
+		return err
+	}
+	if s.Dir == "" {
+		return errors.New("mail directory is not set")
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		logger.Error("Failed to create mail directory: ", err)
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	if err := os.WriteFile(filepath.Join(s.Dir, name), data, 0o644); err != nil {
+		logger.Error("Failed to write mail: ", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"budgetbuddy/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageBuild(t *testing.T) {
+	msg := Message{To: "user@example.com", Subject: "Подтверждение", Body: "line 1\nline 2"}
+	data, err := msg.build("no-reply@example.com", time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	text := string(data)
+	assert.Contains(t, text, "To: user@example.com\r\n")
+	assert.Contains(t, text, "Subject: =?utf-8?q?")
+	assert.Contains(t, text, "Date: Wed, 02 Jul 2025 12:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(text, "\r\n\r\nline 1\r\nline 2"))
+
+	_, err = Message{To: "user@example.com\r\nBcc: other@example.com"}.build("no-reply@example.com", time.Now())
+	assert.Error(t, err)
+}
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewSender(&config.Config{MailDriver: config.MailDriverFile, MailDir: dir, MailFrom: "no-reply@example.com"})
+	require.IsType(t, &FileSender{}, sender)
+
+	require.NoError(t, sender.Send(Message{To: "user@example.com", Subject: "Hello", Body: "token"}))
+	files, err := filepath.Glob(filepath.Join(dir, "*-user@example.com.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "From: no-reply@example.com\r\n")
+
+	assert.IsType(t, &SMTPSender{}, NewSender(&config.Config{MailDriver: config.MailDriverSMTP, SMTPHost: "smtp.example.com", SMTPPort: "587"}))
+
+	// Без каталога письмо не отправляется и не пишется в журнал
+	assert.Error(t, (&FileSender{From: "no-reply@example.com"}).Send(Message{To: "user@example.com", Subject: "Hello", Body: "token"}))
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("http_requests_total", "Total HTTP requests.", "method", "route")
+	latency := reg.NewHistogramVec("http_request_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	reg.NewCounterVec("budgets_exceeded_total", "Budgets exceeded.")
+	reg.NewGaugeFunc("websocket_connections", "Open WebSocket connections.", func() float64 { return 3 })
+
+	requests.Inc("GET", "/transactions")
+	requests.Add(2, "POST", `/say "hi"`)
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	expected := `# HELP http_requests_total Total HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/transactions"} 1
+http_requests_total{method="POST",route="/say \"hi\""} 2
+# HELP http_request_duration_seconds Request latency.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/a",le="0.1"} 1
+http_request_duration_seconds_bucket{route="/a",le="1"} 2
+http_request_duration_seconds_bucket{route="/a",le="+Inf"} 3
+http_request_duration_seconds_sum{route="/a"} 5.55
+http_request_duration_seconds_count{route="/a"} 3
+# HELP budgets_exceeded_total Budgets exceeded.
+# TYPE budgets_exceeded_total counter
+budgets_exceeded_total 0
+# HELP websocket_connections Open WebSocket connections.
+# TYPE websocket_connections gauge
+websocket_connections 3
+`
+	assert.Equal(t, expected, w.Body.String())
+	assert.Equal(t, float64(2), requests.Value("POST", `/say "hi"`))
+	assert.Zero(t, requests.Value("DELETE", "/transactions"))
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounterVec("events_total", "Events.", "kind")
+	assert.Panics(t, func() { reg.NewGaugeFunc("events_total", "Duplicate.", func() float64 { return 0 }) })
+	assert.Panics(t, func() { counter.Inc() })
+}
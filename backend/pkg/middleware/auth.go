@@ -1,58 +1,107 @@
 package middleware
 
 import (
+	"budgetbuddy/pkg/auth"
 	"budgetbuddy/pkg/logger"
+	"context"
 	"net/http"
+	"strconv"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-func AuthMiddleware(jwtSecret string, next http.HandlerFunc) http.HandlerFunc {
+// SessionStore сообщает, активна ли сессия пользователя с указанным идентификатором (claim jti токена).
+type SessionStore interface {
+	IsSessionActive(ctx context.Context, userID int64, id string) (bool, error)
+}
+
+// Principal — аутентифицированный пользователь запроса.
+type Principal struct {
+	UserID    int64
+	SessionID string
+}
+
+type principalKey struct{}
+
+// WithPrincipal возвращает контекст с пользователем запроса.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext возвращает пользователя, установленного AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+func AuthMiddleware(jwtSecret string, sessions SessionStore, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tokenStr := r.Header.Get("Authorization")
 		if tokenStr == "" {
 			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			logger.Error("Authorization header is empty")
+			logger.ErrorContext(r.Context(), "Authorization header is empty")
 			return
 		}
 		if len(tokenStr) > 7 && tokenStr[:7] == "Bearer " {
 			tokenStr = tokenStr[7:]
 		} else {
 			http.Error(w, "Authorization header must start with 'Bearer '", http.StatusUnauthorized)
-			logger.Error("Invalid Authorization header format")
+			logger.ErrorContext(r.Context(), "Invalid Authorization header format")
 			return
 		}
 
 		if tokenStr == "" {
 			http.Error(w, "JWT token is empty", http.StatusUnauthorized)
-			logger.Error("JWT token is empty after removing Bearer prefix")
+			logger.ErrorContext(r.Context(), "JWT token is empty after removing Bearer prefix")
 			return
 		}
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		var claims jwt.StandardClaims
+		token, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+			if token.Method != jwt.SigningMethodHS256 {
+				return nil, jwt.NewValidationError("unexpected signing method", jwt.ValidationErrorSignatureInvalid)
+			}
 			return []byte(jwtSecret), nil
 		})
 		if err != nil || !token.Valid {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-			logger.Error("Failed to parse or validate token: ", err)
+			logger.ErrorContext(r.Context(), "Failed to parse or validate token: ", err)
+			return
+		}
+
+		if !claims.VerifyIssuer(auth.Issuer, true) || !claims.VerifyAudience(auth.Audience, true) {
+			http.Error(w, "Invalid token issuer or audience", http.StatusUnauthorized)
+			logger.ErrorContext(r.Context(), "Invalid token issuer or audience: ", claims.Issuer, " ", claims.Audience)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
-			logger.Error("Failed to parse token claims")
+		userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil || userID <= 0 {
+			http.Error(w, "Invalid subject in token", http.StatusUnauthorized)
+			logger.ErrorContext(r.Context(), "Invalid subject in token claims: ", claims.Subject)
 			return
 		}
 
-		email, ok := claims["email"].(string)
-		if !ok {
-			http.Error(w, "Invalid email in token", http.StatusUnauthorized)
-			logger.Error("Email not found in token claims")
+		// Токен без jti не привязан к сессии и не может быть отозван, поэтому не принимается
+		if claims.Id == "" {
+			http.Error(w, "Invalid session in token", http.StatusUnauthorized)
+			logger.ErrorContext(r.Context(), "Session ID not found in token claims")
+			return
+		}
+		active, err := sessions.IsSessionActive(r.Context(), userID, claims.Id)
+		if err != nil {
+			http.Error(w, "Failed to check session", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Failed to check session: ", err)
+			return
+		}
+		if !active {
+			http.Error(w, "Session has been revoked", http.StatusUnauthorized)
+			logger.ErrorContext(r.Context(), "Revoked or expired session: ", claims.Id)
 			return
 		}
 
-		r.Header.Set("X-User-Email", email)
-		next(w, r)
+		logger.SetUserID(r.Context(), userID)
+		ctx := WithPrincipal(r.Context(), Principal{UserID: userID, SessionID: claims.Id})
+		next(w, r.WithContext(ctx))
 	}
 }
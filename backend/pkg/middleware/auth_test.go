@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"budgetbuddy/pkg/auth"
+	"budgetbuddy/pkg/logger"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSessions map[string]int64
+
+func (s fakeSessions) IsSessionActive(ctx context.Context, userID int64, id string) (bool, error) {
+	owner, ok := s[id]
+	return ok && owner == userID, nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	logger.Init()
+	const secret = "test-secret"
+	sessions := fakeSessions{"s1": 7}
+	sign := func(claims jwt.StandardClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return token
+	}
+	valid := func() jwt.StandardClaims {
+		return jwt.StandardClaims{
+			Subject:   "7",
+			Id:        "s1",
+			Issuer:    auth.Issuer,
+			Audience:  auth.Audience,
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	var principal Principal
+	handler := AuthMiddleware(secret, sessions, func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFromContext(r.Context())
+	})
+	serve := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	t.Run("Valid Token", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(sign(valid())))
+		assert.Equal(t, Principal{UserID: 7, SessionID: "s1"}, principal)
+	})
+
+	for name, mutate := range map[string]func(*jwt.StandardClaims){
+		"Expired":         func(c *jwt.StandardClaims) { c.ExpiresAt = time.Now().Add(-time.Minute).Unix() },
+		"Wrong Audience":  func(c *jwt.StandardClaims) { c.Audience = "other" },
+		"Wrong Issuer":    func(c *jwt.StandardClaims) { c.Issuer = "other" },
+		"No Subject":      func(c *jwt.StandardClaims) { c.Subject = "" },
+		"No Session":      func(c *jwt.StandardClaims) { c.Id = "" },
+		"Revoked Session": func(c *jwt.StandardClaims) { c.Id = "s2" },
+		"Foreign Session": func(c *jwt.StandardClaims) { c.Subject = "8" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			claims := valid()
+			mutate(&claims)
+			assert.Equal(t, http.StatusUnauthorized, serve(sign(claims)))
+		})
+	}
+
+	t.Run("Spoofed Header Ignored", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User-Email", "user@example.com")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"budgetbuddy/pkg/metrics"
+)
+
+// HTTPMetrics — число HTTP-запросов и их длительность по маршрутам.
+type HTTPMetrics struct {
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+func NewHTTPMetrics(reg *metrics.Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: reg.NewCounterVec("http_requests_total",
+			"Total number of HTTP requests by method, route and status.", "method", "route", "status"),
+		duration: reg.NewHistogramVec("http_request_duration_seconds",
+			"HTTP request latency by method and route.", metrics.DefaultBuckets, "method", "route"),
+	}
+}
+
+// Instrument оборачивает mux. Маршрут — шаблон, с которым ServeMux сопоставил запрос
+// (Request.Pattern, заполняется в mux.ServeHTTP), а не путь: иначе число серий не ограничено.
+// Поэтому Instrument должен оборачивать сам mux, без копирования запроса между ними.
+func (m *HTTPMetrics) Instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.Inc(r.Method, route, strconv.Itoa(rec.status))
+		// Для WebSocket длительность — время жизни соединения, а не ответа: в гистограмму не попадает
+		if rec.status == http.StatusSwitchingProtocols {
+			return
+		}
+		m.duration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"budgetbuddy/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transactions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+	handler := NewHTTPMetrics(reg).Instrument(mux)
+
+	for _, path := range []string{"/transactions/1", "/transactions/2", "/missing", "/ws"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, path, nil))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.Write(&buf))
+	out := buf.String()
+	assert.Contains(t, out, `http_requests_total{method="DELETE",route="/transactions/{id}",status="204"} 2`)
+	assert.Contains(t, out, `http_requests_total{method="DELETE",route="unmatched",status="404"} 1`)
+	assert.Contains(t, out, `http_request_duration_seconds_count{method="DELETE",route="/transactions/{id}"} 2`)
+	// Соединение WebSocket считается, но его длительность в гистограмму не попадает
+	assert.Contains(t, out, `http_requests_total{method="DELETE",route="/ws",status="101"} 1`)
+	assert.NotContains(t, out, `http_request_duration_seconds_count{method="DELETE",route="/ws"}`)
+}
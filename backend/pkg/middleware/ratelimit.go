@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"budgetbuddy/pkg/logger"
+)
+
+// Limit — параметры корзины токенов: Rate токенов в секунду, не больше Burst подряд.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute возвращает лимит n запросов в минуту с запасом burst.
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// RateLimitStore хранит корзины токенов. MemoryStore подходит для одного экземпляра сервиса;
+// при нескольких экземплярах можно подключить общее хранилище с тем же интерфейсом.
+type RateLimitStore interface {
+	// Take забирает токен из корзины key. Если токенов нет, возвращает false и время,
+	// через которое появится следующий.
+	Take(key string, limit Limit, now time.Time) (bool, time.Duration)
+}
+
+// bucket хранит свой лимит: в общем хранилище у корзин разных ограничителей он разный.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryStore — RateLimitStore в памяти процесса. Полные корзины периодически удаляются,
+// чтобы память не росла с числом клиентов.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// sweepInterval — период удаления неактивных корзин.
+const sweepInterval = time.Minute
+
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep удаляет корзины, которые успели бы наполниться полностью: их состояние
+// не отличается от новой корзины.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		full := time.Duration(float64(b.limit.Burst) / b.limit.Rate * float64(time.Second))
+		if now.Sub(b.updated) >= full {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RateLimiter ограничивает частоту запросов по ключу. Ключи разных ограничителей в общем
+// хранилище разделяются префиксом name.
+type RateLimiter struct {
+	name  string
+	store RateLimitStore
+	limit Limit
+	now   func() time.Time
+}
+
+func NewRateLimiter(name string, store RateLimitStore, limit Limit) *RateLimiter {
+	return &RateLimiter{name: name, store: store, limit: limit, now: time.Now}
+}
+
+// Allow забирает токен для key и, если запрос не разрешён, возвращает время до следующей попытки.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	return l.store.Take(l.name+":"+key, l.limit, l.now())
+}
+
+// ByIP ограничивает запросы с одного IP-адреса.
+func (l *RateLimiter) ByIP(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if allowed, retryAfter := l.Allow(ClientIP(r)); !allowed {
+			TooManyRequests(w, retryAfter)
+			logger.Error("Rate limit ", l.name, " exceeded for ", ClientIP(r))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Handler — ByIP для http.Handler, чтобы ограничить все маршруты сервиса сразу.
+func (l *RateLimiter) Handler(next http.Handler) http.Handler {
+	return l.ByIP(next.ServeHTTP)
+}
+
+// ClientIP возвращает IP-адрес клиента из соединения. Заголовкам вроде X-Forwarded-For
+// не доверяем: их может подставить сам клиент.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// TooManyRequests отвечает 429 с заголовком Retry-After в целых секундах (не меньше одной).
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+}
+
+// Lockout блокирует ключ (например, учётную запись) после серии неудачных попыток входа.
+// После Threshold неудач подряд каждая следующая блокирует ключ на Base, 2·Base, 4·Base…,
+// но не дольше Max. Успешная попытка сбрасывает счётчик.
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*lockoutState
+	now      func() time.Time
+}
+
+type lockoutState struct {
+	count       int
+	lockedUntil time.Time
+	updated     time.Time
+}
+
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{Threshold: threshold, Base: base, Max: max, failures: make(map[string]*lockoutState), now: time.Now}
+}
+
+// Check возвращает оставшееся время блокировки key или 0, если попытка разрешена.
+func (l *Lockout) Check(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if state, ok := l.failures[key]; ok {
+		if wait := state.lockedUntil.Sub(l.now()); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Fail учитывает неудачную попытку и возвращает назначенную блокировку (0, если её нет).
+func (l *Lockout) Fail(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	state, ok := l.failures[key]
+	if !ok {
+		state = &lockoutState{}
+		l.failures[key] = state
+	}
+	state.count++
+	state.updated = now
+	if state.count < l.Threshold {
+		return 0
+	}
+	wait := l.Max
+	if shift := state.count - l.Threshold; shift < 32 {
+		wait = min(l.Base<<shift, l.Max)
+	}
+	state.lockedUntil = now.Add(wait)
+	return wait
+}
+
+// Reset сбрасывает счётчик неудач key.
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
+
+// sweep забывает ключи без неудач дольше Max: к этому времени блокировка уже закончилась бы.
+func (l *Lockout) sweep(now time.Time) {
+	for key, state := range l.failures {
+		if now.Sub(state.updated) > l.Max && now.After(state.lockedUntil) {
+			delete(l.failures, key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"budgetbuddy/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := store.Take("a", limit, now)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter := store.Take("a", limit, now)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, retryAfter)
+
+	// Другие ключи не затрагиваются
+	allowed, _ = store.Take("b", limit, now)
+	assert.True(t, allowed)
+
+	// Токены восстанавливаются со временем
+	allowed, _ = store.Take("a", limit, now.Add(time.Second))
+	assert.True(t, allowed)
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	fast := Limit{Rate: 1, Burst: 1}
+	slow := PerMinute(1, 2)
+	now := time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC)
+
+	store.Take("slow", slow, now)
+	store.Take("fast", fast, now)
+	// Очистку запускает ограничитель с быстрым лимитом, но медленная корзина ещё не наполнилась
+	store.Take("fast", fast, now.Add(sweepInterval))
+	assert.Contains(t, store.buckets, "slow")
+
+	store.Take("fast", fast, now.Add(3*sweepInterval))
+	assert.NotContains(t, store.buckets, "slow")
+}
+
+func TestRateLimiterByIP(t *testing.T) {
+	logger.Init()
+	limiter := NewRateLimiter("test", NewMemoryStore(), PerMinute(1, 1))
+	handler := limiter.ByIP(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNoContent, request("10.0.0.1:1000").Code)
+	w := request("10.0.0.1:1001")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusNoContent, request("10.0.0.2:1000").Code)
+}
+
+func TestLockout(t *testing.T) {
+	now := time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC)
+	lockout := NewLockout(3, time.Minute, 5*time.Minute)
+	lockout.now = func() time.Time { return now }
+
+	assert.Zero(t, lockout.Fail("user"))
+	assert.Zero(t, lockout.Fail("user"))
+	assert.Zero(t, lockout.Check("user"))
+
+	// Блокировка удваивается с каждой неудачей после порога, но не превышает Max
+	assert.Equal(t, time.Minute, lockout.Fail("user"))
+	assert.Equal(t, time.Minute, lockout.Check("user"))
+	assert.Equal(t, 2*time.Minute, lockout.Fail("user"))
+	assert.Equal(t, 4*time.Minute, lockout.Fail("user"))
+	assert.Equal(t, 5*time.Minute, lockout.Fail("user"))
+
+	now = now.Add(5 * time.Minute)
+	assert.Zero(t, lockout.Check("user"))
+
+	lockout.Fail("user")
+	lockout.Reset("user")
+	assert.Zero(t, lockout.Check("user"))
+	assert.Zero(t, lockout.Fail("user"))
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"budgetbuddy/pkg/auth"
+	"budgetbuddy/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger(t *testing.T) {
+	logger.Init()
+	var seen string
+	handler := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := logger.RequestFromContext(r.Context())
+		require.True(t, ok)
+		seen = info.ID
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	t.Run("Generated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusTeapot, w.Code)
+		assert.Len(t, seen, 32)
+		assert.Equal(t, seen, w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("Propagated", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(RequestIDHeader, "upstream-123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, "upstream-123", seen)
+		assert.Equal(t, "upstream-123", w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("Invalid Replaced", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(RequestIDHeader, "bad id\nwith newline")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Len(t, seen, 32)
+	})
+}
+
+func TestRequestLoggerUserID(t *testing.T) {
+	logger.Init()
+	token, err := auth.GenerateJWT("test-secret", 7, "s1")
+	require.NoError(t, err)
+
+	var userID int64
+	handler := RequestLogger(AuthMiddleware("test-secret", fakeSessions{"s1": 7}, func(w http.ResponseWriter, r *http.Request) {
+		info, _ := logger.RequestFromContext(r.Context())
+		userID = info.UserID()
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, int64(7), userID)
+}
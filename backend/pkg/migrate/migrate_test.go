@@ -0,0 +1,137 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"budgetbuddy/pkg/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0002_add_notes.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN note TEXT")},
+		"0002_add_notes.down.sql":    {Data: []byte("ALTER TABLE users DROP COLUMN note")},
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY)")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users")},
+		"README.md":                  {Data: []byte("not a migration")},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS())
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, Migration{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id SERIAL PRIMARY KEY)", Down: "DROP TABLE users"}, migrations[0])
+	assert.Equal(t, int64(2), migrations[1].Version)
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"Missing Up":    {"0001_create_users.down.sql": {Data: []byte("DROP TABLE users")}},
+		"Name Conflict": {"0001_a.up.sql": {Data: []byte("SELECT 1")}, "0001_b.up.sql": {Data: []byte("SELECT 1")}},
+		"Zero Version":  {"0000_a.up.sql": {Data: []byte("SELECT 1")}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(fsys)
+			assert.ErrorIs(t, err, ErrInvalidMigration)
+		})
+	}
+}
+
+func setup(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	logger.Init()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	migrator, err := New(db, testFS())
+	require.NoError(t, err)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	return migrator, mock
+}
+
+func TestUp(t *testing.T) {
+	migrator, mock := setup(t)
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE users ADD COLUMN note TEXT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(int64(2), "add_notes").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpFailureRollsBack(t *testing.T) {
+	migrator, mock := setup(t)
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE users`).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up()
+	assert.ErrorContains(t, err, "migration 1_create_users")
+	assert.Zero(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	migrator, mock := setup(t)
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE users DROP COLUMN note`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \$1`).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rolledBack, err := migrator.Down(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rolledBack)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatus(t *testing.T) {
+	migrator, mock := setup(t)
+	appliedAt := time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	assert.Equal(t, []Status{
+		{Version: 1, Name: "create_users", AppliedAt: &appliedAt},
+		{Version: 2, Name: "add_notes"},
+	}, statuses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	migrator, err := New(db, testFS())
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+
+	pending, err := migrator.Pending(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, int64(2), pending[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
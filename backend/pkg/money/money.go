@@ -0,0 +1,172 @@
+// Package money реализует точную денежную арифметику без float64.
+// Суммы хранятся в минимальных единицах валюты (копейках, центах).
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale — число знаков после запятой в денежных суммах.
+const Scale = 2
+
+const unitsPerWhole = 100
+
+// DefaultCurrency — валюта, которая используется, если пользователь не выбрал другую.
+const DefaultCurrency = "RUB"
+
+// ErrInvalidAmount возвращается при разборе некорректной суммы.
+var ErrInvalidAmount = errors.New("invalid money amount")
+
+// Amount — денежная сумма в минимальных единицах валюты.
+type Amount int64
+
+// FromMinor создаёт сумму из количества минимальных единиц.
+func FromMinor(units int64) Amount {
+	return Amount(units)
+}
+
+// Parse разбирает десятичную запись суммы вида "-123.45".
+// Экспоненциальная запись и более двух знаков после запятой не допускаются.
+func Parse(s string) (Amount, error) {
+	str := strings.TrimSpace(s)
+	negative := false
+	if str != "" && (str[0] == '-' || str[0] == '+') {
+		negative = str[0] == '-'
+		str = str[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(str, ".")
+	if whole == "" || (hasPoint && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(frac) > Scale {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, Scale)
+	}
+
+	wholeUnits, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || wholeUnits > math.MaxInt64/unitsPerWhole-1 {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, s)
+	}
+	fracUnits := int64(0)
+	if frac != "" {
+		fracUnits, _ = strconv.ParseInt(frac+strings.Repeat("0", Scale-len(frac)), 10, 64)
+	}
+
+	units := wholeUnits*unitsPerWhole + fracUnits
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// MustParse работает как Parse, но паникует при ошибке. Предназначена для констант и тестов.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// ValidCurrency проверяет, что код валюты имеет формат ISO 4217 (три заглавные латинские буквы).
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Minor возвращает сумму в минимальных единицах валюты.
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// String возвращает десятичную запись суммы с двумя знаками после запятой.
+func (a Amount) String() string {
+	sign := ""
+	units := uint64(a)
+	if a < 0 {
+		sign = "-"
+		units = uint64(-a)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/unitsPerWhole, units%unitsPerWhole)
+}
+
+// MarshalJSON кодирует сумму как JSON-число с двумя знаками после запятой.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON принимает сумму в виде JSON-числа или строки.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value передаёт сумму в базу строкой, чтобы NUMERIC сохранял её без потерь.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan читает значение столбца NUMERIC.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = Amount(v * unitsPerWhole)
+		return nil
+	case nil:
+		return fmt.Errorf("%w: cannot scan NULL", ErrInvalidAmount)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+	}
+}
+
+// scanString разбирает значение из базы. Незначащие нули после второго знака,
+// которые Postgres возвращает для вычисленных NUMERIC, отбрасываются.
+func (a *Amount) scanString(s string) error {
+	if whole, frac, ok := strings.Cut(s, "."); ok && len(frac) > Scale {
+		if strings.TrimRight(frac[Scale:], "0") == "" {
+			s = whole + "." + frac[:Scale]
+		}
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
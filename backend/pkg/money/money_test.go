@@ -0,0 +1,77 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	valid := map[string]Amount{
+		"0":        0,
+		"200.75":   20075,
+		"200.7":    20070,
+		"-0.01":    -1,
+		"+15":      1500,
+		" 1000.5 ": 100050,
+	}
+	for input, expected := range valid {
+		amount, err := Parse(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, amount, input)
+	}
+
+	for _, input := range []string{"", "-", "1.", ".5", "1.234", "1e3", "12a", "1,50", "99999999999999999999"} {
+		_, err := Parse(input)
+		assert.ErrorIs(t, err, ErrInvalidAmount, input)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "200.75", Amount(20075).String())
+	assert.Equal(t, "0.05", Amount(5).String())
+	assert.Equal(t, "-3.10", Amount(-310).String())
+}
+
+func TestJSON(t *testing.T) {
+	var req struct {
+		Amount Amount `json:"amount"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"amount": 0.1}`), &req))
+	assert.Equal(t, Amount(10), req.Amount)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"amount": "19.99"}`), &req))
+	assert.Equal(t, Amount(1999), req.Amount)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.001}`), &req))
+
+	data, err := json.Marshal(req)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 19.99}`, string(data))
+}
+
+func TestScan(t *testing.T) {
+	var a Amount
+	require.NoError(t, a.Scan([]byte("401.50")))
+	assert.Equal(t, Amount(40150), a)
+
+	require.NoError(t, a.Scan([]byte("12.3400000000000000")))
+	assert.Equal(t, Amount(1234), a)
+
+	require.NoError(t, a.Scan(int64(7)))
+	assert.Equal(t, Amount(700), a)
+
+	assert.Error(t, a.Scan([]byte("12.345")))
+	assert.Error(t, a.Scan(nil))
+	assert.Error(t, a.Scan(1.5))
+}
+
+func TestValidCurrency(t *testing.T) {
+	assert.True(t, ValidCurrency("RUB"))
+	assert.True(t, ValidCurrency("USD"))
+	assert.False(t, ValidCurrency("usd"))
+	assert.False(t, ValidCurrency("EURO"))
+	assert.False(t, ValidCurrency(""))
+}
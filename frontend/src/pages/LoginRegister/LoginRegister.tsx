@@ -64,16 +64,12 @@ function LoginRegister() {
         }
         setIsLoading(true);
         try {
-            const response = await axios.post<{ token: string }>('http://localhost:8080/register', {
+            // Регистрация не открывает сессию: после неё входим с теми же данными
+            await axios.post('http://localhost:8080/register', {
                 email,
                 password,
                 name,
             });
-            localStorage.setItem('token', response.data.token);
-            setIsAuthenticated(true);
-            setError("");
-            console.log('Успешная регистрация:', response.data.token);
-            return true;
         } catch (error: unknown) {
             if (axios.isAxiosError(error)) {
                 const axiosError = error as AxiosError<{ message?: string }>;
@@ -90,6 +86,7 @@ function LoginRegister() {
         } finally {
             setIsLoading(false);
         }
+        return handleLogin(email, password);
     }
 
     const handleLoginSubmit = async (e: FormEvent<HTMLFormElement>) => {
@@ -42,7 +42,7 @@ function Main() {
       }
 
       const data = await response.json();
-      setTransactions(data);
+      setTransactions(data.transactions);
     } catch (err) {
       setError((err as Error).message || 'Неизвестная ошибка');
     } finally {